@@ -0,0 +1,52 @@
+// +build !generate
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "time"
+
+    "k8s.io/klog/v2"
+)
+
+// runExport implements the "export" subcommand, which fetches the topology
+// from a running scheduler and writes it as Graphviz DOT or JSON.
+func runExport(args []string) {
+    fs := flag.NewFlagSet("export", flag.ExitOnError)
+    server := fs.String("server", "http://localhost:8080", "Address of the scheduler HTTP endpoint")
+    format := fs.String("format", "dot", "Output format: dot or json")
+    output := fs.String("output", "", "File to write to (defaults to stdout)")
+    fs.Parse(args)
+
+    endpoint := fmt.Sprintf("%s/debug/topology?format=%s", *server, url.QueryEscape(*format))
+    client := &http.Client{Timeout: 30 * time.Second}
+
+    resp, err := client.Get(endpoint)
+    if err != nil {
+        klog.Fatalf("Error fetching topology from %s: %v", *server, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        body, _ := io.ReadAll(resp.Body)
+        klog.Fatalf("Error fetching topology: %s: %s", resp.Status, body)
+    }
+
+    var out io.Writer = os.Stdout
+    if *output != "" {
+        file, err := os.Create(*output)
+        if err != nil {
+            klog.Fatalf("Error creating %s: %v", *output, err)
+        }
+        defer file.Close()
+        out = file
+    }
+
+    if _, err := io.Copy(out, resp.Body); err != nil {
+        klog.Fatalf("Error writing topology: %v", err)
+    }
+}
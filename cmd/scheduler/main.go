@@ -1,140 +1,436 @@
-// +build !generate
-package main
-
-import (
-    "context"
-    "flag"
-    "fmt"
-    "net/http"
-    "os"
-    "time"
-
-    "k8s.io/client-go/kubernetes"
-    "k8s.io/client-go/tools/clientcmd"
-    "k8s.io/client-go/tools/leaderelection"
-    "k8s.io/client-go/tools/leaderelection/resourcelock"
-    "k8s.io/klog/v2"
-    "k8s.io/kubernetes/pkg/scheduler/apis/config"
-    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-    "github.com/prometheus/client_golang/prometheus/promhttp"
-
-    "github.com/nod-ai/topology-aware-scheduler/pkg/scheduler/algorithm"
-    clientset "github.com/nod-ai/topology-aware-scheduler/pkg/generated/clientset/versioned"
-)
-
-var (
-    masterURL            string
-    kubeconfig          string
-    schedulerName       string
-    leaderElect         bool
-    lockObjectName      string
-    lockObjectNamespace string
-    version            string // Added for version info
-    buildDate          string // Added for build date
-)
-
-func main() {
-    klog.InitFlags(nil)
-    flag.Parse()
-
-    klog.Infof("Starting Topology-Aware GPU Scheduler - Version: %s, Build Date: %s", version, buildDate)
-
-    // Build kubernetes config
-    cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
-    if err != nil {
-        klog.Fatalf("Error building kubeconfig: %v", err)
-    }
-
-    // Create kubernetes clientset
-    kubeClient, err := kubernetes.NewForConfig(cfg)
-    if err != nil {
-        klog.Fatalf("Error building kubernetes clientset: %v", err)
-    }
-
-    // Create topology clientset
-    topologyClient, err := clientset.NewForConfig(cfg)
-    if err != nil {
-        klog.Fatalf("Error building topology clientset: %v", err)
-    }
-
-    // Create scheduler cache and topology cache
-    nodeCache := algorithm.NewNodeCache()
-    topologyCache := algorithm.NewTopologyCache(nodeCache)
-    
-    // Create the scheduler
-    scheduler := algorithm.NewTopologyScheduler(topologyCache)
-
-    // Start metrics server
-    go func() {
-        http.Handle("/metrics", promhttp.Handler())
-        klog.Fatal(http.ListenAndServe(":8080", nil))
-    }()
-
-    // Leader election handling
-    if leaderElect {
-        lock := &resourcelock.LeaseLock{
-            LeaseMeta: metav1.ObjectMeta{
-                Name:      lockObjectName,
-                Namespace: lockObjectNamespace,
-            },
-            Client: kubeClient.CoordinationV1(),
-            LockConfig: resourcelock.ResourceLockConfig{
-                Identity: getHostname(),
-            },
-        }
-
-        leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
-            Lock:            lock,
-            LeaseDuration:  15 * time.Second,
-            RenewDeadline:  10 * time.Second,
-            RetryPeriod:    2 * time.Second,
-            Callbacks: leaderelection.LeaderCallbacks{
-                OnStartedLeading: func(ctx context.Context) {
-                    runScheduler(scheduler, kubeClient)
-                },
-                OnStoppedLeading: func() {
-                    klog.Info("Leader lost")
-                    os.Exit(0)
-                },
-                OnNewLeader: func(identity string) {
-                    klog.Infof("New leader elected: %v", identity)
-                },
-            },
-        })
-    } else {
-        runScheduler(scheduler, kubeClient)
-    }
-}
-
-func runScheduler(scheduler *algorithm.TopologyScheduler, client kubernetes.Interface) {
-    sched := scheduler.NewScheduler(client)
-    
-    // Start scheduling loop
-    stopCh := make(chan struct{})
-    defer close(stopCh)
-    
-    go sched.Run(stopCh)
-    
-    // Start node monitor
-    monitor := sched.GetMonitor()
-    go monitor.Start()
-    
-    <-stopCh
-}
-
-func getHostname() string {
-    hostname, err := os.Hostname()
-    if err != nil {
-        return "unknown"
-    }
-    return hostname
-}
-
-func init() {
-    flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to kubeconfig file")
-    flag.StringVar(&masterURL, "master", "", "Kubernetes API server address")
-    flag.StringVar(&schedulerName, "scheduler-name", "topology-aware-scheduler", "Name of the scheduler")
-    flag.BoolVar(&leaderElect, "leader-elect", true, "Enable leader election")
-    flag.StringVar(&lockObjectName, "lock-object-name", "topology-scheduler", "Name of lock object")
-    flag.StringVar(&lockObjectNamespace, "lock-object-namespace", "kube-system", "Namespace of lock object")
-}
+// +build !generate
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "time"
+
+    kubeinformers "k8s.io/client-go/informers"
+    "k8s.io/client-go/kubernetes"
+    "k8s.io/client-go/kubernetes/scheme"
+    typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/client-go/tools/clientcmd"
+    "k8s.io/client-go/tools/events"
+    "k8s.io/client-go/tools/leaderelection"
+    "k8s.io/client-go/tools/leaderelection/resourcelock"
+    "k8s.io/client-go/tools/record"
+    "k8s.io/klog/v2"
+    kubescheduler "k8s.io/kubernetes/pkg/scheduler"
+    "k8s.io/kubernetes/pkg/scheduler/apis/config"
+    "k8s.io/kubernetes/pkg/scheduler/apis/config/latest"
+    frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+    "k8s.io/kubernetes/pkg/scheduler/profile"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    corev1 "k8s.io/api/core/v1"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/scheduler/algorithm"
+    clientset "github.com/nod-ai/topology-aware-scheduler/pkg/generated/clientset/versioned"
+    informers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/informers/externalversions"
+)
+
+var (
+    masterURL            string
+    kubeconfig          string
+    schedulerName       string
+    leaderElect         bool
+    lockObjectName      string
+    lockObjectNamespace string
+    version            string // Added for version info
+    buildDate          string // Added for build date
+    configSource        string
+    configFile          string
+    configNamespace     string
+    configName          string
+    configReloadInterval time.Duration
+    assumedPodTTL       time.Duration
+    checkpointStore     string
+    checkpointPath      string
+    checkpointName      string
+    checkpointInterval  time.Duration
+    auditInterval       time.Duration
+    auditSelfHeal       bool
+    auditEveryCycle     bool
+    recoveryLimits      = algorithm.DefaultRecoveryLimits()
+    nodeFailurePolicy   = algorithm.DefaultNodeFailurePolicy()
+    gpuHealthSources    gpuHealthSourceFlag
+    gpuHealthPolicy     = algorithm.DefaultGPUHealthPolicy()
+    drainMaxDisruptedPods int
+    decisionLogTarget     string
+    decisionLogMaxSize    int
+    decisionLogMaxBackups int
+    tracingEndpoint       string
+    tracingSampleRatio    float64
+    adminAddress          string
+)
+
+// gpuHealthSourceFlag collects repeated --gpu-health-source flags
+type gpuHealthSourceFlag []algorithm.GPUHealthEndpoint
+
+func (f *gpuHealthSourceFlag) String() string {
+    return fmt.Sprint(*f)
+}
+
+func (f *gpuHealthSourceFlag) Set(value string) error {
+    endpoint, err := algorithm.ParseGPUHealthEndpoint(value)
+    if err != nil {
+        return err
+    }
+    *f = append(*f, endpoint)
+    return nil
+}
+
+func main() {
+    if len(os.Args) > 1 && os.Args[1] == "export" {
+        runExport(os.Args[2:])
+        return
+    }
+    if len(os.Args) > 1 && os.Args[1] == "recovery-plan" {
+        runRecoveryPlan(os.Args[2:])
+        return
+    }
+
+    klog.InitFlags(nil)
+    flag.Parse()
+
+    klog.Infof("Starting Topology-Aware GPU Scheduler - Version: %s, Build Date: %s", version, buildDate)
+
+    // flushers run in reverse order on exit, also when losing leadership
+    // exits the process without returning from main
+    var flushers []func()
+    flush := func() {
+        for i := len(flushers) - 1; i >= 0; i-- {
+            flushers[i]()
+        }
+    }
+    defer flush()
+
+    // Export scheduling and recovery spans when asked to
+    if tracingEndpoint != "" {
+        shutdown, err := algorithm.SetupTracing(context.Background(), tracingEndpoint, schedulerName, tracingSampleRatio)
+        if err != nil {
+            klog.Fatalf("Error setting up tracing: %v", err)
+        }
+        flushers = append(flushers, func() {
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+            defer cancel()
+            if err := shutdown(ctx); err != nil {
+                klog.Errorf("Error flushing traces: %v", err)
+            }
+        })
+    }
+
+    // Build kubernetes config
+    cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+    if err != nil {
+        klog.Fatalf("Error building kubeconfig: %v", err)
+    }
+
+    // Create kubernetes clientset
+    kubeClient, err := kubernetes.NewForConfig(cfg)
+    if err != nil {
+        klog.Fatalf("Error building kubernetes clientset: %v", err)
+    }
+
+    // Create topology clientset
+    topologyClient, err := clientset.NewForConfig(cfg)
+    if err != nil {
+        klog.Fatalf("Error building topology clientset: %v", err)
+    }
+
+    // Create scheduler cache and topology cache
+    nodeCache := algorithm.NewNodeCache()
+    topologyCache := algorithm.NewTopologyCache(nodeCache)
+    topologyCache.SetAssumedPodTTL(assumedPodTTL)
+    
+    // Create the event recorder. The broadcaster aggregates repeated events
+    // and the scheduler drops identical ones within a few minutes.
+    eventBroadcaster := record.NewBroadcaster()
+    eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+    recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: schedulerName})
+
+    // Keep the caches populated from the cluster's nodes
+    kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 30*time.Second)
+    topologyCache.AddNodeEventHandler(kubeInformerFactory.Core().V1().Nodes())
+    topologyCache.AddPodEventHandler(kubeInformerFactory.Core().V1().Pods())
+
+    // Create the scheduler
+    scheduler := algorithm.NewTopologyScheduler(topologyCache)
+    scheduler.SetEventRecorder(recorder)
+
+    // Record every placement and recovery decision when asked to
+    if decisionLogTarget != "" {
+        decisionLog, err := algorithm.OpenDecisionLog(decisionLogTarget, decisionLogMaxSize, decisionLogMaxBackups)
+        if err != nil {
+            klog.Fatalf("Error opening decision log: %v", err)
+        }
+        flushers = append(flushers, func() {
+            if err := decisionLog.Close(); err != nil {
+                klog.Errorf("Error closing decision log: %v", err)
+            }
+        })
+        scheduler.SetDecisionLog(decisionLog)
+    }
+
+    // Load the scheduler config before scheduling and keep it in sync
+    stopCh := make(chan struct{})
+    topologyInformerFactory := informers.NewSharedInformerFactory(topologyClient, 30*time.Second)
+    configWatcher := algorithm.NewConfigWatcher(scheduler, configFile, configReloadInterval)
+
+    switch configSource {
+    case algorithm.ConfigSourceFile:
+        if err := configWatcher.LoadFile(); err != nil {
+            klog.Fatalf("Error loading scheduler config: %v", err)
+        }
+        go configWatcher.WatchFile(stopCh)
+    case algorithm.ConfigSourceCRD:
+        configWatcher.WatchCRD(topologyInformerFactory.Topology().V1alpha1().TopologySchedulerConfigs(),
+            configNamespace, configName)
+    default:
+        klog.Fatalf("Unknown config source %q, must be %q or %q",
+            configSource, algorithm.ConfigSourceFile, algorithm.ConfigSourceCRD)
+    }
+
+    // Apply link overrides declared on DomainConfigs
+    linkWatcher := algorithm.NewLinkWatcher(topologyCache)
+    linkWatcher.Watch(topologyInformerFactory.Topology().V1alpha1().DomainConfigs())
+
+    // Recover pods from nodes the monitor declares failed
+    domainManager := algorithm.NewDomainManager()
+    recoveryManager := algorithm.NewRecoveryManager(domainManager, scheduler, kubeClient)
+    recoveryManager.SetLimits(recoveryLimits)
+    recoveryManager.Watch(kubeInformerFactory.Core().V1().Pods())
+
+    leaseInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 30*time.Second,
+        kubeinformers.WithNamespace(corev1.NamespaceNodeLease))
+    monitor := scheduler.GetMonitor()
+    monitor.SetPolicy(nodeFailurePolicy)
+    monitor.SetRecoveryManager(recoveryManager, kubeClient)
+    monitor.Watch(kubeInformerFactory.Core().V1().Nodes(), kubeInformerFactory.Core().V1().Pods(),
+        leaseInformerFactory.Coordination().V1().Leases())
+
+    // Move pods out of draining domains and report when they are empty
+    drainer := algorithm.NewDomainDrainer(scheduler, recoveryManager, topologyClient, drainMaxDisruptedPods)
+    drainer.Watch(kubeInformerFactory.Core().V1().Pods())
+
+    // Spares are tainted by the leader; claimed replacements tolerate them
+    scheduler.GetSparePool().Watch(kubeInformerFactory.Core().V1().Pods())
+
+    // Report domains, config and recovery state on TopologySchedulers
+    statusController := algorithm.NewStatusController(scheduler, recoveryManager, topologyClient, schedulerName)
+    statusController.Watch(topologyInformerFactory.Topology().V1alpha1().TopologySchedulers())
+
+    kubeInformerFactory.Start(stopCh)
+    topologyInformerFactory.Start(stopCh)
+    leaseInformerFactory.Start(stopCh)
+    kubeInformerFactory.WaitForCacheSync(stopCh)
+    topologyInformerFactory.WaitForCacheSync(stopCh)
+    leaseInformerFactory.WaitForCacheSync(stopCh)
+    go topologyCache.RunAssumedPodCleanup(stopCh)
+    go topologyCache.RunFlapExpiry(stopCh)
+    go scheduler.GetSparePool().Run(stopCh)
+
+    // Every replica tracks GPU health so its cache is current when it leads,
+    // but only the leader moves pods off bad devices
+    var gpuHealth *algorithm.GPUHealthSource
+    if len(gpuHealthSources) > 0 {
+        gpuHealth = algorithm.NewGPUHealthSource(scheduler, gpuHealthSources, gpuHealthPolicy)
+        go gpuHealth.Run(stopCh)
+    }
+
+    checkpointer := newCheckpointer(scheduler, kubeClient, kubeInformerFactory.Core().V1().Pods().Lister())
+    if checkpointer != nil {
+        checkpointer.SetRecoveryManager(recoveryManager)
+    }
+    lead := func(ctx context.Context) {
+        if gpuHealth != nil {
+            gpuHealth.SetRecoveryManager(recoveryManager)
+        }
+        scheduler.GetSparePool().SetClient(kubeClient)
+        go drainer.Run(ctx.Done())
+        go statusController.Run(ctx.Done())
+        startLeading(ctx, scheduler, kubeClient, kubeInformerFactory, checkpointer)
+    }
+
+    if config := scheduler.GetConfig(); config.Source == algorithm.ConfigSourceDefault {
+        klog.Warningf("No scheduler config found, using default weights %+v", config.Weights)
+    }
+
+    // Start metrics server
+    go func() {
+        http.Handle("/metrics", promhttp.Handler())
+        http.Handle("/debug/topology", algorithm.NewTopologyExportHandler(topologyCache))
+        klog.Fatal(http.ListenAndServe(":8080", nil))
+    }()
+
+    // The admin endpoints change scheduling, so they are kept off the metrics
+    // Service on a listener that is local to the pod by default
+    go func() {
+        admin := http.NewServeMux()
+        admin.Handle("/admin/links", algorithm.NewLinkAdminHandler(topologyCache))
+        admin.Handle("/admin/recovery/", algorithm.NewRecoveryAdminHandler(recoveryManager))
+        klog.Fatal(http.ListenAndServe(adminAddress, admin))
+    }()
+
+    // Audit the in-memory caches against the API server
+    auditor := algorithm.NewCacheAuditor(kubeClient, scheduler, domainManager, recorder, auditSelfHeal, auditInterval)
+    if auditEveryCycle {
+        scheduler.SetCycleAuditor(auditor)
+    }
+    if auditInterval > 0 {
+        go auditor.Run(stopCh)
+    }
+
+    // Leader election handling
+    if leaderElect {
+        lock := &resourcelock.LeaseLock{
+            LeaseMeta: metav1.ObjectMeta{
+                Name:      lockObjectName,
+                Namespace: lockObjectNamespace,
+            },
+            Client: kubeClient.CoordinationV1(),
+            LockConfig: resourcelock.ResourceLockConfig{
+                Identity: getHostname(),
+            },
+        }
+
+        leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+            Lock:            lock,
+            LeaseDuration:  15 * time.Second,
+            RenewDeadline:  10 * time.Second,
+            RetryPeriod:    2 * time.Second,
+            Callbacks: leaderelection.LeaderCallbacks{
+                OnStartedLeading: lead,
+                OnStoppedLeading: func() {
+                    klog.Info("Leader lost")
+                    flush()
+                    klog.Flush()
+                    os.Exit(0)
+                },
+                OnNewLeader: func(identity string) {
+                    klog.Infof("New leader elected: %v", identity)
+                },
+            },
+        })
+    } else {
+        lead(context.Background())
+    }
+}
+
+func newCheckpointer(scheduler *algorithm.TopologyScheduler, client kubernetes.Interface, pods corelisters.PodLister) *algorithm.Checkpointer {
+    var store algorithm.CheckpointStore
+    switch checkpointStore {
+    case algorithm.CheckpointStoreConfigMap:
+        store = algorithm.NewConfigMapCheckpointStore(client, lockObjectNamespace, checkpointName)
+    case algorithm.CheckpointStoreFile:
+        store = algorithm.NewFileCheckpointStore(checkpointPath)
+    case algorithm.CheckpointStoreNone:
+        return nil
+    default:
+        klog.Fatalf("Unknown checkpoint store %q", checkpointStore)
+    }
+    return algorithm.NewCheckpointer(scheduler, store, pods, getHostname(), checkpointInterval)
+}
+
+// startLeading restores the previous leader's checkpoint before any scheduling
+// happens, so reservations it held are not handed out twice
+func startLeading(ctx context.Context, scheduler *algorithm.TopologyScheduler, client kubernetes.Interface,
+    informerFactory kubeinformers.SharedInformerFactory, checkpointer *algorithm.Checkpointer) {
+    if checkpointer != nil {
+        if err := checkpointer.Restore(ctx); err != nil {
+            klog.Errorf("Error restoring checkpoint, reservations of the previous leader are lost: %v", err)
+        }
+        go checkpointer.Run(ctx)
+    }
+    runScheduler(ctx, scheduler, client, informerFactory)
+}
+
+// runScheduler runs the scheduling framework with the default plugins and the
+// topology plugin. The plugin is built on scheduler, so it schedules with the
+// same cache and config as the rest of the binary.
+func runScheduler(ctx context.Context, scheduler *algorithm.TopologyScheduler, client kubernetes.Interface,
+    informerFactory kubeinformers.SharedInformerFactory) {
+    defaults, err := latest.Default()
+    if err != nil {
+        klog.Fatalf("Error building the default scheduler config: %v", err)
+    }
+    schedProfile := defaults.Profiles[0]
+    schedProfile.SchedulerName = schedulerName
+    schedProfile.Plugins.MultiPoint.Enabled = append(schedProfile.Plugins.MultiPoint.Enabled,
+        config.Plugin{Name: algorithm.Name})
+
+    broadcaster := events.NewBroadcaster(&events.EventSinkImpl{Interface: client.EventsV1()})
+    broadcaster.StartRecordingToSink(ctx.Done())
+
+    sched, err := kubescheduler.New(ctx, client, informerFactory, nil, profile.NewRecorderFactory(broadcaster),
+        kubescheduler.WithProfiles(schedProfile),
+        kubescheduler.WithFrameworkOutOfTreeRegistry(frameworkruntime.Registry{
+            algorithm.Name: algorithm.NewFactory(scheduler),
+        }))
+    if err != nil {
+        klog.Fatalf("Error creating scheduler: %v", err)
+    }
+    // The framework adds informers of its own that have not been started yet
+    informerFactory.Start(ctx.Done())
+    informerFactory.WaitForCacheSync(ctx.Done())
+
+    go scheduler.GetMonitor().Start(ctx.Done())
+    sched.Run(ctx)
+}
+
+func getHostname() string {
+    hostname, err := os.Hostname()
+    if err != nil {
+        return "unknown"
+    }
+    return hostname
+}
+
+func init() {
+    flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to kubeconfig file")
+    flag.StringVar(&masterURL, "master", "", "Kubernetes API server address")
+    flag.StringVar(&schedulerName, "scheduler-name", "topology-aware-scheduler", "Name of the scheduler")
+    flag.BoolVar(&leaderElect, "leader-elect", true, "Enable leader election")
+    flag.StringVar(&lockObjectName, "lock-object-name", "topology-scheduler", "Name of lock object")
+    flag.StringVar(&lockObjectNamespace, "lock-object-namespace", "kube-system", "Namespace of lock object")
+    flag.StringVar(&configSource, "config-source", "file", "Where to load the scheduler config from: file or crd")
+    flag.StringVar(&configFile, "config-file", "/app/config/config.yaml", "Path to the mounted SchedulerConfig file")
+    flag.StringVar(&configNamespace, "config-namespace", "kube-system", "Namespace of the TopologySchedulerConfig to load")
+    flag.StringVar(&configName, "config-name", "topology-scheduler-config", "Name of the TopologySchedulerConfig to load")
+    flag.DurationVar(&assumedPodTTL, "assumed-pod-ttl", algorithm.DefaultAssumedPodTTL, "How long a pod holds its GPUs after its binding is sent without being observed bound")
+    flag.StringVar(&checkpointStore, "checkpoint-store", algorithm.CheckpointStoreConfigMap, "Where to checkpoint leader state: configmap, file or none")
+    flag.StringVar(&checkpointPath, "checkpoint-path", "/var/lib/topology-scheduler/checkpoint.json", "Checkpoint file when --checkpoint-store=file")
+    flag.StringVar(&checkpointName, "checkpoint-name", "topology-scheduler-checkpoint", "ConfigMap in --lock-object-namespace holding the checkpoint")
+    flag.DurationVar(&checkpointInterval, "checkpoint-interval", 5*time.Second, "How often the leader checkpoints its state")
+    flag.DurationVar(&auditInterval, "audit-interval", 5*time.Minute, "How often to audit caches against the API server, 0 disables periodic audits")
+    flag.BoolVar(&auditSelfHeal, "audit-self-heal", false, "Rebuild caches from API server state when the auditor finds mismatches")
+    flag.BoolVar(&auditEveryCycle, "audit-every-cycle", false, "Debug: audit caches after every scheduling cycle")
+    flag.IntVar(&recoveryLimits.Concurrency, "recovery-concurrency", algorithm.DefaultRecoveryConcurrency, "How many pod migrations recovery runs at once")
+    flag.Float64Var(&recoveryLimits.QPS, "recovery-qps", algorithm.DefaultRecoveryQPS, "How many evictions per second recovery may issue")
+    flag.IntVar(&recoveryLimits.Burst, "recovery-burst", algorithm.DefaultRecoveryBurst, "Burst of evictions allowed above --recovery-qps")
+    flag.IntVar(&recoveryLimits.FailureThreshold, "recovery-failure-threshold", algorithm.DefaultRecoveryFailureThreshold, "Failed migrations within --recovery-failure-window that pause automatic recovery, 0 disables")
+    flag.IntVar(&recoveryLimits.NodeFailureThreshold, "recovery-node-failure-threshold", algorithm.DefaultRecoveryNodeFailureThreshold, "Failed nodes within --recovery-failure-window that pause automatic recovery, 0 disables")
+    flag.DurationVar(&recoveryLimits.FailureWindow, "recovery-failure-window", algorithm.DefaultRecoveryFailureWindow, "Window over which recovery failures are counted")
+    flag.DurationVar(&nodeFailurePolicy.NotReadyGracePeriod, "node-notready-grace-period", algorithm.DefaultNotReadyGracePeriod, "How long a node must stay unhealthy before its pods are recovered")
+    flag.DurationVar(&nodeFailurePolicy.LeaseGracePeriod, "node-lease-grace-period", algorithm.DefaultLeaseGracePeriod, "How long a node's heartbeat lease may go unrenewed before the node is unhealthy")
+    flag.IntVar(&nodeFailurePolicy.FlapThreshold, "node-flap-threshold", algorithm.DefaultFlapThreshold, "Times a node may turn unhealthy within --node-flap-window before it is quarantined, 0 disables quarantine")
+    flag.DurationVar(&nodeFailurePolicy.FlapWindow, "node-flap-window", algorithm.DefaultFlapWindow, "Window over which node health flaps are counted")
+    flag.DurationVar(&nodeFailurePolicy.QuarantineDuration, "node-quarantine-duration", algorithm.DefaultQuarantineDuration, "How long a flapping node is quarantined")
+    flag.Var(&gpuHealthSources, "gpu-health-source", "GPU health source as format=location, where format is dcgm, amd or agent and location is a URL or file of Prometheus text metrics; may be repeated")
+    flag.DurationVar(&gpuHealthPolicy.Interval, "gpu-health-interval", algorithm.DefaultGPUHealthInterval, "How often the GPU health sources are read")
+    flag.Float64Var(&gpuHealthPolicy.TemperatureLimit, "gpu-temperature-limit", algorithm.DefaultGPUTemperatureLimit, "GPU temperature in Celsius above which a device is unhealthy, 0 disables")
+    flag.DurationVar(&gpuHealthPolicy.FailurePersistence, "gpu-failure-persistence", algorithm.DefaultGPUFailurePersistence, "How long a GPU must stay unhealthy before the pods using it are moved")
+    flag.IntVar(&drainMaxDisruptedPods, "drain-max-disrupted-pods", algorithm.DefaultDrainMaxDisruptedPods, "How many GPU pods of a draining domain may be moving at once")
+    flag.StringVar(&decisionLogTarget, "decision-log", "", "Where to write the JSON Lines decision log: a file path or stdout; empty disables it")
+    flag.IntVar(&decisionLogMaxSize, "decision-log-max-size", algorithm.DefaultDecisionLogMaxSizeMB, "Size in megabytes at which the decision log file is rotated, 0 disables rotation")
+    flag.IntVar(&decisionLogMaxBackups, "decision-log-max-backups", algorithm.DefaultDecisionLogMaxBackups, "How many rotated decision log files to keep")
+    flag.StringVar(&tracingEndpoint, "tracing-endpoint", "", "OTLP/gRPC collector to export traces to as http://host:port or https://host:port, or file:///path to write them to a file; empty disables tracing")
+    flag.Float64Var(&tracingSampleRatio, "tracing-sample-ratio", algorithm.DefaultTracingSampleRatio, "Fraction of scheduling cycles and recoveries to trace")
+    flag.StringVar(&adminAddress, "admin-address", "127.0.0.1:8081", "Address of the admin endpoints; anyone who can reach it can change link state and resume recovery")
+    flag.DurationVar(&configReloadInterval, "config-reload-interval", 10*time.Second, "How often to check the config file for changes")
+}
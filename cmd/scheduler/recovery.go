@@ -0,0 +1,93 @@
+// +build !generate
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/scheduler/algorithm"
+)
+
+// runRecoveryPlan implements the "recovery-plan" subcommand, which asks a
+// running scheduler what it would do if the given nodes or domain failed.
+func runRecoveryPlan(args []string) {
+    fs := flag.NewFlagSet("recovery-plan", flag.ExitOnError)
+    server := fs.String("server", "http://localhost:8081", "Address of the scheduler admin endpoint")
+    nodes := fs.String("nodes", "", "Comma-separated nodes to plan the failure of")
+    domain := fs.String("domain", "", "Domain to plan the failure of")
+    format := fs.String("format", "text", "Output format: text or json")
+    fs.Parse(args)
+
+    query := url.Values{}
+    for _, node := range strings.Split(*nodes, ",") {
+        if node = strings.TrimSpace(node); node != "" {
+            query.Add("node", node)
+        }
+    }
+    if *domain != "" {
+        query.Set("domain", *domain)
+    }
+    if len(query) == 0 {
+        klog.Fatalf("One of --nodes or --domain is required")
+    }
+
+    endpoint := fmt.Sprintf("%s/admin/recovery/plan?%s", *server, query.Encode())
+    client := &http.Client{Timeout: 30 * time.Second}
+
+    resp, err := client.Get(endpoint)
+    if err != nil {
+        klog.Fatalf("Error fetching recovery plan from %s: %v", *server, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        body, _ := io.ReadAll(resp.Body)
+        klog.Fatalf("Error fetching recovery plan: %s: %s", resp.Status, body)
+    }
+
+    if *format == "json" {
+        if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+            klog.Fatalf("Error writing recovery plan: %v", err)
+        }
+        return
+    }
+
+    var plan algorithm.RecoveryPlan
+    if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+        klog.Fatalf("Error decoding recovery plan: %v", err)
+    }
+    printRecoveryPlan(os.Stdout, &plan)
+}
+
+func printRecoveryPlan(out io.Writer, plan *algorithm.RecoveryPlan) {
+    fmt.Fprintf(out, "Failed nodes: %s\n", strings.Join(plan.FailedNodes, ", "))
+    fmt.Fprintf(out, "Pods to move: %d, topology degradation: %.1f\n\n", plan.MovedPods, plan.Degradation)
+
+    w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, "JOB\tPOLICY\tPOD\tGPUS\tFROM\tTO\tDISTANCE")
+    for _, job := range plan.Jobs {
+        if job.Error != "" {
+            fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t%s\n", job.Job, job.Policy, job.Error)
+            continue
+        }
+        for _, m := range job.Migrations {
+            to := m.TargetDomain
+            if m.SpareNode != "" {
+                to += " (spare " + m.SpareNode + ")"
+            }
+            fmt.Fprintf(w, "%s\t%s\t%s/%s\t%d\t%s\t%s\t%.1f\n",
+                job.Job, job.Policy, m.Namespace, m.Pod, m.GPUs, m.FromDomain, to, m.Distance)
+        }
+    }
+    w.Flush()
+}
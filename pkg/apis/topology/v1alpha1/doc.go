@@ -1,9 +1,9 @@
-// +k8s:deepcopy-gen=package
-// +k8s:defaulter-gen=TypeMeta
-// +k8s:conversion-gen=false
-// +k8s:openapi-gen=true
-// +k8s:generator-name=client
-// +groupName=topology.scheduler.k8s.io
-
-// Package v1alpha1 contains the v1alpha1 version of the topology scheduler API.
-package v1alpha1
+// +k8s:deepcopy-gen=package
+// +k8s:defaulter-gen=TypeMeta
+// +k8s:conversion-gen=false
+// +k8s:openapi-gen=true
+// +k8s:generator-name=client
+// +groupName=topology.scheduler.k8s.io
+
+// Package v1alpha1 contains the v1alpha1 version of the topology scheduler API.
+package v1alpha1
@@ -0,0 +1,136 @@
+package v1alpha1
+
+import (
+    "fmt"
+    "math"
+
+    batchv1 "k8s.io/api/batch/v1"
+)
+
+const (
+    // LabelLeafDomain is set on nodes to the name of the leaf domain they belong to
+    LabelLeafDomain = GroupName + "/leaf-domain"
+    // LabelSpineDomain is set on nodes to the name of the spine their leaf uplinks to
+    LabelSpineDomain = GroupName + "/spine-domain"
+
+    // AnnotationConfigGeneration records the TopologySchedulerConfig generation
+    // a rendered scheduler ConfigMap was produced from
+    AnnotationConfigGeneration = GroupName + "/config-generation"
+
+    // AnnotationPreferredDomain asks the scheduler to favor a domain for a pod.
+    // Recovery sets it on replacements of evicted pods. It keeps the prefix
+    // documented for workload annotations.
+    AnnotationPreferredDomain = "topology.scheduler/preferred-domain"
+
+    // AnnotationRecoveryPolicy selects how a job is recovered when one of its
+    // pods is lost to a node failure
+    AnnotationRecoveryPolicy = "topology.scheduler/recovery-policy"
+
+    // RecoveryPolicyReplaceMember replaces only the lost pods, near their old domain
+    RecoveryPolicyReplaceMember = "replace-member"
+    // RecoveryPolicyRestartJob evicts every pod of the job so it is placed
+    // again on a fresh, topology-aligned set of nodes
+    RecoveryPolicyRestartJob = "restart-job"
+
+    // TaintGPUUnhealthy marks a node whose GPUs have failed
+    TaintGPUUnhealthy = GroupName + "/gpu-unhealthy"
+    // TaintQuarantined keeps pods off a node whose health keeps flapping
+    TaintQuarantined = GroupName + "/quarantined"
+    // TaintSpare keeps pods off a hot spare, except the replacements it is claimed for
+    TaintSpare = GroupName + "/spare"
+
+    DomainTypeLeaf  = "leaf"
+    DomainTypeSpine = "spine"
+
+    // Domain lifecycle states, from least to most restrictive
+    DomainLifecycleActive      = "Active"
+    DomainLifecycleCordoned    = "Cordoned"
+    DomainLifecycleDraining    = "Draining"
+    DomainLifecycleMaintenance = "Maintenance"
+
+    // ConditionSynced reports whether an object has been applied to the cluster
+    ConditionSynced = "Synced"
+    // ConditionDrained reports whether a draining domain is empty and safe to service
+    ConditionDrained = "Drained"
+    // ConditionReady reports whether a scheduler leader is reporting into a TopologyScheduler
+    ConditionReady = "Ready"
+    // ConditionDegraded reports whether any reported domain is not Healthy or Active
+    ConditionDegraded = "Degraded"
+    // ConditionRecoveryPaused reports whether automatic recovery is paused
+    ConditionRecoveryPaused = "RecoveryPaused"
+
+    // ConditionJobRecovery is set on a Job recovered after a node failure
+    ConditionJobRecovery batchv1.JobConditionType = GroupName + "/JobRecovery"
+
+    // Placement results written on GPU pods when they are bound
+    AnnotationPlacedStrategy = "topology.scheduler/placed-strategy"
+    AnnotationPlacedLeaf     = "topology.scheduler/placed-leaf"
+    AnnotationPlacedSpine    = "topology.scheduler/placed-spine"
+    // AnnotationPlacedScore is the mean domain score of the job's pods, 0 to 100
+    AnnotationPlacedScore = "topology.scheduler/placed-score"
+    // AnnotationPlacedMaxHops is the most switch links between any two domains of the job
+    AnnotationPlacedMaxHops = "topology.scheduler/placed-max-hops"
+    // AnnotationPlacedPeers lists the nodes of the job's other pods, comma-separated
+    AnnotationPlacedPeers = "topology.scheduler/placed-peers"
+)
+
+// weightTolerance is how far the weights may sum from 1.0 and still be considered normalized
+const weightTolerance = 0.001
+
+// Validate checks that all weights are non-negative and sum to 1
+func (w *ScoringWeights) Validate() error {
+    weights := map[string]float64{
+        "resourceAvailability":  w.ResourceAvailability,
+        "topologyAlignment":     w.TopologyAlignment,
+        "domainUtilization":     w.DomainUtilization,
+        "historicalPerformance": w.HistoricalPerformance,
+    }
+
+    var sum float64
+    for name, weight := range weights {
+        if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+            return fmt.Errorf("scoring weight %s must be a non-negative number, got %v", name, weight)
+        }
+        sum += weight
+    }
+
+    if math.Abs(sum-1.0) > weightTolerance {
+        return fmt.Errorf("scoring weights must sum to 1.0, got %.3f", sum)
+    }
+    return nil
+}
+
+// Validate checks that the topology constraints are non-negative
+func (c *TopologyConstraints) Validate() error {
+    if c.MaxNodesPerLeaf < 0 {
+        return fmt.Errorf("maxNodesPerLeaf must be non-negative, got %d", c.MaxNodesPerLeaf)
+    }
+    if c.MaxGPUsPerLeaf < 0 {
+        return fmt.Errorf("maxGPUsPerLeaf must be non-negative, got %d", c.MaxGPUsPerLeaf)
+    }
+    return nil
+}
+
+func (p *SparePoolPolicy) Validate() error {
+    if p.NodesPerLeaf < 0 {
+        return fmt.Errorf("nodesPerLeaf must be non-negative, got %d", p.NodesPerLeaf)
+    }
+    if p.NodesPerSpine < 0 {
+        return fmt.Errorf("nodesPerSpine must be non-negative, got %d", p.NodesPerSpine)
+    }
+    return nil
+}
+
+// Validate checks that the health fractions are in [0, 1] and the window is non-negative
+func (p *HealthPolicy) Validate() error {
+    if p.MinDomainHealth < 0 || p.MinDomainHealth > 1 || math.IsNaN(p.MinDomainHealth) {
+        return fmt.Errorf("minDomainHealth must be between 0 and 1, got %v", p.MinDomainHealth)
+    }
+    if p.FlapPenalty < 0 || p.FlapPenalty > 1 || math.IsNaN(p.FlapPenalty) {
+        return fmt.Errorf("flapPenalty must be between 0 and 1, got %v", p.FlapPenalty)
+    }
+    if p.FlapWindowSeconds < 0 {
+        return fmt.Errorf("flapWindowSeconds must be non-negative, got %d", p.FlapWindowSeconds)
+    }
+    return nil
+}
@@ -1,46 +1,50 @@
-package v1alpha1
-
-import (
-    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-    "k8s.io/apimachinery/pkg/runtime"
-    "k8s.io/apimachinery/pkg/runtime/schema"
-)
-
-const (
-    GroupName = "topology.scheduler.k8s.io"
-    Version   = "v1alpha1"
-)
-
-var (
-    // SchemeGroupVersion is the group version used to register these objects
-    SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
-
-    // SchemeBuilder and AddToScheme will stay in k8s.io/kubernetes.
-    SchemeBuilder      runtime.SchemeBuilder
-    localSchemeBuilder = &SchemeBuilder
-    AddToScheme       = localSchemeBuilder.AddToScheme
-)
-
-func init() {
-    // We only register manually written functions here. The registration of the
-    // generated functions takes place in the generated files. The separation
-    // makes the code compile even when the generated files are missing.
-    localSchemeBuilder.Register(addKnownTypes)
-}
-
-// Resource takes an unqualified resource and returns a Group qualified GroupResource
-func Resource(resource string) schema.GroupResource {
-    return SchemeGroupVersion.WithResource(resource).GroupResource()
-}
-
-// Adds the list of known types to the given scheme.
-func addKnownTypes(scheme *runtime.Scheme) error {
-    scheme.AddKnownTypes(
-        SchemeGroupVersion,
-        &TopologyScheduler{},
-        &TopologySchedulerList{},
-    )
-
-    metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
-    return nil
-}
+package v1alpha1
+
+import (
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/runtime"
+    "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+    GroupName = "topology.scheduler.k8s.io"
+    Version   = "v1alpha1"
+)
+
+var (
+    // SchemeGroupVersion is the group version used to register these objects
+    SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: Version}
+
+    // SchemeBuilder and AddToScheme will stay in k8s.io/kubernetes.
+    SchemeBuilder      runtime.SchemeBuilder
+    localSchemeBuilder = &SchemeBuilder
+    AddToScheme       = localSchemeBuilder.AddToScheme
+)
+
+func init() {
+    // We only register manually written functions here. The registration of the
+    // generated functions takes place in the generated files. The separation
+    // makes the code compile even when the generated files are missing.
+    localSchemeBuilder.Register(addKnownTypes)
+}
+
+// Resource takes an unqualified resource and returns a Group qualified GroupResource
+func Resource(resource string) schema.GroupResource {
+    return SchemeGroupVersion.WithResource(resource).GroupResource()
+}
+
+// Adds the list of known types to the given scheme.
+func addKnownTypes(scheme *runtime.Scheme) error {
+    scheme.AddKnownTypes(
+        SchemeGroupVersion,
+        &TopologyScheduler{},
+        &TopologySchedulerList{},
+        &TopologySchedulerConfig{},
+        &TopologySchedulerConfigList{},
+        &DomainConfig{},
+        &DomainConfigList{},
+    )
+
+    metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+    return nil
+}
@@ -1,43 +1,211 @@
-package v1alpha1
-
-import (
-    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-)
-
-// +genclient
-// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
-// +k8s:defaulter-gen=true
-// +kubebuilder:resource:scope=Namespaced
-
-// TopologyScheduler is a specification for a TopologyScheduler resource
-type TopologyScheduler struct {
-    metav1.TypeMeta   `json:",inline"`
-    metav1.ObjectMeta `json:"metadata,omitempty"`
-    Spec   TopologySchedulerSpec   `json:"spec"`
-    Status TopologySchedulerStatus `json:"status"`
-}
-
-// TopologySchedulerSpec is the spec for a TopologyScheduler resource
-type TopologySchedulerSpec struct {
-    // Add your scheduler-specific fields here
-    GPUCount    int32  `json:"gpuCount"`
-    Domain      string `json:"domain"`
-    Utilization int32  `json:"utilization"`
-}
-
-// TopologySchedulerStatus is the status for a TopologyScheduler resource
-type TopologySchedulerStatus struct {
-    // Add status fields here
-    Phase      string `json:"phase"`
-    Message    string `json:"message"`
-    LastUpdate string `json:"lastUpdate"`
-}
-
-// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
-
-// TopologySchedulerList is a list of TopologyScheduler resources
-type TopologySchedulerList struct {
-    metav1.TypeMeta `json:",inline"`
-    metav1.ListMeta `json:"metadata"`
-    Items []TopologyScheduler `json:"items"`
-}
+package v1alpha1
+
+import (
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +k8s:defaulter-gen=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+
+// TopologyScheduler reports the state of a running topology scheduler: its
+// domains' capacity, usage and health and the config it applies
+type TopologyScheduler struct {
+    metav1.TypeMeta   `json:",inline"`
+    metav1.ObjectMeta `json:"metadata,omitempty"`
+    Spec   TopologySchedulerSpec   `json:"spec"`
+    Status TopologySchedulerStatus `json:"status,omitempty"`
+}
+
+// TopologySchedulerSpec selects what a TopologyScheduler reports
+type TopologySchedulerSpec struct {
+    // SchedulerName is the scheduler reporting into this object. Empty means
+    // topology-aware-scheduler.
+    SchedulerName string `json:"schedulerName,omitempty"`
+    // Domains limits the report to these leaf domains and the leaves under
+    // these spines. Empty reports every domain.
+    Domains []string `json:"domains,omitempty"`
+}
+
+// TopologySchedulerStatus is the state of the scheduler as last reported by its leader
+type TopologySchedulerStatus struct {
+    ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+    // ConfigGeneration is the generation of the scheduler config in effect
+    ConfigGeneration int64 `json:"configGeneration,omitempty"`
+    // ConfigSource is where that config was loaded from: file, crd or default
+    ConfigSource string `json:"configSource,omitempty"`
+    // TotalGPUs and UsedGPUs sum the reported domains
+    TotalGPUs int32 `json:"totalGPUs"`
+    UsedGPUs  int32 `json:"usedGPUs"`
+    // HealthyDomains counts the reported domains whose health is Healthy
+    HealthyDomains int32              `json:"healthyDomains"`
+    Domains        []DomainSummary    `json:"domains,omitempty"`
+    Conditions     []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// DomainSummary is the capacity, usage and health of one leaf domain
+type DomainSummary struct {
+    Name  string `json:"name"`
+    Spine string `json:"spine,omitempty"`
+    Nodes int32  `json:"nodes"`
+    // HealthyNodes are Ready and free of pressure conditions and failure taints
+    HealthyNodes int32 `json:"healthyNodes"`
+    // TotalGPUs excludes unhealthy devices and reserved spares
+    TotalGPUs int32 `json:"totalGPUs"`
+    UsedGPUs  int32 `json:"usedGPUs"`
+    // Health is Healthy, Flapping, Degraded or Unhealthy
+    Health    string `json:"health"`
+    LinkState string `json:"linkState,omitempty"`
+    Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TopologySchedulerList is a list of TopologyScheduler resources
+type TopologySchedulerList struct {
+    metav1.TypeMeta `json:",inline"`
+    metav1.ListMeta `json:"metadata"`
+    Items []TopologyScheduler `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+
+// TopologySchedulerConfig holds the scoring weights and topology constraints
+// used by the scheduler
+type TopologySchedulerConfig struct {
+    metav1.TypeMeta   `json:",inline"`
+    metav1.ObjectMeta `json:"metadata,omitempty"`
+    Spec   TopologySchedulerConfigSpec   `json:"spec"`
+    Status TopologySchedulerConfigStatus `json:"status,omitempty"`
+}
+
+// TopologySchedulerConfigSpec is the spec for a TopologySchedulerConfig resource
+type TopologySchedulerConfigSpec struct {
+    ScoringWeights      ScoringWeights      `json:"scoringWeights"`
+    TopologyConstraints TopologyConstraints `json:"topologyConstraints,omitempty"`
+    HealthPolicy        HealthPolicy        `json:"healthPolicy,omitempty"`
+    SparePool           SparePoolPolicy     `json:"sparePool,omitempty"`
+}
+
+// ScoringWeights are the relative weights of each domain scoring component
+type ScoringWeights struct {
+    ResourceAvailability  float64 `json:"resourceAvailability"`
+    TopologyAlignment     float64 `json:"topologyAlignment"`
+    DomainUtilization     float64 `json:"domainUtilization"`
+    // HistoricalPerformance weights the share of the domain's nodes that are
+    // healthy. No record of past job performance is kept.
+    HistoricalPerformance float64 `json:"historicalPerformance"`
+}
+
+// TopologyConstraints bound how much of a single leaf domain a job may use.
+// The scheduler filters out nodes that would take a job past either limit;
+// zero means no limit.
+type TopologyConstraints struct {
+    MaxNodesPerLeaf int32 `json:"maxNodesPerLeaf,omitempty"`
+    MaxGPUsPerLeaf  int32 `json:"maxGPUsPerLeaf,omitempty"`
+}
+
+// HealthPolicy controls how node and domain health affect placement
+type HealthPolicy struct {
+    // MinDomainHealth is the fraction of healthy nodes a domain needs before
+    // jobs spanning more than one node are placed in it
+    MinDomainHealth float64 `json:"minDomainHealth,omitempty"`
+    // FlapWindowSeconds is how long a node health transition counts as recent
+    FlapWindowSeconds int32 `json:"flapWindowSeconds,omitempty"`
+    // FlapPenalty is the fraction of a domain's score removed per recent transition
+    FlapPenalty float64 `json:"flapPenalty,omitempty"`
+}
+
+// SparePoolPolicy reserves idle nodes that normal scheduling never uses, so
+// recovery can replace a failed node without leaving its domain
+type SparePoolPolicy struct {
+    // NodesPerLeaf is how many spare nodes each leaf domain keeps
+    NodesPerLeaf int32 `json:"nodesPerLeaf,omitempty"`
+    // NodesPerSpine is how many spare nodes the leaves under each spine keep together
+    NodesPerSpine int32 `json:"nodesPerSpine,omitempty"`
+}
+
+// TopologySchedulerConfigStatus is the status for a TopologySchedulerConfig resource
+type TopologySchedulerConfigStatus struct {
+    ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+    Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TopologySchedulerConfigList is a list of TopologySchedulerConfig resources
+type TopologySchedulerConfigList struct {
+    metav1.TypeMeta `json:",inline"`
+    metav1.ListMeta `json:"metadata"`
+    Items []TopologySchedulerConfig `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// DomainConfig declares a network domain and the nodes that belong to it
+type DomainConfig struct {
+    metav1.TypeMeta   `json:",inline"`
+    metav1.ObjectMeta `json:"metadata,omitempty"`
+    Spec   DomainConfigSpec   `json:"spec"`
+    Status DomainConfigStatus `json:"status,omitempty"`
+}
+
+// DomainConfigSpec is the spec for a DomainConfig resource
+type DomainConfigSpec struct {
+    // Type is either "leaf" or "spine"
+    Type string `json:"type"`
+    // Parent is the spine domain a leaf domain is uplinked to
+    Parent string `json:"parent,omitempty"`
+    LeafSwitch  string `json:"leafSwitch,omitempty"`
+    SpineSwitch string `json:"spineSwitch,omitempty"`
+    // Bandwidth of the domain uplink in Gbps
+    Bandwidth int64 `json:"bandwidth,omitempty"`
+    // Nodes and NodeSelector select the members of a leaf domain. Both may be set.
+    Nodes        []string              `json:"nodes,omitempty"`
+    NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+    // Links overrides the state of links from this domain, e.g. to take a
+    // failed uplink out of service
+    Links []LinkSpec `json:"links,omitempty"`
+    // Lifecycle is Active, Cordoned, Draining or Maintenance. On a spine it
+    // applies to every leaf under it.
+    Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// LinkSpec overrides the state of one of a domain's links
+type LinkSpec struct {
+    // Peer is the spine or domain at the other end of the link
+    Peer string `json:"peer"`
+    // State is Up, Degraded or Down
+    State         string  `json:"state"`
+    CapacityGbps  int64   `json:"capacityGbps,omitempty"`
+    LatencyMicros float64 `json:"latencyMicros,omitempty"`
+    Reason        string  `json:"reason,omitempty"`
+}
+
+// DomainConfigStatus is the status for a DomainConfig resource
+type DomainConfigStatus struct {
+    ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+    Nodes              []string           `json:"nodes,omitempty"`
+    TotalGPUs          int32              `json:"totalGPUs"`
+    // Lifecycle is the state the scheduler applies, including node annotations
+    Lifecycle  string             `json:"lifecycle,omitempty"`
+    Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DomainConfigList is a list of DomainConfig resources
+type DomainConfigList struct {
+    metav1.TypeMeta `json:",inline"`
+    metav1.ListMeta `json:"metadata"`
+    Items []DomainConfig `json:"items"`
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+    "fmt"
+    "time"
+
+    utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/client-go/kubernetes"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/client-go/util/workqueue"
+    "k8s.io/klog/v2"
+
+    clientset "github.com/nod-ai/topology-aware-scheduler/pkg/generated/clientset/versioned"
+    informers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/informers/externalversions/topology/v1alpha1"
+    listers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/listers/topology/v1alpha1"
+)
+
+const (
+    // SchedulerConfigNamespace and SchedulerConfigName identify the ConfigMap
+    // the scheduler reads its SchedulerConfig from
+    SchedulerConfigNamespace = "kube-system"
+    SchedulerConfigName      = "topology-scheduler-config"
+    SchedulerConfigKey       = "config.yaml"
+
+    maxRetries = 5
+)
+
+// Controller reconciles TopologySchedulerConfig and DomainConfig objects into
+// the scheduler ConfigMap and topology labels on nodes
+type Controller struct {
+    kubeClient     kubernetes.Interface
+    topologyClient clientset.Interface
+
+    configLister  listers.TopologySchedulerConfigLister
+    configsSynced cache.InformerSynced
+    domainLister  listers.DomainConfigLister
+    domainsSynced cache.InformerSynced
+
+    configQueue workqueue.RateLimitingInterface
+    domainQueue workqueue.RateLimitingInterface
+}
+
+func NewController(
+    kubeClient kubernetes.Interface,
+    topologyClient clientset.Interface,
+    configInformer informers.TopologySchedulerConfigInformer,
+    domainInformer informers.DomainConfigInformer,
+) *Controller {
+    c := &Controller{
+        kubeClient:     kubeClient,
+        topologyClient: topologyClient,
+        configLister:   configInformer.Lister(),
+        configsSynced:  configInformer.Informer().HasSynced,
+        domainLister:   domainInformer.Lister(),
+        domainsSynced:  domainInformer.Informer().HasSynced,
+        configQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "TopologySchedulerConfigs"),
+        domainQueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "DomainConfigs"),
+    }
+
+    configInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc:    func(obj interface{}) { c.enqueue(c.configQueue, obj) },
+        UpdateFunc: func(old, new interface{}) { c.enqueue(c.configQueue, new) },
+        DeleteFunc: func(obj interface{}) { c.enqueue(c.configQueue, obj) },
+    })
+
+    domainInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc:    func(obj interface{}) { c.enqueue(c.domainQueue, obj) },
+        UpdateFunc: func(old, new interface{}) { c.enqueue(c.domainQueue, new) },
+        DeleteFunc: func(obj interface{}) { c.enqueue(c.domainQueue, obj) },
+    })
+
+    return c
+}
+
+// Run waits for the informer caches to sync and starts workers for both
+// queues. It blocks until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+    defer utilruntime.HandleCrash()
+    defer c.configQueue.ShutDown()
+    defer c.domainQueue.ShutDown()
+
+    klog.Info("Starting topology controller")
+
+    if ok := cache.WaitForCacheSync(stopCh, c.configsSynced, c.domainsSynced); !ok {
+        return fmt.Errorf("failed to wait for caches to sync")
+    }
+
+    for i := 0; i < workers; i++ {
+        go wait.Until(func() { c.runWorker(c.configQueue, c.syncSchedulerConfig) }, time.Second, stopCh)
+        go wait.Until(func() { c.runWorker(c.domainQueue, c.syncDomainConfig) }, time.Second, stopCh)
+    }
+
+    klog.Info("Started topology controller workers")
+    <-stopCh
+    klog.Info("Shutting down topology controller")
+    return nil
+}
+
+func (c *Controller) enqueue(queue workqueue.RateLimitingInterface, obj interface{}) {
+    key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+    if err != nil {
+        utilruntime.HandleError(err)
+        return
+    }
+    queue.Add(key)
+}
+
+func (c *Controller) runWorker(queue workqueue.RateLimitingInterface, sync func(key string) error) {
+    for c.processNextItem(queue, sync) {
+    }
+}
+
+func (c *Controller) processNextItem(queue workqueue.RateLimitingInterface, sync func(key string) error) bool {
+    item, shutdown := queue.Get()
+    if shutdown {
+        return false
+    }
+    defer queue.Done(item)
+
+    key := item.(string)
+    err := sync(key)
+    if err == nil {
+        queue.Forget(item)
+        return true
+    }
+
+    if queue.NumRequeues(item) < maxRetries {
+        klog.Warningf("Error syncing %s, retrying: %v", key, err)
+        queue.AddRateLimited(item)
+        return true
+    }
+
+    queue.Forget(item)
+    utilruntime.HandleError(fmt.Errorf("dropping %s out of the queue: %v", key, err))
+    return true
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    corev1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/errors"
+    "k8s.io/apimachinery/pkg/api/meta"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+const gpuResourceName = corev1.ResourceName("nvidia.com/gpu")
+
+// syncDomainConfig reconciles a DomainConfig into topology labels on its member
+// nodes. Leaf domains label their nodes with the leaf and parent spine, nodes
+// that no longer match lose the labels, and deleting a leaf clears them.
+func (c *Controller) syncDomainConfig(key string) error {
+    domain, err := c.domainLister.Get(key)
+    if errors.IsNotFound(err) {
+        klog.Infof("DomainConfig %s deleted, removing it from its nodes", key)
+        _, err := c.reconcileLeafNodes(key, "", nil)
+        return err
+    }
+    if err != nil {
+        return err
+    }
+
+    domain = domain.DeepCopy()
+    condition := metav1.Condition{
+        Type:               v1alpha1.ConditionSynced,
+        Status:             metav1.ConditionTrue,
+        Reason:             "Reconciled",
+        ObservedGeneration: domain.Generation,
+    }
+
+    var syncErr error
+    switch domain.Spec.Type {
+    case v1alpha1.DomainTypeLeaf:
+        syncErr = c.syncLeafDomain(domain, &condition)
+    case v1alpha1.DomainTypeSpine:
+        syncErr = c.syncSpineDomain(domain, &condition)
+    default:
+        condition.Status = metav1.ConditionFalse
+        condition.Reason = "InvalidType"
+        condition.Message = fmt.Sprintf("domain type must be %q or %q, got %q",
+            v1alpha1.DomainTypeLeaf, v1alpha1.DomainTypeSpine, domain.Spec.Type)
+    }
+
+    domain.Status.ObservedGeneration = domain.Generation
+    meta.SetStatusCondition(&domain.Status.Conditions, condition)
+
+    _, err = c.topologyClient.TopologyV1alpha1().DomainConfigs().UpdateStatus(
+        context.TODO(), domain, metav1.UpdateOptions{})
+    if err != nil {
+        return fmt.Errorf("failed to update status of DomainConfig %s: %v", key, err)
+    }
+    return syncErr
+}
+
+func (c *Controller) syncLeafDomain(domain *v1alpha1.DomainConfig, condition *metav1.Condition) error {
+    if parent := domain.Spec.Parent; parent != "" {
+        spine, err := c.domainLister.Get(parent)
+        if err != nil || spine.Spec.Type != v1alpha1.DomainTypeSpine {
+            condition.Status = metav1.ConditionFalse
+            condition.Reason = "ParentNotFound"
+            condition.Message = fmt.Sprintf("parent %s is not a spine DomainConfig", parent)
+            return nil
+        }
+    }
+
+    desired, err := c.selectDomainNodes(domain)
+    if err != nil {
+        condition.Status = metav1.ConditionFalse
+        condition.Reason = "InvalidSelector"
+        condition.Message = err.Error()
+        return nil
+    }
+
+    conflicts := c.findConflicts(domain.Name, desired)
+    for _, node := range conflicts {
+        delete(desired, node)
+    }
+
+    members, err := c.reconcileLeafNodes(domain.Name, domain.Spec.Parent, desired)
+    if err != nil {
+        condition.Status = metav1.ConditionFalse
+        condition.Reason = "LabelFailed"
+        condition.Message = err.Error()
+        return err
+    }
+
+    domain.Status.Nodes = nil
+    domain.Status.TotalGPUs = 0
+    for _, node := range members {
+        domain.Status.Nodes = append(domain.Status.Nodes, node.Name)
+        if gpus, ok := node.Status.Allocatable[gpuResourceName]; ok {
+            domain.Status.TotalGPUs += int32(gpus.Value())
+        }
+    }
+    sort.Strings(domain.Status.Nodes)
+
+    if len(conflicts) > 0 {
+        condition.Status = metav1.ConditionFalse
+        condition.Reason = "NodeConflict"
+        condition.Message = fmt.Sprintf("nodes already belong to another leaf domain: %s", strings.Join(conflicts, ", "))
+        return nil
+    }
+    condition.Message = fmt.Sprintf("%d nodes in domain", len(members))
+    return nil
+}
+
+func (c *Controller) syncSpineDomain(domain *v1alpha1.DomainConfig, condition *metav1.Condition) error {
+    selector := labels.SelectorFromSet(labels.Set{v1alpha1.LabelSpineDomain: domain.Name})
+    nodes, err := c.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+    if err != nil {
+        return err
+    }
+
+    domain.Status.Nodes = nil
+    domain.Status.TotalGPUs = 0
+    for _, node := range nodes.Items {
+        domain.Status.Nodes = append(domain.Status.Nodes, node.Name)
+        if gpus, ok := node.Status.Allocatable[gpuResourceName]; ok {
+            domain.Status.TotalGPUs += int32(gpus.Value())
+        }
+    }
+    sort.Strings(domain.Status.Nodes)
+    condition.Message = fmt.Sprintf("%d nodes under spine", len(nodes.Items))
+    return nil
+}
+
+// selectDomainNodes returns the names of the nodes listed in or selected by the domain spec
+func (c *Controller) selectDomainNodes(domain *v1alpha1.DomainConfig) (map[string]bool, error) {
+    desired := make(map[string]bool)
+    for _, name := range domain.Spec.Nodes {
+        desired[name] = true
+    }
+
+    if domain.Spec.NodeSelector == nil {
+        return desired, nil
+    }
+
+    selector, err := metav1.LabelSelectorAsSelector(domain.Spec.NodeSelector)
+    if err != nil {
+        return nil, fmt.Errorf("invalid node selector: %v", err)
+    }
+    nodes, err := c.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+    if err != nil {
+        return nil, err
+    }
+    for _, node := range nodes.Items {
+        desired[node.Name] = true
+    }
+    return desired, nil
+}
+
+// findConflicts returns the desired nodes that are already labeled with a
+// different leaf domain that still exists
+func (c *Controller) findConflicts(domainName string, desired map[string]bool) []string {
+    var conflicts []string
+    for name := range desired {
+        node, err := c.kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+        if err != nil {
+            continue
+        }
+        current, ok := node.Labels[v1alpha1.LabelLeafDomain]
+        if !ok || current == domainName {
+            continue
+        }
+        if _, err := c.domainLister.Get(current); err == nil {
+            conflicts = append(conflicts, name)
+        }
+    }
+    sort.Strings(conflicts)
+    return conflicts
+}
+
+// reconcileLeafNodes labels every desired node with the leaf and spine domain
+// and strips the labels from nodes that carry the leaf label but are no longer
+// desired. It returns the nodes that are members once labeling succeeds.
+func (c *Controller) reconcileLeafNodes(domainName, spineName string, desired map[string]bool) ([]*corev1.Node, error) {
+    selector := labels.SelectorFromSet(labels.Set{v1alpha1.LabelLeafDomain: domainName})
+    current, err := c.kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+    if err != nil {
+        return nil, err
+    }
+
+    for _, node := range current.Items {
+        if desired[node.Name] {
+            continue
+        }
+        if err := c.patchNodeLabels(node.Name, nil); err != nil {
+            return nil, err
+        }
+        klog.V(2).Infof("Removed node %s from leaf domain %s", node.Name, domainName)
+    }
+
+    var members []*corev1.Node
+    for name := range desired {
+        domainLabels := map[string]interface{}{
+            v1alpha1.LabelLeafDomain:  domainName,
+            v1alpha1.LabelSpineDomain: nil,
+        }
+        if spineName != "" {
+            domainLabels[v1alpha1.LabelSpineDomain] = spineName
+        }
+
+        node, err := c.kubeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+        if errors.IsNotFound(err) {
+            klog.Warningf("Node %s listed in leaf domain %s does not exist", name, domainName)
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        if node.Labels[v1alpha1.LabelLeafDomain] != domainName || node.Labels[v1alpha1.LabelSpineDomain] != spineName {
+            if err := c.patchNodeLabels(name, domainLabels); err != nil {
+                return nil, err
+            }
+            klog.V(2).Infof("Added node %s to leaf domain %s", name, domainName)
+        }
+        members = append(members, node)
+    }
+    return members, nil
+}
+
+// patchNodeLabels sets the topology labels on a node; nil removes both labels
+func (c *Controller) patchNodeLabels(nodeName string, domainLabels map[string]interface{}) error {
+    if domainLabels == nil {
+        domainLabels = map[string]interface{}{
+            v1alpha1.LabelLeafDomain:  nil,
+            v1alpha1.LabelSpineDomain: nil,
+        }
+    }
+
+    patch, err := json.Marshal(map[string]interface{}{
+        "metadata": map[string]interface{}{"labels": domainLabels},
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = c.kubeClient.CoreV1().Nodes().Patch(context.TODO(), nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+    if err != nil {
+        return fmt.Errorf("failed to patch labels on node %s: %v", nodeName, err)
+    }
+    return nil
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+
+    corev1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/errors"
+    "k8s.io/apimachinery/pkg/api/meta"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/klog/v2"
+    "sigs.k8s.io/yaml"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// SchedulerConfigFile is the document rendered into the scheduler ConfigMap
+type SchedulerConfigFile struct {
+    APIVersion string                               `json:"apiVersion"`
+    Kind       string                               `json:"kind"`
+    Metadata   SchedulerConfigMetadata              `json:"metadata"`
+    Spec       v1alpha1.TopologySchedulerConfigSpec `json:"spec"`
+}
+
+// SchedulerConfigMetadata is the part of the object metadata the scheduler
+// reads. A full ObjectMeta would render fields its strict decoding rejects.
+type SchedulerConfigMetadata struct {
+    Name       string `json:"name"`
+    Generation int64  `json:"generation,omitempty"`
+}
+
+// syncSchedulerConfig validates a TopologySchedulerConfig and, if it is the
+// active config, renders it into the ConfigMap the scheduler watches. Only the
+// config named after the scheduler ConfigMap in its namespace is active.
+func (c *Controller) syncSchedulerConfig(key string) error {
+    namespace, name, err := cache.SplitMetaNamespaceKey(key)
+    if err != nil {
+        return err
+    }
+
+    config, err := c.configLister.TopologySchedulerConfigs(namespace).Get(name)
+    if errors.IsNotFound(err) {
+        klog.Infof("TopologySchedulerConfig %s deleted, scheduler keeps its last applied config", key)
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    config = config.DeepCopy()
+    condition := metav1.Condition{
+        Type:               v1alpha1.ConditionSynced,
+        Status:             metav1.ConditionTrue,
+        Reason:             "Applied",
+        Message:            fmt.Sprintf("rendered into ConfigMap %s/%s", SchedulerConfigNamespace, SchedulerConfigName),
+        ObservedGeneration: config.Generation,
+    }
+
+    var syncErr error
+    switch {
+    case namespace != SchedulerConfigNamespace || name != SchedulerConfigName:
+        condition.Status = metav1.ConditionFalse
+        condition.Reason = "Inactive"
+        condition.Message = fmt.Sprintf("only %s/%s is applied to the scheduler", SchedulerConfigNamespace, SchedulerConfigName)
+    default:
+        if err := validateSchedulerConfig(&config.Spec); err != nil {
+            condition.Status = metav1.ConditionFalse
+            condition.Reason = "InvalidConfig"
+            condition.Message = err.Error()
+        } else if err := c.applySchedulerConfig(config); err != nil {
+            condition.Status = metav1.ConditionFalse
+            condition.Reason = "ApplyFailed"
+            condition.Message = err.Error()
+            syncErr = err
+        }
+    }
+
+    config.Status.ObservedGeneration = config.Generation
+    meta.SetStatusCondition(&config.Status.Conditions, condition)
+
+    _, err = c.topologyClient.TopologyV1alpha1().TopologySchedulerConfigs(namespace).UpdateStatus(
+        context.TODO(), config, metav1.UpdateOptions{})
+    if err != nil {
+        return fmt.Errorf("failed to update status of %s: %v", key, err)
+    }
+    return syncErr
+}
+
+func validateSchedulerConfig(spec *v1alpha1.TopologySchedulerConfigSpec) error {
+    if err := spec.ScoringWeights.Validate(); err != nil {
+        return err
+    }
+    if err := spec.TopologyConstraints.Validate(); err != nil {
+        return err
+    }
+    if err := spec.SparePool.Validate(); err != nil {
+        return err
+    }
+    return spec.HealthPolicy.Validate()
+}
+
+// renderSchedulerConfig renders the SchedulerConfig document the scheduler
+// loads from the ConfigMap
+func renderSchedulerConfig(config *v1alpha1.TopologySchedulerConfig) ([]byte, error) {
+    data, err := yaml.Marshal(&SchedulerConfigFile{
+        APIVersion: v1alpha1.SchemeGroupVersion.String(),
+        Kind:       "SchedulerConfig",
+        Metadata:   SchedulerConfigMetadata{Name: config.Name, Generation: config.Generation},
+        Spec:       config.Spec,
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to render scheduler config: %v", err)
+    }
+    return data, nil
+}
+
+func (c *Controller) applySchedulerConfig(config *v1alpha1.TopologySchedulerConfig) error {
+    data, err := renderSchedulerConfig(config)
+    if err != nil {
+        return err
+    }
+
+    generation := strconv.FormatInt(config.Generation, 10)
+    configMaps := c.kubeClient.CoreV1().ConfigMaps(SchedulerConfigNamespace)
+
+    cm, err := configMaps.Get(context.TODO(), SchedulerConfigName, metav1.GetOptions{})
+    if errors.IsNotFound(err) {
+        cm = &corev1.ConfigMap{
+            ObjectMeta: metav1.ObjectMeta{
+                Name:        SchedulerConfigName,
+                Namespace:   SchedulerConfigNamespace,
+                Annotations: map[string]string{v1alpha1.AnnotationConfigGeneration: generation},
+            },
+            Data: map[string]string{SchedulerConfigKey: string(data)},
+        }
+        _, err = configMaps.Create(context.TODO(), cm, metav1.CreateOptions{})
+        return err
+    }
+    if err != nil {
+        return err
+    }
+
+    if cm.Data[SchedulerConfigKey] == string(data) &&
+        cm.Annotations[v1alpha1.AnnotationConfigGeneration] == generation {
+        return nil
+    }
+
+    cm = cm.DeepCopy()
+    if cm.Data == nil {
+        cm.Data = make(map[string]string)
+    }
+    if cm.Annotations == nil {
+        cm.Annotations = make(map[string]string)
+    }
+    cm.Data[SchedulerConfigKey] = string(data)
+    cm.Annotations[v1alpha1.AnnotationConfigGeneration] = generation
+
+    _, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+    if err == nil {
+        klog.Infof("Applied TopologySchedulerConfig %s/%s generation %s", config.Namespace, config.Name, generation)
+    }
+    return err
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/client-go/kubernetes/fake"
+    "sigs.k8s.io/yaml"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    "github.com/nod-ai/topology-aware-scheduler/pkg/scheduler/algorithm"
+)
+
+func testSchedulerConfig(generation int64) *v1alpha1.TopologySchedulerConfig {
+    return &v1alpha1.TopologySchedulerConfig{
+        ObjectMeta: metav1.ObjectMeta{
+            Name:       SchedulerConfigName,
+            Namespace:  SchedulerConfigNamespace,
+            Generation: generation,
+        },
+        Spec: v1alpha1.TopologySchedulerConfigSpec{
+            ScoringWeights: v1alpha1.ScoringWeights{
+                ResourceAvailability:  0.5,
+                TopologyAlignment:     0.3,
+                DomainUtilization:     0.2,
+                HistoricalPerformance: 0,
+            },
+            TopologyConstraints: v1alpha1.TopologyConstraints{MaxNodesPerLeaf: 4},
+        },
+    }
+}
+
+func TestValidateSchedulerConfig(t *testing.T) {
+    tests := []struct {
+        name    string
+        mutate  func(spec *v1alpha1.TopologySchedulerConfigSpec)
+        wantErr bool
+    }{
+        {
+            name:   "valid",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {},
+        },
+        {
+            name: "weights do not sum to one",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {
+                spec.ScoringWeights.ResourceAvailability = 0.9
+            },
+            wantErr: true,
+        },
+        {
+            name: "negative weight",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {
+                spec.ScoringWeights.ResourceAvailability = 0.7
+                spec.ScoringWeights.HistoricalPerformance = -0.2
+            },
+            wantErr: true,
+        },
+        {
+            name: "negative leaf constraint",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {
+                spec.TopologyConstraints.MaxGPUsPerLeaf = -1
+            },
+            wantErr: true,
+        },
+        {
+            name: "negative spare pool",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {
+                spec.SparePool.NodesPerLeaf = -1
+            },
+            wantErr: true,
+        },
+        {
+            name: "health threshold above one",
+            mutate: func(spec *v1alpha1.TopologySchedulerConfigSpec) {
+                spec.HealthPolicy.MinDomainHealth = 1.5
+            },
+            wantErr: true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            spec := testSchedulerConfig(1).Spec
+            tt.mutate(&spec)
+            if err := validateSchedulerConfig(&spec); (err != nil) != tt.wantErr {
+                t.Errorf("validateSchedulerConfig() = %v, want error %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestApplySchedulerConfig(t *testing.T) {
+    client := fake.NewSimpleClientset()
+    c := &Controller{kubeClient: client}
+
+    if err := c.applySchedulerConfig(testSchedulerConfig(1)); err != nil {
+        t.Fatalf("failed to create the ConfigMap: %v", err)
+    }
+    cm, err := client.CoreV1().ConfigMaps(SchedulerConfigNamespace).Get(context.Background(), SchedulerConfigName, metav1.GetOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := cm.Annotations[v1alpha1.AnnotationConfigGeneration]; got != "1" {
+        t.Errorf("generation annotation = %q, want 1", got)
+    }
+    var file SchedulerConfigFile
+    if err := yaml.Unmarshal([]byte(cm.Data[SchedulerConfigKey]), &file); err != nil {
+        t.Fatalf("rendered config does not parse: %v", err)
+    }
+    if file.Kind != "SchedulerConfig" || file.Spec != testSchedulerConfig(1).Spec {
+        t.Errorf("rendered config is %+v, want the TopologySchedulerConfig spec", file)
+    }
+
+    // An unchanged config is not written again
+    client.ClearActions()
+    if err := c.applySchedulerConfig(testSchedulerConfig(1)); err != nil {
+        t.Fatal(err)
+    }
+    for _, action := range client.Actions() {
+        if action.GetVerb() == "update" {
+            t.Errorf("unchanged config updated the ConfigMap")
+        }
+    }
+
+    config := testSchedulerConfig(2)
+    config.Spec.TopologyConstraints.MaxNodesPerLeaf = 8
+    if err := c.applySchedulerConfig(config); err != nil {
+        t.Fatal(err)
+    }
+    cm, err = client.CoreV1().ConfigMaps(SchedulerConfigNamespace).Get(context.Background(), SchedulerConfigName, metav1.GetOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := cm.Annotations[v1alpha1.AnnotationConfigGeneration]; got != "2" {
+        t.Errorf("generation annotation after update = %q, want 2", got)
+    }
+}
+
+// The scheduler must load every config the controller renders
+func TestRenderedSchedulerConfigLoads(t *testing.T) {
+    config := testSchedulerConfig(3)
+    data, err := renderSchedulerConfig(config)
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(t.TempDir(), SchedulerConfigKey)
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    loaded, _, err := algorithm.LoadSchedulerConfigFile(path)
+    if err != nil {
+        t.Fatalf("scheduler rejected the rendered config: %v\n%s", err, data)
+    }
+    if loaded.Generation != 3 {
+        t.Errorf("generation = %d, want 3", loaded.Generation)
+    }
+    if loaded.Weights.ResourceAvailability != 0.5 || loaded.Constraints.MaxNodesPerLeaf != 4 {
+        t.Errorf("loaded config %+v does not match the rendered spec", loaded)
+    }
+}
+
+func TestLoadSchedulerConfigFileAPIVersion(t *testing.T) {
+    spec := `spec:
+  scoringWeights:
+    resourceAvailability: 0.4
+    topologyAlignment: 0.3
+    domainUtilization: 0.2
+    historicalPerformance: 0.1
+`
+    tests := []struct {
+        name    string
+        header  string
+        wantErr bool
+    }{
+        {name: "current group", header: "apiVersion: topology.scheduler.k8s.io/v1alpha1\nkind: SchedulerConfig\n"},
+        {name: "legacy group", header: "apiVersion: topology.scheduler/v1alpha1\nkind: SchedulerConfig\n"},
+        {name: "other group", header: "apiVersion: example.com/v1\nkind: SchedulerConfig\n", wantErr: true},
+        {name: "other kind", header: "apiVersion: topology.scheduler.k8s.io/v1alpha1\nkind: ConfigMap\n", wantErr: true},
+        {name: "unknown field", header: "apiVersion: topology.scheduler.k8s.io/v1alpha1\nkind: SchedulerConfig\nextra: 1\n", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), SchedulerConfigKey)
+            if err := os.WriteFile(path, []byte(tt.header+spec), 0644); err != nil {
+                t.Fatal(err)
+            }
+            _, _, err := algorithm.LoadSchedulerConfigFile(path)
+            if (err != nil) != tt.wantErr {
+                t.Errorf("LoadSchedulerConfigFile() = %v, want error %v", err, tt.wantErr)
+            }
+        })
+    }
+}
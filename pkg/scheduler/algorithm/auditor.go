@@ -0,0 +1,335 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/client-go/kubernetes"
+    "k8s.io/client-go/tools/record"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+const (
+    AuditCacheTopology  = "topology_cache"
+    AuditCacheNode      = "node_cache"
+    AuditCacheScheduler = "scheduler"
+    AuditCacheDomain    = "domain_manager"
+
+    AuditKindMembership = "membership"
+    AuditKindNodeGPUs   = "node_gpus"
+    AuditKindDomainGPUs = "domain_gpus"
+    AuditKindTotalGPUs  = "total_gpus"
+)
+
+// AuditMismatch is a single difference between an in-memory cache and the API server
+type AuditMismatch struct {
+    Cache    string
+    Kind     string
+    Object   string
+    Expected string
+    Actual   string
+}
+
+func (m AuditMismatch) String() string {
+    return fmt.Sprintf("%s %s %s: expected %s, got %s", m.Cache, m.Kind, m.Object, m.Expected, m.Actual)
+}
+
+// auditTruth is the topology state recomputed from the API server
+type auditTruth struct {
+    nodes       map[string]*v1.Node
+    nodeDomain  map[string]string
+    nodeGPUs    map[string]int
+    domainUsed  map[string]int
+    domainTotal map[string]int
+    boundPods   []*v1.Pod
+}
+
+// CacheAuditor periodically recomputes topology state from the API server and
+// diffs it against the scheduler's in-memory views
+type CacheAuditor struct {
+    mu            sync.Mutex
+    client        kubernetes.Interface
+    scheduler     *TopologyScheduler
+    domainManager *DomainManager
+    recorder      record.EventRecorder
+    selfHeal      bool
+    interval      time.Duration
+}
+
+func NewCacheAuditor(
+    client kubernetes.Interface,
+    scheduler *TopologyScheduler,
+    domainManager *DomainManager,
+    recorder record.EventRecorder,
+    selfHeal bool,
+    interval time.Duration,
+) *CacheAuditor {
+    return &CacheAuditor{
+        client:        client,
+        scheduler:     scheduler,
+        domainManager: domainManager,
+        recorder:      recorder,
+        selfHeal:      selfHeal,
+        interval:      interval,
+    }
+}
+
+func (a *CacheAuditor) Run(stopCh <-chan struct{}) {
+    wait.Until(func() {
+        if _, err := a.RunOnce(context.Background()); err != nil {
+            klog.Errorf("Cache audit failed: %v", err)
+        }
+    }, a.interval, stopCh)
+}
+
+// RunOnce audits every cache once and returns the mismatches found. With
+// self-heal enabled the topology cache and scheduler domains are corrected,
+// and the informer handlers are paused until the heal is done, so the truth
+// is not raced by updates applied while it is listed.
+func (a *CacheAuditor) RunOnce(ctx context.Context) ([]AuditMismatch, error) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    if a.selfHeal {
+        a.scheduler.cache.PauseEventHandlers()
+        defer a.scheduler.cache.ResumeEventHandlers()
+    }
+
+    cacheState := a.scheduler.cache.AuditState()
+
+    truth, err := a.computeTruth(ctx, cacheState)
+    if err != nil {
+        a.scheduler.metrics.ObserveCacheAudit(nil, "error")
+        return nil, err
+    }
+
+    var mismatches []AuditMismatch
+    mismatches = append(mismatches, a.diffTopologyCache(truth, cacheState)...)
+    mismatches = append(mismatches, a.diffSchedulerDomains(truth)...)
+    if a.domainManager != nil {
+        mismatches = append(mismatches, a.diffDomainManager(truth)...)
+    }
+
+    sort.Slice(mismatches, func(i, j int) bool {
+        return mismatches[i].String() < mismatches[j].String()
+    })
+
+    for _, m := range mismatches {
+        klog.Warningf("Cache audit mismatch: %s", m)
+        a.recordEvent(m, truth)
+    }
+    a.scheduler.metrics.ObserveCacheAudit(mismatches, "success")
+
+    if a.selfHeal && len(mismatches) > 0 {
+        a.heal(truth)
+    }
+    return mismatches, nil
+}
+
+func (a *CacheAuditor) computeTruth(ctx context.Context, cacheState *CacheAuditState) (*auditTruth, error) {
+    nodes, err := a.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+    if err != nil {
+        return nil, fmt.Errorf("failed to list nodes: %v", err)
+    }
+    pods, err := a.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+    if err != nil {
+        return nil, fmt.Errorf("failed to list pods: %v", err)
+    }
+
+    truth := &auditTruth{
+        nodes:       make(map[string]*v1.Node, len(nodes.Items)),
+        nodeDomain:  make(map[string]string),
+        nodeGPUs:    make(map[string]int),
+        domainUsed:  make(map[string]int),
+        domainTotal: make(map[string]int),
+    }
+
+    for i := range nodes.Items {
+        node := &nodes.Items[i]
+        truth.nodes[node.Name] = node
+        if leaf := node.Labels[v1alpha1.LabelLeafDomain]; leaf != "" {
+            truth.nodeDomain[node.Name] = leaf
+            // Device health and spares are only known to the cache, so they are taken as given
+            truth.domainTotal[leaf] += max(nodeGPUCapacity(node)-cacheState.WithheldGPUs[node.Name], 0)
+        }
+    }
+
+    for i := range pods.Items {
+        pod := &pods.Items[i]
+        if pod.Spec.NodeName == "" || isTerminalPod(pod) {
+            continue
+        }
+        gpus := getGPURequirements(pod)
+        if gpus == 0 {
+            continue
+        }
+        truth.boundPods = append(truth.boundPods, pod)
+        truth.nodeGPUs[pod.Spec.NodeName] += gpus
+    }
+
+    // Assumed pods are not bound yet, so the API server cannot know about them
+    for node, gpus := range cacheState.AssumedNodeGPUs {
+        truth.nodeGPUs[node] += gpus
+    }
+
+    for node, gpus := range truth.nodeGPUs {
+        if domain, ok := truth.nodeDomain[node]; ok {
+            truth.domainUsed[domain] += gpus
+        }
+    }
+    return truth, nil
+}
+
+func (a *CacheAuditor) diffTopologyCache(truth *auditTruth, state *CacheAuditState) []AuditMismatch {
+    var mismatches []AuditMismatch
+
+    for node, expected := range truth.nodeDomain {
+        if actual := state.DomainForNode[node]; actual != expected {
+            mismatches = append(mismatches, newMismatch(AuditCacheTopology, AuditKindMembership, node, expected, actual))
+        }
+    }
+    for node, actual := range state.DomainForNode {
+        if _, ok := truth.nodeDomain[node]; !ok {
+            mismatches = append(mismatches, newMismatch(AuditCacheTopology, AuditKindMembership, node, "", actual))
+        }
+    }
+
+    for _, node := range unionKeys(truth.nodeGPUs, state.NodeGPUs) {
+        if truth.nodeGPUs[node] != state.NodeGPUs[node] {
+            mismatches = append(mismatches, newIntMismatch(AuditCacheTopology, AuditKindNodeGPUs, node,
+                truth.nodeGPUs[node], state.NodeGPUs[node]))
+        }
+    }
+
+    for _, node := range unionKeys(truth.nodeGPUs, state.NodeAllocations) {
+        if _, known := truth.nodes[node]; !known {
+            continue
+        }
+        if truth.nodeGPUs[node] != state.NodeAllocations[node] {
+            mismatches = append(mismatches, newIntMismatch(AuditCacheNode, AuditKindNodeGPUs, node,
+                truth.nodeGPUs[node], state.NodeAllocations[node]))
+        }
+    }
+
+    for _, domain := range unionKeys(truth.domainUsed, state.DomainUsedGPUs) {
+        if truth.domainUsed[domain] != state.DomainUsedGPUs[domain] {
+            mismatches = append(mismatches, newIntMismatch(AuditCacheTopology, AuditKindDomainGPUs, domain,
+                truth.domainUsed[domain], state.DomainUsedGPUs[domain]))
+        }
+    }
+    for _, domain := range unionKeys(truth.domainTotal, state.DomainTotalGPUs) {
+        if truth.domainTotal[domain] != state.DomainTotalGPUs[domain] {
+            mismatches = append(mismatches, newIntMismatch(AuditCacheTopology, AuditKindTotalGPUs, domain,
+                truth.domainTotal[domain], state.DomainTotalGPUs[domain]))
+        }
+    }
+    return mismatches
+}
+
+func (a *CacheAuditor) diffSchedulerDomains(truth *auditTruth) []AuditMismatch {
+    a.scheduler.RLock()
+    defer a.scheduler.RUnlock()
+
+    var mismatches []AuditMismatch
+    for name, domain := range a.scheduler.domains {
+        if truth.domainUsed[name] != domain.UsedGPUs {
+            mismatches = append(mismatches, newIntMismatch(AuditCacheScheduler, AuditKindDomainGPUs, name,
+                truth.domainUsed[name], domain.UsedGPUs))
+        }
+    }
+    return mismatches
+}
+
+func (a *CacheAuditor) diffDomainManager(truth *auditTruth) []AuditMismatch {
+    var mismatches []AuditMismatch
+    nodeDomains := a.domainManager.NodeDomains()
+    for node, actual := range nodeDomains {
+        if expected := truth.nodeDomain[node]; expected != actual {
+            mismatches = append(mismatches, newMismatch(AuditCacheDomain, AuditKindMembership, node, expected, actual))
+        }
+    }
+    return mismatches
+}
+
+// heal rebuilds the topology cache and scheduler domains from the truth. The
+// domain manager is only reported on since it is populated from node updates.
+func (a *CacheAuditor) heal(truth *auditTruth) {
+    cache := a.scheduler.cache
+    state := cache.AuditState()
+
+    for name := range state.DomainForNode {
+        if _, exists := truth.nodes[name]; !exists {
+            cache.DeleteNode(name)
+        }
+    }
+    for _, node := range truth.nodes {
+        cache.UpsertNode(node)
+    }
+    cache.ResyncPods(truth.boundPods)
+
+    a.scheduler.Lock()
+    for name, domain := range a.scheduler.domains {
+        domain.UsedGPUs = truth.domainUsed[name]
+    }
+    a.scheduler.Unlock()
+
+    a.scheduler.metrics.IncCacheHeal()
+    klog.Infof("Cache auditor healed topology cache and scheduler domains")
+}
+
+func (a *CacheAuditor) recordEvent(m AuditMismatch, truth *auditTruth) {
+    if a.recorder == nil {
+        return
+    }
+
+    ref := &v1.ObjectReference{Kind: "Node", Name: m.Object}
+    if node, exists := truth.nodes[m.Object]; exists {
+        ref.UID = node.UID
+    }
+    if m.Kind == AuditKindDomainGPUs || m.Kind == AuditKindTotalGPUs {
+        ref = domainRef(m.Object)
+    }
+    a.recorder.Eventf(ref, v1.EventTypeWarning, "CacheMismatch", "%s", m.String())
+}
+
+func newMismatch(cache, kind, object, expected, actual string) AuditMismatch {
+    if expected == "" {
+        expected = "<none>"
+    }
+    if actual == "" {
+        actual = "<none>"
+    }
+    return AuditMismatch{Cache: cache, Kind: kind, Object: object, Expected: expected, Actual: actual}
+}
+
+func newIntMismatch(cache, kind, object string, expected, actual int) AuditMismatch {
+    return AuditMismatch{
+        Cache:    cache,
+        Kind:     kind,
+        Object:   object,
+        Expected: fmt.Sprintf("%d", expected),
+        Actual:   fmt.Sprintf("%d", actual),
+    }
+}
+
+func unionKeys(a, b map[string]int) []string {
+    keys := make([]string, 0, len(a)+len(b))
+    for k := range a {
+        keys = append(keys, k)
+    }
+    for k := range b {
+        if _, ok := a[k]; !ok {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,316 @@
+package algorithm
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/errors"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/client-go/kubernetes"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/klog/v2"
+)
+
+const (
+    checkpointVersion = 1
+    checkpointKey     = "checkpoint.json"
+
+    CheckpointStoreConfigMap = "configmap"
+    CheckpointStoreFile      = "file"
+    CheckpointStoreNone      = "none"
+)
+
+// SchedulerCheckpoint is the leader state persisted across failover
+type SchedulerCheckpoint struct {
+    Version          int              `json:"version"`
+    Holder           string           `json:"holder"`
+    Timestamp        time.Time        `json:"timestamp"`
+    ConfigGeneration int64            `json:"configGeneration"`
+    Cache            *CacheCheckpoint `json:"cache"`
+    // PlacementHints steer the pending replacements of recovered gangs
+    PlacementHints []PlacementHintCheckpoint `json:"placementHints,omitempty"`
+    // Recoveries is the recovery history, with where each replacement landed
+    Recoveries *RecoveryHistoryCheckpoint `json:"recoveries,omitempty"`
+    // Breaker keeps automatic recovery paused across failover
+    Breaker *BreakerCheckpoint `json:"breaker,omitempty"`
+    // Spares keeps claimed spares for the replacements they wait for
+    Spares *SparePoolCheckpoint `json:"spares,omitempty"`
+}
+
+type PlacementHintCheckpoint struct {
+    OwnerUID types.UID `json:"ownerUID"`
+    Domain   string    `json:"domain"`
+    // Pending counts the replacements still expected in the domain
+    Pending  int       `json:"pending,omitempty"`
+    Expires  time.Time `json:"expires"`
+}
+
+type RecoveryHistoryCheckpoint struct {
+    Records []RecoveryRecord `json:"records"`
+    NextID  int              `json:"nextID"`
+}
+
+type SparePoolCheckpoint struct {
+    Claimed   []ClaimedSpareCheckpoint  `json:"claimed,omitempty"`
+    Backfills []SpareBackfillCheckpoint `json:"backfills,omitempty"`
+}
+
+type ClaimedSpareCheckpoint struct {
+    Node     string `json:"node"`
+    Leaf     string `json:"leaf"`
+    Spine    string `json:"spine,omitempty"`
+    Capacity int    `json:"capacity"`
+    // Claims counts the replacements still expected, by claim key, and Pods
+    // maps the pending replacements already matched to their claim key
+    Claims    map[string]int       `json:"claims"`
+    Pods      map[types.UID]string `json:"pods,omitempty"`
+    ClaimedAt time.Time            `json:"claimedAt"`
+    Expires   time.Time            `json:"expires"`
+}
+
+type SpareBackfillCheckpoint struct {
+    FailedNode string `json:"failedNode"`
+    Leaf       string `json:"leaf"`
+    Spine      string `json:"spine,omitempty"`
+}
+
+// CheckpointStore persists scheduler checkpoints. Load returns nil and no
+// error when no checkpoint has been written yet.
+type CheckpointStore interface {
+    Save(ctx context.Context, cp *SchedulerCheckpoint) error
+    Load(ctx context.Context) (*SchedulerCheckpoint, error)
+}
+
+// ConfigMapCheckpointStore keeps the checkpoint in a ConfigMap. A checkpoint
+// that only differs from the last one written by its timestamp is not written.
+type ConfigMapCheckpointStore struct {
+    client    kubernetes.Interface
+    namespace string
+    name      string
+
+    mu sync.Mutex
+    // written is the last checkpoint written, encoded without its timestamp
+    written []byte
+}
+
+func NewConfigMapCheckpointStore(client kubernetes.Interface, namespace, name string) *ConfigMapCheckpointStore {
+    return &ConfigMapCheckpointStore{
+        client:    client,
+        namespace: namespace,
+        name:      name,
+    }
+}
+
+func (s *ConfigMapCheckpointStore) Save(ctx context.Context, cp *SchedulerCheckpoint) error {
+    data, err := json.Marshal(cp)
+    if err != nil {
+        return fmt.Errorf("failed to encode checkpoint: %v", err)
+    }
+    state := *cp
+    state.Timestamp = time.Time{}
+    unstamped, err := json.Marshal(&state)
+    if err != nil {
+        return fmt.Errorf("failed to encode checkpoint: %v", err)
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if bytes.Equal(unstamped, s.written) {
+        return nil
+    }
+    if err := s.write(ctx, data); err != nil {
+        return err
+    }
+    s.written = unstamped
+    return nil
+}
+
+func (s *ConfigMapCheckpointStore) write(ctx context.Context, data []byte) error {
+    configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+    cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+    if errors.IsNotFound(err) {
+        cm = &v1.ConfigMap{
+            ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+            Data:       map[string]string{checkpointKey: string(data)},
+        }
+        _, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+        return err
+    }
+    if err != nil {
+        return err
+    }
+
+    cm = cm.DeepCopy()
+    if cm.Data == nil {
+        cm.Data = make(map[string]string)
+    }
+    cm.Data[checkpointKey] = string(data)
+    _, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+    return err
+}
+
+func (s *ConfigMapCheckpointStore) Load(ctx context.Context) (*SchedulerCheckpoint, error) {
+    cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+    if errors.IsNotFound(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    data, ok := cm.Data[checkpointKey]
+    if !ok {
+        return nil, nil
+    }
+    return decodeCheckpoint([]byte(data))
+}
+
+// FileCheckpointStore keeps the checkpoint on a local or shared volume
+type FileCheckpointStore struct {
+    path string
+}
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+    return &FileCheckpointStore{path: path}
+}
+
+// Save writes to a temporary file and renames it so a crash never leaves a
+// partially written checkpoint
+func (s *FileCheckpointStore) Save(ctx context.Context, cp *SchedulerCheckpoint) error {
+    data, err := json.Marshal(cp)
+    if err != nil {
+        return fmt.Errorf("failed to encode checkpoint: %v", err)
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(s.path), ".checkpoint-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.Write(data); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileCheckpointStore) Load(ctx context.Context) (*SchedulerCheckpoint, error) {
+    data, err := os.ReadFile(s.path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return decodeCheckpoint(data)
+}
+
+func decodeCheckpoint(data []byte) (*SchedulerCheckpoint, error) {
+    var cp SchedulerCheckpoint
+    if err := json.Unmarshal(data, &cp); err != nil {
+        return nil, fmt.Errorf("failed to decode checkpoint: %v", err)
+    }
+    if cp.Version != checkpointVersion {
+        return nil, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+    }
+    return &cp, nil
+}
+
+// Checkpointer periodically saves the leader's state and restores it on a new leader
+type Checkpointer struct {
+    scheduler *TopologyScheduler
+    store     CheckpointStore
+    // pods reconciles restored reservations with the pods that still exist
+    pods     corelisters.PodLister
+    recovery *RecoveryManager
+    holder   string
+    interval time.Duration
+}
+
+func NewCheckpointer(scheduler *TopologyScheduler, store CheckpointStore, pods corelisters.PodLister, holder string, interval time.Duration) *Checkpointer {
+    return &Checkpointer{
+        scheduler: scheduler,
+        store:     store,
+        pods:      pods,
+        holder:    holder,
+        interval:  interval,
+    }
+}
+
+// SetRecoveryManager adds the recovery circuit breaker to the checkpoint
+func (c *Checkpointer) SetRecoveryManager(recovery *RecoveryManager) {
+    c.recovery = recovery
+}
+
+// Restore loads the last checkpoint into the scheduler. It must be called
+// after informer caches have synced and before scheduling starts.
+func (c *Checkpointer) Restore(ctx context.Context) error {
+    cp, err := c.store.Load(ctx)
+    if err != nil {
+        return fmt.Errorf("failed to load checkpoint: %v", err)
+    }
+    if cp == nil {
+        klog.Info("No scheduler checkpoint found, starting from informer state")
+        return nil
+    }
+
+    klog.Infof("Restoring checkpoint written by %s at %s (config generation %d)",
+        cp.Holder, cp.Timestamp.Format(time.RFC3339), cp.ConfigGeneration)
+    if cp.Cache != nil {
+        c.scheduler.cache.Restore(cp.Cache, c.pods)
+    }
+    c.scheduler.restorePlacementHints(cp.PlacementHints)
+    if cp.Recoveries != nil {
+        c.scheduler.recoveries.restore(cp.Recoveries)
+    }
+    if cp.Spares != nil {
+        c.scheduler.spares.restore(cp.Spares)
+    }
+    if cp.Breaker != nil && c.recovery != nil {
+        c.recovery.restoreBreaker(cp.Breaker)
+    }
+    return nil
+}
+
+func (c *Checkpointer) Save(ctx context.Context) error {
+    cp := &SchedulerCheckpoint{
+        Version:          checkpointVersion,
+        Holder:           c.holder,
+        Timestamp:        time.Now(),
+        ConfigGeneration: c.scheduler.GetConfig().Generation,
+        Cache:            c.scheduler.cache.Checkpoint(),
+        PlacementHints:   c.scheduler.placementHintsCheckpoint(),
+        Recoveries:       c.scheduler.recoveries.checkpoint(),
+        Spares:           c.scheduler.spares.checkpoint(),
+    }
+    if c.recovery != nil {
+        cp.Breaker = c.recovery.Breaker().checkpoint()
+    }
+    return c.store.Save(ctx, cp)
+}
+
+// Run saves a checkpoint every interval until ctx is done
+func (c *Checkpointer) Run(ctx context.Context) {
+    wait.UntilWithContext(ctx, func(ctx context.Context) {
+        if err := c.Save(ctx); err != nil {
+            klog.Errorf("Failed to save scheduler checkpoint: %v", err)
+        }
+    }, c.interval)
+}
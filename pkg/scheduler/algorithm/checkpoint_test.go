@@ -0,0 +1,55 @@
+package algorithm
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapCheckpointStoreSkipsUnchangedCheckpoints(t *testing.T) {
+    ts := testTopology()
+    client := fake.NewSimpleClientset()
+    store := NewConfigMapCheckpointStore(client, "kube-system", "checkpoint")
+    checkpointer := NewCheckpointer(ts, store, nil, "leader", time.Minute)
+    ts.cache.AddPod(testPod("a", "a-0", "n1-1", 8, ""))
+    ts.cache.AddPod(testPod("b", "b-0", "n1-2", 8, ""))
+
+    writes := func() int {
+        count := 0
+        for _, action := range client.Actions() {
+            if action.GetVerb() == "create" || action.GetVerb() == "update" {
+                count++
+            }
+        }
+        return count
+    }
+
+    for i := 0; i < 3; i++ {
+        if err := checkpointer.Save(context.Background()); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if got := writes(); got != 1 {
+        t.Errorf("writes for an unchanged scheduler = %d, want 1", got)
+    }
+
+    if err := ts.cache.AssumePod(testPod("c", "c-0", "", 8, ""), "n2-1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := checkpointer.Save(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if got := writes(); got != 2 {
+        t.Errorf("writes after an assumed pod = %d, want 2", got)
+    }
+
+    cp, err := store.Load(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cp == nil || cp.Cache == nil || len(cp.Cache.AssumedPods) != 1 {
+        t.Fatalf("loaded checkpoint %+v, want one assumed pod", cp)
+    }
+}
@@ -0,0 +1,167 @@
+package algorithm
+
+import (
+    "fmt"
+    "os"
+    "time"
+
+    "sigs.k8s.io/yaml"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+const (
+    ConfigSourceDefault = "default"
+    ConfigSourceFile    = "file"
+    ConfigSourceCRD     = "crd"
+)
+
+// DefaultHealthPolicy is used when a config does not set a health policy
+var DefaultHealthPolicy = v1alpha1.HealthPolicy{
+    MinDomainHealth:   0.75,
+    FlapWindowSeconds: int32(DefaultFlapWindow / time.Second),
+    FlapPenalty:       0.1,
+}
+
+// SchedulerConfig is the set of tunables that can be changed without a restart
+type SchedulerConfig struct {
+    Weights     TopologyScore
+    Constraints v1alpha1.TopologyConstraints
+    Health      v1alpha1.HealthPolicy
+    Spares      v1alpha1.SparePoolPolicy
+    // Generation identifies the config revision. It is the TopologySchedulerConfig
+    // generation when known, otherwise a local counter.
+    Generation int64
+    Source     string
+}
+
+// SchedulerConfigKind is the kind of the document mounted from the ConfigMap
+const SchedulerConfigKind = "SchedulerConfig"
+
+// legacySchedulerConfigAPIVersion is the apiVersion config files used before
+// the API group moved to topology.scheduler.k8s.io. It is still accepted.
+const legacySchedulerConfigAPIVersion = "topology.scheduler/v1alpha1"
+
+// schedulerConfigFile mirrors the SchedulerConfig document mounted from the ConfigMap
+type schedulerConfigFile struct {
+    APIVersion string `json:"apiVersion"`
+    Kind       string `json:"kind"`
+    Metadata   struct {
+        Name       string `json:"name"`
+        Generation int64  `json:"generation"`
+    } `json:"metadata"`
+    Spec v1alpha1.TopologySchedulerConfigSpec `json:"spec"`
+}
+
+func DefaultSchedulerConfig() *SchedulerConfig {
+    return &SchedulerConfig{
+        Weights: TopologyScore{
+            ResourceAvailability: 0.4,
+            TopologyAlignment:    0.3,
+            DomainUtilization:    0.2,
+            HistoricalPerf:       0.1,
+        },
+        Health: DefaultHealthPolicy,
+        Source: ConfigSourceDefault,
+    }
+}
+
+// NewSchedulerConfig builds a SchedulerConfig from the API spec
+func NewSchedulerConfig(spec *v1alpha1.TopologySchedulerConfigSpec, generation int64, source string) *SchedulerConfig {
+    health := spec.HealthPolicy
+    if health == (v1alpha1.HealthPolicy{}) {
+        health = DefaultHealthPolicy
+    }
+    if health.FlapWindowSeconds == 0 {
+        health.FlapWindowSeconds = DefaultHealthPolicy.FlapWindowSeconds
+    }
+
+    return &SchedulerConfig{
+        Weights: TopologyScore{
+            ResourceAvailability: spec.ScoringWeights.ResourceAvailability,
+            TopologyAlignment:    spec.ScoringWeights.TopologyAlignment,
+            DomainUtilization:    spec.ScoringWeights.DomainUtilization,
+            HistoricalPerf:       spec.ScoringWeights.HistoricalPerformance,
+        },
+        Constraints: spec.TopologyConstraints,
+        Health:      health,
+        Spares:      spec.SparePool,
+        Generation:  generation,
+        Source:      source,
+    }
+}
+
+// LoadSchedulerConfigFile reads a SchedulerConfig document from disk. The
+// returned generation is zero unless the document carries one.
+func LoadSchedulerConfigFile(path string) (*SchedulerConfig, []byte, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to read scheduler config %s: %v", path, err)
+    }
+
+    var file schedulerConfigFile
+    if err := yaml.UnmarshalStrict(data, &file); err != nil {
+        return nil, nil, fmt.Errorf("failed to parse scheduler config %s: %v", path, err)
+    }
+    apiVersion := file.APIVersion == v1alpha1.SchemeGroupVersion.String() || file.APIVersion == legacySchedulerConfigAPIVersion
+    if !apiVersion || file.Kind != SchedulerConfigKind {
+        return nil, nil, fmt.Errorf("scheduler config %s is %s %s, expected %s %s", path,
+            file.APIVersion, file.Kind, v1alpha1.SchemeGroupVersion, SchedulerConfigKind)
+    }
+
+    return NewSchedulerConfig(&file.Spec, file.Metadata.Generation, ConfigSourceFile), data, nil
+}
+
+func (c *SchedulerConfig) Validate() error {
+    weights := v1alpha1.ScoringWeights{
+        ResourceAvailability:  c.Weights.ResourceAvailability,
+        TopologyAlignment:     c.Weights.TopologyAlignment,
+        DomainUtilization:     c.Weights.DomainUtilization,
+        HistoricalPerformance: c.Weights.HistoricalPerf,
+    }
+    if err := weights.Validate(); err != nil {
+        return err
+    }
+    if err := c.Constraints.Validate(); err != nil {
+        return err
+    }
+    if err := c.Spares.Validate(); err != nil {
+        return err
+    }
+    return c.Health.Validate()
+}
+
+// FlapWindow returns how long node health transitions count against a domain
+func (c *SchedulerConfig) FlapWindow() time.Duration {
+    return time.Duration(c.Health.FlapWindowSeconds) * time.Second
+}
+
+// ApplyConfig validates and atomically swaps in a new scheduler config. An
+// invalid config is rejected and the current one stays in effect.
+func (ts *TopologyScheduler) ApplyConfig(config *SchedulerConfig) error {
+    if err := config.Validate(); err != nil {
+        ts.metrics.ObserveConfigReload(config.Source, "invalid")
+        return fmt.Errorf("rejecting scheduler config from %s: %v", config.Source, err)
+    }
+
+    ts.Lock()
+    if config.Generation == 0 {
+        config.Generation = ts.config.Generation + 1
+    }
+    ts.config = config
+    ts.Unlock()
+
+    ts.cache.SetFlapWindow(config.FlapWindow())
+    ts.spares.SetPolicy(config.Spares)
+
+    ts.metrics.ObserveConfigReload(config.Source, "applied")
+    ts.metrics.SetConfigGeneration(config.Generation)
+    return nil
+}
+
+// GetConfig returns the config currently in effect
+func (ts *TopologyScheduler) GetConfig() *SchedulerConfig {
+    ts.RLock()
+    defer ts.RUnlock()
+    return ts.config
+}
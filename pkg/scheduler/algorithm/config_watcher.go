@@ -0,0 +1,85 @@
+package algorithm
+
+import (
+    "bytes"
+    "time"
+
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    informers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/informers/externalversions/topology/v1alpha1"
+)
+
+// ConfigWatcher keeps the scheduler config in sync with the mounted
+// SchedulerConfig file or a TopologySchedulerConfig object
+type ConfigWatcher struct {
+    scheduler *TopologyScheduler
+    path      string
+    interval  time.Duration
+    lastData  []byte
+}
+
+func NewConfigWatcher(scheduler *TopologyScheduler, path string, interval time.Duration) *ConfigWatcher {
+    return &ConfigWatcher{
+        scheduler: scheduler,
+        path:      path,
+        interval:  interval,
+    }
+}
+
+// LoadFile reads the config file once and applies it if it changed since the last load
+func (cw *ConfigWatcher) LoadFile() error {
+    config, data, err := LoadSchedulerConfigFile(cw.path)
+    if err != nil {
+        return err
+    }
+    if bytes.Equal(data, cw.lastData) {
+        return nil
+    }
+
+    if err := cw.scheduler.ApplyConfig(config); err != nil {
+        return err
+    }
+    cw.lastData = data
+
+    klog.Infof("Applied scheduler config generation %d from %s: weights %+v, constraints %+v",
+        config.Generation, cw.path, config.Weights, config.Constraints)
+    return nil
+}
+
+// WatchFile polls the config file until stopCh is closed. ConfigMap volumes are
+// updated by swapping a symlink, so polling picks up changes without inotify.
+func (cw *ConfigWatcher) WatchFile(stopCh <-chan struct{}) {
+    wait.Until(func() {
+        if err := cw.LoadFile(); err != nil {
+            klog.Errorf("Failed to reload scheduler config: %v", err)
+        }
+    }, cw.interval, stopCh)
+}
+
+// WatchCRD applies the named TopologySchedulerConfig whenever its generation changes
+func (cw *ConfigWatcher) WatchCRD(informer informers.TopologySchedulerConfigInformer, namespace, name string) {
+    apply := func(obj interface{}) {
+        config, ok := obj.(*v1alpha1.TopologySchedulerConfig)
+        if !ok || config.Namespace != namespace || config.Name != name {
+            return
+        }
+        if config.Generation == cw.scheduler.GetConfig().Generation {
+            return
+        }
+
+        schedulerConfig := NewSchedulerConfig(&config.Spec, config.Generation, ConfigSourceCRD)
+        if err := cw.scheduler.ApplyConfig(schedulerConfig); err != nil {
+            klog.Errorf("Failed to apply TopologySchedulerConfig %s/%s: %v", namespace, name, err)
+            return
+        }
+        klog.Infof("Applied TopologySchedulerConfig %s/%s generation %d", namespace, name, config.Generation)
+    }
+
+    informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc:    apply,
+        UpdateFunc: func(old, new interface{}) { apply(new) },
+    })
+}
@@ -0,0 +1,31 @@
+package algorithm
+
+import (
+    "testing"
+)
+
+func TestCycleStateCloneKeepsRejectionsApart(t *testing.T) {
+    state := &cycleState{
+        snapshot:   testTopology().cache.Snapshot(),
+        rejections: newFilterRejections(),
+        candidates: newDomainCandidates(),
+    }
+    state.rejections.observe("insufficient GPUs", true)
+
+    clone := state.Clone().(*cycleState)
+    if clone == state || clone.snapshot != state.snapshot {
+        t.Fatalf("clone should be a new state sharing the snapshot")
+    }
+    clone.rejections.observe("domain cordoned", true)
+    clone.candidates.observe(DomainCandidate{Domain: "leaf-1", Score: 1})
+
+    if counts := state.rejections.counts(); len(counts) != 1 || counts["insufficient GPUs"] != 1 {
+        t.Errorf("cycle rejections = %v, want only the cycle's own", counts)
+    }
+    if state.rejections.filtered() != 1 {
+        t.Errorf("cycle filtered %d nodes, want 1", state.rejections.filtered())
+    }
+    if candidates := state.candidates.list(); len(candidates) != 0 {
+        t.Errorf("cycle candidates = %v, want none from the clone", candidates)
+    }
+}
@@ -0,0 +1,227 @@
+package algorithm
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/klog/v2"
+)
+
+// Decision types recorded in the decision log
+const (
+    DecisionPlacement = "placement"
+    DecisionRejection = "rejection"
+    DecisionRecovery  = "recovery"
+)
+
+const (
+    // DecisionLogStdout writes the decision log to standard output
+    DecisionLogStdout = "stdout"
+
+    DefaultDecisionLogMaxSizeMB  = 100
+    DefaultDecisionLogMaxBackups = 5
+)
+
+// Decision is one line of the decision log
+type Decision struct {
+    Time         time.Time             `json:"time"`
+    Type         string                `json:"type"`
+    Pod          string                `json:"pod,omitempty"`
+    PodUID       types.UID             `json:"podUID,omitempty"`
+    Job          string                `json:"job,omitempty"`
+    Requirements *DecisionRequirements `json:"requirements,omitempty"`
+    Strategy     string                `json:"strategy,omitempty"`
+    PreferredDomain string            `json:"preferredDomain,omitempty"`
+    Candidates      []DomainCandidate `json:"candidates,omitempty"`
+    // Nodes are the nodes chosen for the pod; JobNodes hold the whole job so far
+    Nodes    []string `json:"nodes,omitempty"`
+    JobNodes []string `json:"jobNodes,omitempty"`
+    // Rejections counts the nodes the topology filter rejected, by reason
+    Rejections       map[string]int  `json:"rejections,omitempty"`
+    Recovery         *RecoveryRecord `json:"recovery,omitempty"`
+    ConfigGeneration int64           `json:"configGeneration"`
+    LatencyMillis    float64         `json:"latencyMs"`
+    Error            string          `json:"error,omitempty"`
+}
+
+type DecisionRequirements struct {
+    GPUs        int `json:"gpus"`
+    NodesNeeded int `json:"nodesNeeded"`
+}
+
+// DomainCandidate is how the best node of a domain scored for a pod. Score
+// is Base * HealthFactor * LinkFactor plus the bonuses.
+type DomainCandidate struct {
+    Domain string `json:"domain"`
+    // Nodes is how many of the domain's nodes passed the filters and were scored
+    Nodes          int     `json:"nodes"`
+    Base           float64 `json:"base"`
+    HealthFactor   float64 `json:"healthFactor"`
+    LinkFactor     float64 `json:"linkFactor"`
+    PreferredBonus float64 `json:"preferredBonus,omitempty"`
+    SpareBonus     float64 `json:"spareBonus,omitempty"`
+    Score          float64 `json:"score"`
+}
+
+// DecisionLog appends decisions as JSON Lines to stdout or to a file that is
+// rotated once it reaches its maximum size
+type DecisionLog struct {
+    mu  sync.Mutex
+    out io.Writer
+    // file is nil when writing to stdout
+    file *rotatingFile
+}
+
+// OpenDecisionLog opens the decision log at target, which is a file path or
+// stdout. maxSizeMB of 0 disables rotation.
+func OpenDecisionLog(target string, maxSizeMB, maxBackups int) (*DecisionLog, error) {
+    if target == DecisionLogStdout {
+        return &DecisionLog{out: os.Stdout}, nil
+    }
+    file, err := openRotatingFile(target, int64(maxSizeMB)<<20, maxBackups)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open decision log %s: %v", target, err)
+    }
+    return &DecisionLog{out: file, file: file}, nil
+}
+
+func (l *DecisionLog) Record(decision *Decision) {
+    line, err := json.Marshal(decision)
+    if err != nil {
+        klog.Errorf("Failed to encode %s decision: %v", decision.Type, err)
+        return
+    }
+    line = append(line, '\n')
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if _, err := l.out.Write(line); err != nil {
+        klog.Errorf("Failed to write %s decision: %v", decision.Type, err)
+    }
+}
+
+func (l *DecisionLog) Close() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.file == nil {
+        return nil
+    }
+    return l.file.Close()
+}
+
+// SetDecisionLog makes the scheduler record its decisions; nil disables it
+func (ts *TopologyScheduler) SetDecisionLog(log *DecisionLog) {
+    ts.decisions.Store(log)
+}
+
+// recordDecision stamps a decision with the time and config generation and
+// appends it to the decision log, if there is one
+func (ts *TopologyScheduler) recordDecision(decision *Decision) {
+    log := ts.decisions.Load()
+    if log == nil {
+        return
+    }
+    decision.Time = time.Now()
+    decision.ConfigGeneration = ts.GetConfig().Generation
+    log.Record(decision)
+}
+
+// podDecision starts a decision about a pod
+func podDecision(decisionType string, pod *v1.Pod, gpuReq *GPURequirements) *Decision {
+    decision := &Decision{
+        Type:   decisionType,
+        Pod:    pod.Namespace + "/" + pod.Name,
+        PodUID: pod.UID,
+        Job:    getJobName(pod),
+    }
+    if gpuReq != nil {
+        decision.Requirements = &DecisionRequirements{
+            GPUs:        getGPURequirements(pod),
+            NodesNeeded: gpuReq.NodesNeeded,
+        }
+    }
+    return decision
+}
+
+func millisSince(start time.Time) float64 {
+    return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// rotatingFile is an append-only file that is renamed to path.1, shifting
+// older backups up, once a write would take it past maxSize
+type rotatingFile struct {
+    path       string
+    maxSize    int64
+    maxBackups int
+    file       *os.File
+    size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+    r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+    if err := r.open(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+func (r *rotatingFile) open() error {
+    file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return err
+    }
+    r.file = file
+    r.size = info.Size()
+    return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+    if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+        if err := r.rotate(); err != nil {
+            return 0, err
+        }
+    }
+    n, err := r.file.Write(p)
+    r.size += int64(n)
+    return n, err
+}
+
+// rotate moves the log to its first backup and starts a new one. The log is
+// reopened even when the move fails, so decisions keep being written.
+func (r *rotatingFile) rotate() error {
+    if err := r.file.Close(); err != nil {
+        klog.Errorf("Failed to close decision log %s for rotation: %v", r.path, err)
+    }
+    if err := r.shiftBackups(); err != nil {
+        klog.Errorf("Failed to rotate decision log %s: %v", r.path, err)
+    }
+    return r.open()
+}
+
+func (r *rotatingFile) shiftBackups() error {
+    if r.maxBackups > 0 {
+        for i := r.maxBackups - 1; i >= 1; i-- {
+            err := os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+            if err != nil && !os.IsNotExist(err) {
+                return err
+            }
+        }
+        return os.Rename(r.path, r.path+".1")
+    }
+    return os.Remove(r.path)
+}
+
+func (r *rotatingFile) Close() error {
+    return r.file.Close()
+}
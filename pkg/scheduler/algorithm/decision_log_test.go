@@ -0,0 +1,142 @@
+package algorithm
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+    line := strings.Repeat("x", 9) + "\n"
+
+    tests := []struct {
+        name       string
+        maxSize    int64
+        maxBackups int
+        writes     int
+        // sizes is the expected size of the log and then each backup; a
+        // missing file is -1
+        sizes []int64
+    }{
+        {
+            name:       "no rotation below the size limit",
+            maxSize:    100,
+            maxBackups: 2,
+            writes:     5,
+            sizes:      []int64{50, -1},
+        },
+        {
+            name:       "rotates once the next write would pass the limit",
+            maxSize:    30,
+            maxBackups: 2,
+            writes:     4,
+            sizes:      []int64{10, 30, -1},
+        },
+        {
+            name:       "keeps at most maxBackups backups",
+            maxSize:    10,
+            maxBackups: 2,
+            writes:     5,
+            sizes:      []int64{10, 10, 10, -1},
+        },
+        {
+            name:       "without backups the log is truncated",
+            maxSize:    20,
+            maxBackups: 0,
+            writes:     3,
+            sizes:      []int64{10, -1},
+        },
+        {
+            name:       "no size limit",
+            maxSize:    0,
+            maxBackups: 2,
+            writes:     10,
+            sizes:      []int64{100, -1},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), "decisions.log")
+            r, err := openRotatingFile(path, tt.maxSize, tt.maxBackups)
+            if err != nil {
+                t.Fatalf("failed to open: %v", err)
+            }
+            for i := 0; i < tt.writes; i++ {
+                if _, err := r.Write([]byte(line)); err != nil {
+                    t.Fatalf("write %d failed: %v", i, err)
+                }
+            }
+            if err := r.Close(); err != nil {
+                t.Fatalf("failed to close: %v", err)
+            }
+
+            for i, want := range tt.sizes {
+                name := path
+                if i > 0 {
+                    name = fmt.Sprintf("%s.%d", path, i)
+                }
+                got := int64(-1)
+                if info, err := os.Stat(name); err == nil {
+                    got = info.Size()
+                }
+                if got != want {
+                    t.Errorf("%s has size %d, want %d", filepath.Base(name), got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestRotatingFileAppendsToExistingLog(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "decisions.log")
+    if err := os.WriteFile(path, []byte("earlier\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    r, err := openRotatingFile(path, 100, 1)
+    if err != nil {
+        t.Fatalf("failed to open: %v", err)
+    }
+    if _, err := r.Write([]byte("later\n")); err != nil {
+        t.Fatalf("write failed: %v", err)
+    }
+    r.Close()
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(data) != "earlier\nlater\n" {
+        t.Errorf("log is %q, want the earlier content kept", data)
+    }
+}
+
+func TestRotatingFileKeepsWritingWhenRotationFails(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "decisions.log")
+    // A directory in place of the backup makes the rotation fail
+    if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0755); err != nil {
+        t.Fatal(err)
+    }
+
+    r, err := openRotatingFile(path, 10, 1)
+    if err != nil {
+        t.Fatalf("failed to open: %v", err)
+    }
+    for i := 0; i < 3; i++ {
+        if _, err := r.Write([]byte("012345678\n")); err != nil {
+            t.Fatalf("write %d failed: %v", i, err)
+        }
+    }
+    r.Close()
+
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if info.Size() != 30 {
+        t.Errorf("log has size %d, want every write kept", info.Size())
+    }
+}
@@ -1,51 +1,170 @@
-package scheduler
-
-import (
-    "fmt"
-    "sync"
-)
-
-type DomainManager struct {
-    mu      sync.RWMutex
-    domains map[string]*Domain
-}
-
-type Domain struct {
-    Name        string
-    Type        string // "leaf" or "spine"
-    Bandwidth   int64
-    Latency     float64
-    Nodes       map[string]*Node
-    Parent      string
-    Children    []string
-}
-
-func NewDomainManager() *DomainManager {
-    return &DomainManager{
-        domains: make(map[string]*Domain),
-    }
-}
-
-func (dm *DomainManager) AddDomain(domain *Domain) error {
-    dm.mu.Lock()
-    defer dm.mu.Unlock()
-
-    if _, exists := dm.domains[domain.Name]; exists {
-        return fmt.Errorf("domain %s already exists", domain.Name)
-    }
-
-    dm.domains[domain.Name] = domain
-    return nil
-}
-
-func (dm *DomainManager) GetDomainByNode(nodeName string) (*Domain, error) {
-    dm.mu.RLock()
-    defer dm.mu.RUnlock()
-
-    for _, domain := range dm.domains {
-        if _, exists := domain.Nodes[nodeName]; exists {
-            return domain, nil
-        }
-    }
-    return nil, fmt.Errorf("no domain found for node %s", nodeName)
-}
+package scheduler
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+type DomainManager struct {
+    mu        sync.RWMutex
+    domains   map[string]*Domain
+    nodeIndex map[string]string
+    // distances is rebuilt lazily after the domain tree changes
+    distances *topology.DistanceTable
+}
+
+type Domain struct {
+    Name        string
+    Type        string // "leaf" or "spine"
+    Bandwidth   int64
+    Latency     float64
+    Nodes       map[string]*Node
+    Parent      string
+    Children    []string
+}
+
+func NewDomainManager() *DomainManager {
+    return &DomainManager{
+        domains:   make(map[string]*Domain),
+        nodeIndex: make(map[string]string),
+    }
+}
+
+func (dm *DomainManager) AddDomain(domain *Domain) error {
+    dm.mu.Lock()
+    defer dm.mu.Unlock()
+
+    if _, exists := dm.domains[domain.Name]; exists {
+        return fmt.Errorf("domain %s already exists", domain.Name)
+    }
+
+    dm.domains[domain.Name] = domain
+    for nodeName := range domain.Nodes {
+        dm.nodeIndex[nodeName] = domain.Name
+    }
+    dm.distances = nil
+    return nil
+}
+
+func (dm *DomainManager) AddNodeToDomain(node *Node, domainName string) error {
+    dm.mu.Lock()
+    defer dm.mu.Unlock()
+
+    domain, exists := dm.domains[domainName]
+    if !exists {
+        return fmt.Errorf("domain %s not found", domainName)
+    }
+
+    if current, exists := dm.nodeIndex[node.Name]; exists && current != domainName {
+        delete(dm.domains[current].Nodes, node.Name)
+    }
+    if domain.Nodes == nil {
+        domain.Nodes = make(map[string]*Node)
+    }
+    domain.Nodes[node.Name] = node
+    dm.nodeIndex[node.Name] = domainName
+    return nil
+}
+
+func (dm *DomainManager) HandleNodeRemoval(nodeName string) error {
+    dm.mu.Lock()
+    defer dm.mu.Unlock()
+
+    domainName, exists := dm.nodeIndex[nodeName]
+    if !exists {
+        return fmt.Errorf("no domain found for node %s", nodeName)
+    }
+
+    delete(dm.domains[domainName].Nodes, nodeName)
+    delete(dm.nodeIndex, nodeName)
+    return nil
+}
+
+func (dm *DomainManager) GetDomainByNode(nodeName string) (*Domain, error) {
+    dm.mu.RLock()
+    defer dm.mu.RUnlock()
+
+    domainName, exists := dm.nodeIndex[nodeName]
+    if !exists {
+        return nil, fmt.Errorf("no domain found for node %s", nodeName)
+    }
+    return dm.domains[domainName], nil
+}
+
+// GetDomainDistance returns the weighted distance between two domains in the
+// leaf-spine tree, using a table that is recomputed only after the tree changes
+func (dm *DomainManager) GetDomainDistance(source, target string) (float64, error) {
+    dm.mu.RLock()
+    distances := dm.distances
+    dm.mu.RUnlock()
+
+    if distances == nil {
+        dm.mu.Lock()
+        if dm.distances == nil {
+            dm.distances = topology.NewDistanceTable(dm.edgesLocked())
+        }
+        distances = dm.distances
+        dm.mu.Unlock()
+    }
+
+    distance, ok := distances.Distance(source, target)
+    if !ok {
+        return 0, fmt.Errorf("domain %s is not reachable from %s", target, source)
+    }
+    return distance, nil
+}
+
+// GetAdjacentDomains returns the leaf domains that share a spine with the given
+// domain, ordered by name
+func (dm *DomainManager) GetAdjacentDomains(domainName string) []*Domain {
+    dm.mu.RLock()
+    defer dm.mu.RUnlock()
+
+    domain, exists := dm.domains[domainName]
+    if !exists || domain.Parent == "" {
+        return nil
+    }
+
+    var adjacent []*Domain
+    for _, other := range dm.domains {
+        if other.Name != domainName && other.Parent == domain.Parent && other.Type == "leaf" {
+            adjacent = append(adjacent, other)
+        }
+    }
+    sort.Slice(adjacent, func(i, j int) bool {
+        return adjacent[i].Name < adjacent[j].Name
+    })
+    return adjacent
+}
+
+// edgesLocked returns the uplink from every domain to its parent, weighted
+// by the child's uplink latency (in microseconds) and bandwidth
+func (dm *DomainManager) edgesLocked() []topology.Edge {
+    edges := make([]topology.Edge, 0, len(dm.domains))
+    for name, domain := range dm.domains {
+        if domain.Parent == "" {
+            continue
+        }
+        edges = append(edges, topology.Edge{
+            Source: name,
+            Target: domain.Parent,
+            Cost:   topology.LinkCost(domain.Latency, float64(domain.Bandwidth)),
+        })
+    }
+    return edges
+}
+
+// NodeDomains returns the domain each known node belongs to
+func (dm *DomainManager) NodeDomains() map[string]string {
+    dm.mu.RLock()
+    defer dm.mu.RUnlock()
+
+    nodeDomains := make(map[string]string, len(dm.nodeIndex))
+    for nodeName, domainName := range dm.nodeIndex {
+        nodeDomains[nodeName] = domainName
+    }
+    return nodeDomains
+}
@@ -0,0 +1,43 @@
+package algorithm
+
+// calculateDomainScore rates a domain for a pod between 0 and 1 by combining,
+// with the configured weights:
+//   - resource availability: the share of the domain's GPUs that are free
+//   - topology alignment: how much of the job the free GPUs could hold
+//   - domain utilization: the share already in use, so jobs pack into busy
+//     domains and leave whole domains free for large jobs
+//   - historical performance: the share of the domain's nodes that are healthy
+//
+// It uses the config in effect now; a scheduling cycle scores with the
+// weights it started with through scoreDomain.
+func (ts *TopologyScheduler) calculateDomainScore(domain *Domain, gpuReq *GPURequirements) float64 {
+    return scoreDomain(domain, gpuReq, ts.GetConfig().Weights)
+}
+
+// scoreDomain rates a domain for a pod with the given weights
+func scoreDomain(domain *Domain, gpuReq *GPURequirements, weights TopologyScore) float64 {
+    if domain.TotalGPUs == 0 || len(domain.Nodes) == 0 {
+        return 0
+    }
+
+    free := domain.TotalGPUs - domain.UsedGPUs
+    if free < 0 {
+        free = 0
+    }
+    availability := float64(free) / float64(domain.TotalGPUs)
+    utilization := float64(domain.UsedGPUs) / float64(domain.TotalGPUs)
+
+    alignment := 1.0
+    if gpuReq != nil && gpuReq.NodesNeeded > 0 {
+        gpusPerNode := float64(domain.TotalGPUs) / float64(len(domain.Nodes))
+        alignment = float64(free) / (gpusPerNode * float64(gpuReq.NodesNeeded))
+        if alignment > 1 {
+            alignment = 1
+        }
+    }
+
+    return availability*weights.ResourceAvailability +
+        alignment*weights.TopologyAlignment +
+        utilization*weights.DomainUtilization +
+        domain.Health*weights.HistoricalPerf
+}
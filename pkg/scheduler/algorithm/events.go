@@ -0,0 +1,101 @@
+package algorithm
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/meta"
+    "k8s.io/apimachinery/pkg/runtime"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// Reasons of the events the scheduler publishes
+const (
+    EventReasonPlaced             = "TopologyPlaced"
+    EventReasonFallback           = "TopologyFallback"
+    EventReasonRejected           = "TopologyRejected"
+    EventReasonRecoveryMigration  = "RecoveryMigration"
+    EventReasonRecoveryFailed     = "RecoveryMigrationFailed"
+    EventReasonNodeFailed         = "NodeFailed"
+    EventReasonNodeQuarantined    = "NodeQuarantined"
+    EventReasonDomainStateChanged = "DomainStateChanged"
+)
+
+const (
+    // eventDedupWindow is how long a repeat of an identical event is dropped
+    eventDedupWindow = 5 * time.Minute
+    // eventDedupPruneSize is how many remembered events trigger pruning
+    eventDedupPruneSize = 1024
+)
+
+// EventRecorder is the part of record.EventRecorder the scheduler uses
+type EventRecorder interface {
+    Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{})
+}
+
+// EventEmitter publishes events through a recorder, dropping repeats of an
+// identical event on the same object within the dedup window. A pod retried
+// every few seconds then gets one event per decision instead of a flood.
+type EventEmitter struct {
+    mu       sync.Mutex
+    recorder EventRecorder
+    seen     map[string]time.Time
+}
+
+func NewEventEmitter() *EventEmitter {
+    return &EventEmitter{seen: make(map[string]time.Time)}
+}
+
+// SetEventRecorder makes the scheduler publish events; nil disables them
+func (ts *TopologyScheduler) SetEventRecorder(recorder EventRecorder) {
+    ts.events.mu.Lock()
+    defer ts.events.mu.Unlock()
+    ts.events.recorder = recorder
+}
+
+func (e *EventEmitter) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+    message := fmt.Sprintf(messageFmt, args...)
+    key := eventObjectKey(object) + "/" + reason + "/" + message
+    now := time.Now()
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if e.recorder == nil {
+        return
+    }
+    if last, exists := e.seen[key]; exists && now.Sub(last) < eventDedupWindow {
+        return
+    }
+    if len(e.seen) >= eventDedupPruneSize {
+        for k, last := range e.seen {
+            if now.Sub(last) >= eventDedupWindow {
+                delete(e.seen, k)
+            }
+        }
+    }
+    e.seen[key] = now
+    e.recorder.Eventf(object, eventtype, reason, "%s", message)
+}
+
+func eventObjectKey(object runtime.Object) string {
+    if ref, ok := object.(*v1.ObjectReference); ok {
+        return ref.Kind + "/" + ref.Namespace + "/" + ref.Name
+    }
+    accessor, err := meta.Accessor(object)
+    if err != nil {
+        return fmt.Sprintf("%T", object)
+    }
+    return string(accessor.GetUID())
+}
+
+// domainRef refers events about a domain to its DomainConfig
+func domainRef(domainName string) *v1.ObjectReference {
+    return &v1.ObjectReference{
+        Kind:       "DomainConfig",
+        APIVersion: v1alpha1.SchemeGroupVersion.String(),
+        Name:       domainName,
+    }
+}
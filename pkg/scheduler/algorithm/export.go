@@ -0,0 +1,211 @@
+package algorithm
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+)
+
+const (
+    ExportFormatDOT  = "dot"
+    ExportFormatJSON = "json"
+)
+
+// Domain health states reported in the topology export
+const (
+    DomainStateHealthy   = "Healthy"
+    DomainStateFlapping  = "Flapping"
+    DomainStateDegraded  = "Degraded"
+    DomainStateUnhealthy = "Unhealthy"
+)
+
+// TopologyExport is a snapshot of the topology the scheduler currently believes in
+type TopologyExport struct {
+    Domains          []DomainExport     `json:"domains"`
+    SpineConnections []ConnectionExport `json:"spineConnections"`
+    Links            []Link             `json:"links"`
+    LastUpdated      time.Time          `json:"lastUpdated"`
+}
+
+// DomainExport describes a single leaf domain with its live utilization overlay
+type DomainExport struct {
+    Name        string       `json:"name"`
+    LeafSwitch  string       `json:"leafSwitch,omitempty"`
+    SpineSwitch string       `json:"spineSwitch,omitempty"`
+    TotalGPUs   int          `json:"totalGPUs"`
+    UsedGPUs    int          `json:"usedGPUs"`
+    State       string       `json:"state"`
+    Health      float64      `json:"health"`
+    RecentFlaps int          `json:"recentFlaps"`
+    LinkState   string       `json:"linkState,omitempty"`
+    Lifecycle   string       `json:"lifecycle,omitempty"`
+    // SafeToService is set once a draining domain has no GPU pods left
+    SafeToService bool         `json:"safeToService,omitempty"`
+    Nodes         []NodeExport `json:"nodes"`
+    Jobs          []string     `json:"jobs,omitempty"`
+}
+
+// NodeExport describes a node's membership in a domain
+type NodeExport struct {
+    Name    string `json:"name"`
+    Healthy bool   `json:"healthy"`
+}
+
+// ConnectionExport describes a spine connection between two domains
+type ConnectionExport struct {
+    Source string `json:"source"`
+    Target string `json:"target"`
+}
+
+func (te *TopologyExport) sort() {
+    sort.Slice(te.Domains, func(i, j int) bool {
+        return te.Domains[i].Name < te.Domains[j].Name
+    })
+    for _, domain := range te.Domains {
+        sort.Slice(domain.Nodes, func(i, j int) bool {
+            return domain.Nodes[i].Name < domain.Nodes[j].Name
+        })
+        sort.Strings(domain.Jobs)
+    }
+    sort.Slice(te.SpineConnections, func(i, j int) bool {
+        if te.SpineConnections[i].Source != te.SpineConnections[j].Source {
+            return te.SpineConnections[i].Source < te.SpineConnections[j].Source
+        }
+        return te.SpineConnections[i].Target < te.SpineConnections[j].Target
+    })
+}
+
+func (te *TopologyExport) WriteJSON(w io.Writer) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(te)
+}
+
+// WriteDOT renders the topology as a Graphviz digraph. Each domain is drawn as a
+// cluster of its nodes, labelled with GPU usage, health and placed jobs.
+func (te *TopologyExport) WriteDOT(w io.Writer) error {
+    var b strings.Builder
+
+    b.WriteString("digraph topology {\n")
+    b.WriteString("    compound=true;\n")
+    b.WriteString("    node [shape=box, style=filled, fontname=\"Helvetica\"];\n")
+
+    spines := make(map[string]bool)
+    for i, domain := range te.Domains {
+        fmt.Fprintf(&b, "    subgraph cluster_%d {\n", i)
+        fmt.Fprintf(&b, "        label=%q;\n", domainLabel(domain))
+        fmt.Fprintf(&b, "        style=filled;\n        fillcolor=%q;\n", healthColor(domain.Health))
+        fmt.Fprintf(&b, "        %q [shape=point, style=invis];\n", domainAnchor(domain.Name))
+        for _, node := range domain.Nodes {
+            color := "white"
+            if !node.Healthy {
+                color = "lightcoral"
+            }
+            fmt.Fprintf(&b, "        %q [fillcolor=%q];\n", node.Name, color)
+        }
+        b.WriteString("    }\n")
+
+        if domain.SpineSwitch != "" {
+            spines[domain.SpineSwitch] = true
+        }
+    }
+
+    spineNames := make([]string, 0, len(spines))
+    for spine := range spines {
+        spineNames = append(spineNames, spine)
+    }
+    sort.Strings(spineNames)
+    for _, spine := range spineNames {
+        fmt.Fprintf(&b, "    %q [shape=ellipse, fillcolor=\"lightblue\"];\n", spine)
+    }
+
+    for i, domain := range te.Domains {
+        if domain.SpineSwitch == "" {
+            continue
+        }
+        fmt.Fprintf(&b, "    %q -> %q [lhead=cluster_%d, dir=none, color=%q];\n",
+            domain.SpineSwitch, domainAnchor(domain.Name), i, linkColor(domain.LinkState))
+    }
+
+    for _, conn := range te.SpineConnections {
+        fmt.Fprintf(&b, "    %q -> %q [style=dashed];\n",
+            domainAnchor(conn.Source), domainAnchor(conn.Target))
+    }
+
+    b.WriteString("}\n")
+
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+func domainAnchor(domainName string) string {
+    return "domain:" + domainName
+}
+
+func domainLabel(domain DomainExport) string {
+    label := fmt.Sprintf("%s\nGPUs %d/%d\n%s %.0f%%",
+        domain.Name, domain.UsedGPUs, domain.TotalGPUs, domain.State, domain.Health*100)
+    if domain.Lifecycle != "" && domain.Lifecycle != string(DomainActive) {
+        label += "\n" + domain.Lifecycle
+    }
+    if len(domain.Jobs) > 0 {
+        label += "\njobs: " + strings.Join(domain.Jobs, ", ")
+    }
+    return label
+}
+
+func linkColor(state string) string {
+    switch LinkState(state) {
+    case LinkStateDown:
+        return "red"
+    case LinkStateDegraded:
+        return "orange"
+    default:
+        return "black"
+    }
+}
+
+func healthColor(health float64) string {
+    switch {
+    case health >= 1.0:
+        return "palegreen"
+    case health >= 0.5:
+        return "khaki"
+    default:
+        return "lightcoral"
+    }
+}
+
+// NewTopologyExportHandler serves the cache topology as JSON or, with
+// ?format=dot, as Graphviz DOT.
+func NewTopologyExportHandler(cache *TopologyCache) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        format := r.URL.Query().Get("format")
+        if format == "" {
+            format = ExportFormatJSON
+        }
+
+        export := cache.Export()
+
+        var err error
+        switch format {
+        case ExportFormatJSON:
+            w.Header().Set("Content-Type", "application/json")
+            err = export.WriteJSON(w)
+        case ExportFormatDOT:
+            w.Header().Set("Content-Type", "text/vnd.graphviz")
+            err = export.WriteDOT(w)
+        default:
+            http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+            return
+        }
+
+        if err != nil {
+            http.Error(w, fmt.Sprintf("failed to export topology: %v", err), http.StatusInternalServerError)
+        }
+    })
+}
@@ -0,0 +1,477 @@
+package algorithm
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    dto "github.com/prometheus/client_model/go"
+    "github.com/prometheus/common/expfmt"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+// GPU health source formats
+const (
+    // GPUHealthFormatDCGM reads the NVIDIA DCGM exporter
+    GPUHealthFormatDCGM = "dcgm"
+    // GPUHealthFormatAMD reads the AMD device-metrics exporter
+    GPUHealthFormatAMD = "amd"
+    // GPUHealthFormatAgent reads topology_scheduler_gpu_healthy{node,gpu,reason}
+    // written by a node agent
+    GPUHealthFormatAgent = "agent"
+)
+
+const (
+    DefaultGPUHealthInterval     = 30 * time.Second
+    DefaultGPUTemperatureLimit   = 90.0
+    DefaultGPUFailurePersistence = 5 * time.Minute
+
+    gpuHealthReadTimeout = 10 * time.Second
+)
+
+// GPUHealthEndpoint is where a health source is read from: an http(s) URL,
+// such as an exporter or a Prometheus /federate endpoint, or a file path
+type GPUHealthEndpoint struct {
+    Format   string
+    Location string
+}
+
+// ParseGPUHealthEndpoint parses format=location, e.g. dcgm=http://prometheus:9090/federate?...
+func ParseGPUHealthEndpoint(spec string) (GPUHealthEndpoint, error) {
+    format, location, ok := strings.Cut(spec, "=")
+    if !ok || location == "" {
+        return GPUHealthEndpoint{}, fmt.Errorf("invalid GPU health source %q, expected format=location", spec)
+    }
+    switch format {
+    case GPUHealthFormatDCGM, GPUHealthFormatAMD, GPUHealthFormatAgent:
+    default:
+        return GPUHealthEndpoint{}, fmt.Errorf("unknown GPU health format %q, expected dcgm, amd or agent", format)
+    }
+    return GPUHealthEndpoint{Format: format, Location: location}, nil
+}
+
+func (e GPUHealthEndpoint) String() string {
+    return e.Format + "=" + e.Location
+}
+
+// GPUHealthPolicy decides when a device is unhealthy and when its pods are moved
+type GPUHealthPolicy struct {
+    Interval time.Duration
+    // TemperatureLimit is the temperature in Celsius above which a GPU is unhealthy
+    TemperatureLimit float64
+    // FailurePersistence is how long a GPU must stay unhealthy before its pods are moved
+    FailurePersistence time.Duration
+}
+
+func DefaultGPUHealthPolicy() GPUHealthPolicy {
+    return GPUHealthPolicy{
+        Interval:           DefaultGPUHealthInterval,
+        TemperatureLimit:   DefaultGPUTemperatureLimit,
+        FailurePersistence: DefaultGPUFailurePersistence,
+    }
+}
+
+// gpuReading is what one read reported about one device
+type gpuReading struct {
+    node    string
+    device  string
+    reasons []string
+    pods    []types.NamespacedName
+}
+
+// gpuDeviceState tracks a device across reads
+type gpuDeviceState struct {
+    healthy bool
+    reason  string
+    since   time.Time
+    pods    []types.NamespacedName
+    // recovered is set once the pods of a persistently bad device were moved
+    recovered bool
+}
+
+// GPUHealthSource polls exporter metrics for per-device GPU health and
+// shrinks node capacity by the unhealthy devices. Devices that stay unhealthy
+// past the failure persistence have their pods moved.
+type GPUHealthSource struct {
+    sync.Mutex
+    scheduler *TopologyScheduler
+    recovery  *RecoveryManager
+    endpoints []GPUHealthEndpoint
+    policy    GPUHealthPolicy
+    client    *http.Client
+    // devices is keyed by node, then device
+    devices map[string]map[string]*gpuDeviceState
+}
+
+func NewGPUHealthSource(ts *TopologyScheduler, endpoints []GPUHealthEndpoint, policy GPUHealthPolicy) *GPUHealthSource {
+    return &GPUHealthSource{
+        scheduler: ts,
+        endpoints: endpoints,
+        policy:    policy,
+        client:    &http.Client{Timeout: gpuHealthReadTimeout},
+        devices:   make(map[string]map[string]*gpuDeviceState),
+    }
+}
+
+// SetRecoveryManager enables moving pods off persistently bad devices. Only
+// the leader should set it.
+func (s *GPUHealthSource) SetRecoveryManager(recovery *RecoveryManager) {
+    s.Lock()
+    defer s.Unlock()
+    s.recovery = recovery
+}
+
+// Run polls the health sources until stopCh is closed
+func (s *GPUHealthSource) Run(stopCh <-chan struct{}) {
+    interval := s.policy.Interval
+    if interval <= 0 {
+        interval = DefaultGPUHealthInterval
+    }
+    wait.Until(s.poll, interval, stopCh)
+}
+
+func (s *GPUHealthSource) poll() {
+    var readings []gpuReading
+    complete := true
+    for _, endpoint := range s.endpoints {
+        endpointReadings, err := s.read(endpoint)
+        if err != nil {
+            klog.Errorf("Failed to read GPU health from %s: %v", endpoint, err)
+            s.scheduler.metrics.IncGPUHealthScrape(endpoint.Format, "error")
+            complete = false
+            continue
+        }
+        s.scheduler.metrics.IncGPUHealthScrape(endpoint.Format, "success")
+        readings = append(readings, endpointReadings...)
+    }
+
+    s.Lock()
+    defer s.Unlock()
+
+    now := time.Now()
+    seen := s.observeLocked(readings, now)
+    // A healthy device missing from the reads is forgotten, unless a source
+    // could not be read. An unhealthy one stays unhealthy: a device that
+    // fell off the bus stops being reported.
+    for nodeName, devices := range s.devices {
+        for id, state := range devices {
+            if complete && state.healthy && !seen[nodeName][id] {
+                delete(devices, id)
+            }
+        }
+    }
+
+    unhealthy := 0
+    for nodeName, devices := range s.devices {
+        s.scheduler.cache.SetGPUHealth(nodeName, deviceHealth(devices))
+        if len(devices) == 0 {
+            delete(s.devices, nodeName)
+        }
+        for id, state := range devices {
+            if state.healthy {
+                continue
+            }
+            unhealthy++
+            if !state.recovered && now.Sub(state.since) >= s.policy.FailurePersistence {
+                s.recoverLocked(nodeName, id, state)
+            }
+        }
+    }
+    s.scheduler.metrics.SetUnhealthyGPUs(unhealthy)
+}
+
+// observeLocked merges the readings into the device states and returns the devices seen
+func (s *GPUHealthSource) observeLocked(readings []gpuReading, now time.Time) map[string]map[string]bool {
+    merged := make(map[string]map[string]*gpuReading)
+    for i := range readings {
+        r := &readings[i]
+        if merged[r.node] == nil {
+            merged[r.node] = make(map[string]*gpuReading)
+        }
+        existing, exists := merged[r.node][r.device]
+        if !exists {
+            merged[r.node][r.device] = r
+            continue
+        }
+        existing.reasons = append(existing.reasons, r.reasons...)
+        existing.pods = append(existing.pods, r.pods...)
+    }
+
+    seen := make(map[string]map[string]bool, len(merged))
+    for nodeName, devices := range merged {
+        seen[nodeName] = make(map[string]bool, len(devices))
+        if s.devices[nodeName] == nil {
+            s.devices[nodeName] = make(map[string]*gpuDeviceState)
+        }
+        for id, r := range devices {
+            seen[nodeName][id] = true
+            healthy := len(r.reasons) == 0
+
+            state, exists := s.devices[nodeName][id]
+            if !exists {
+                state = &gpuDeviceState{healthy: true, since: now}
+                s.devices[nodeName][id] = state
+            }
+            if state.healthy != healthy {
+                state.since = now
+                state.recovered = false
+                if healthy {
+                    klog.Infof("GPU %s on node %s is healthy again", id, nodeName)
+                } else {
+                    klog.Warningf("GPU %s on node %s is unhealthy: %s", id, nodeName, strings.Join(r.reasons, "; "))
+                }
+            }
+            state.healthy = healthy
+            state.reason = strings.Join(r.reasons, "; ")
+            state.pods = uniquePods(r.pods)
+        }
+    }
+    return seen
+}
+
+// recoverLocked moves the pods using a persistently bad device
+func (s *GPUHealthSource) recoverLocked(nodeName, id string, state *gpuDeviceState) {
+    if s.recovery == nil {
+        return
+    }
+    state.recovered = true
+    if len(state.pods) == 0 {
+        klog.V(2).Infof("GPU %s on node %s stayed unhealthy, but no pods are reported using it", id, nodeName)
+        return
+    }
+
+    klog.Warningf("GPU %s on node %s unhealthy for %s, moving %d pod(s)",
+        id, nodeName, s.policy.FailurePersistence, len(state.pods))
+    go func(pods []types.NamespacedName) {
+        if err := s.recovery.HandleGPUFailure(nodeName, pods); err != nil {
+            klog.Errorf("Recovery of GPU %s on node %s failed: %v", id, nodeName, err)
+        }
+    }(append([]types.NamespacedName(nil), state.pods...))
+}
+
+func (s *GPUHealthSource) read(endpoint GPUHealthEndpoint) ([]gpuReading, error) {
+    body, err := s.open(endpoint.Location)
+    if err != nil {
+        return nil, err
+    }
+    defer body.Close()
+
+    var parser expfmt.TextParser
+    families, err := parser.TextToMetricFamilies(body)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse metrics: %v", err)
+    }
+
+    switch endpoint.Format {
+    case GPUHealthFormatDCGM:
+        return parseDCGMHealth(families, s.policy.TemperatureLimit), nil
+    case GPUHealthFormatAMD:
+        return parseAMDHealth(families, s.policy.TemperatureLimit), nil
+    default:
+        return parseAgentHealth(families), nil
+    }
+}
+
+func (s *GPUHealthSource) open(location string) (io.ReadCloser, error) {
+    if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+        return os.Open(location)
+    }
+
+    resp, err := s.client.Get(location)
+    if err != nil {
+        return nil, err
+    }
+    if resp.StatusCode != http.StatusOK {
+        resp.Body.Close()
+        return nil, fmt.Errorf("unexpected status %s", resp.Status)
+    }
+    return resp.Body, nil
+}
+
+// gpuCheck maps one exporter metric to an unhealthy reason, or "" if the value is healthy
+type gpuCheck struct {
+    metric string
+    reason func(value float64) string
+}
+
+// collectGPUHealth applies checks to the metric families. Every device with
+// a sample is reported, so healthy devices are listed too.
+func collectGPUHealth(families map[string]*dto.MetricFamily, checks []gpuCheck, nodeLabels, deviceLabels []string) []gpuReading {
+    var readings []gpuReading
+    for _, check := range checks {
+        family, exists := families[check.metric]
+        if !exists {
+            continue
+        }
+        for _, m := range family.GetMetric() {
+            labels := metricLabels(m)
+            r := gpuReading{
+                node:   firstLabel(labels, nodeLabels),
+                device: firstLabel(labels, deviceLabels),
+            }
+            if r.node == "" || r.device == "" {
+                continue
+            }
+            if reason := check.reason(metricValue(m)); reason != "" {
+                r.reasons = []string{reason}
+            }
+            pod := firstLabel(labels, []string{"pod", "exported_pod"})
+            namespace := firstLabel(labels, []string{"namespace", "exported_namespace"})
+            if pod != "" && namespace != "" {
+                r.pods = []types.NamespacedName{{Namespace: namespace, Name: pod}}
+            }
+            readings = append(readings, r)
+        }
+    }
+    return readings
+}
+
+// fatalXIDs are the XID errors that leave a GPU unusable until it is reset
+// or replaced. Others, such as 13, 31 and 43, are raised by faulting
+// applications and say nothing about the device.
+var fatalXIDs = map[int]bool{
+    48: true, // double-bit ECC error
+    63: true, // ECC page retirement or row remapping
+    64: true, // ECC page retirement or row remapping failure
+    74: true, // NVLink error
+    79: true, // fallen off the bus
+    92: true, // high single-bit ECC error rate
+    94: true, // contained ECC error
+    95: true, // uncontained ECC error
+}
+
+func parseDCGMHealth(families map[string]*dto.MetricFamily, temperatureLimit float64) []gpuReading {
+    return collectGPUHealth(families, []gpuCheck{
+        {"DCGM_FI_DEV_XID_ERRORS", func(v float64) string {
+            if fatalXIDs[int(v)] {
+                return fmt.Sprintf("XID error %.0f", v)
+            }
+            return ""
+        }},
+        {"DCGM_FI_DEV_ECC_DBE_VOL_TOTAL", func(v float64) string {
+            if v > 0 {
+                return fmt.Sprintf("%.0f uncorrectable ECC errors", v)
+            }
+            return ""
+        }},
+        {"DCGM_FI_DEV_GPU_TEMP", temperatureCheck(temperatureLimit)},
+    }, []string{"Hostname", "node", "kubernetes_node"}, []string{"UUID", "gpu"})
+}
+
+func parseAMDHealth(families map[string]*dto.MetricFamily, temperatureLimit float64) []gpuReading {
+    return collectGPUHealth(families, []gpuCheck{
+        {"gpu_ecc_uncorrect_total", func(v float64) string {
+            if v > 0 {
+                return fmt.Sprintf("%.0f uncorrectable ECC errors", v)
+            }
+            return ""
+        }},
+        {"gpu_junction_temperature", temperatureCheck(temperatureLimit)},
+    }, []string{"hostname", "node", "kubernetes_node"}, []string{"gpu_uuid", "gpu_id"})
+}
+
+// parseAgentHealth reads topology_scheduler_gpu_healthy, 1 for a healthy
+// device and 0 otherwise, with the cause in the reason label
+func parseAgentHealth(families map[string]*dto.MetricFamily) []gpuReading {
+    family, exists := families["topology_scheduler_gpu_healthy"]
+    if !exists {
+        return nil
+    }
+
+    var readings []gpuReading
+    for _, m := range family.GetMetric() {
+        labels := metricLabels(m)
+        r := gpuReading{node: labels["node"], device: labels["gpu"]}
+        if r.node == "" || r.device == "" {
+            continue
+        }
+        if metricValue(m) == 0 {
+            reason := labels["reason"]
+            if reason == "" {
+                reason = "reported unhealthy by node agent"
+            }
+            r.reasons = []string{reason}
+        }
+        if labels["pod"] != "" && labels["namespace"] != "" {
+            r.pods = []types.NamespacedName{{Namespace: labels["namespace"], Name: labels["pod"]}}
+        }
+        readings = append(readings, r)
+    }
+    return readings
+}
+
+func temperatureCheck(limit float64) func(float64) string {
+    return func(v float64) string {
+        if limit > 0 && v > limit {
+            return fmt.Sprintf("temperature %.0fC above %.0fC", v, limit)
+        }
+        return ""
+    }
+}
+
+func metricLabels(m *dto.Metric) map[string]string {
+    labels := make(map[string]string, len(m.GetLabel()))
+    for _, pair := range m.GetLabel() {
+        labels[pair.GetName()] = pair.GetValue()
+    }
+    return labels
+}
+
+func metricValue(m *dto.Metric) float64 {
+    switch {
+    case m.Gauge != nil:
+        return m.Gauge.GetValue()
+    case m.Counter != nil:
+        return m.Counter.GetValue()
+    case m.Untyped != nil:
+        return m.Untyped.GetValue()
+    }
+    return 0
+}
+
+func firstLabel(labels map[string]string, names []string) string {
+    for _, name := range names {
+        if value := labels[name]; value != "" {
+            return value
+        }
+    }
+    return ""
+}
+
+// deviceHealth converts device states for the cache, sorted by device ID
+func deviceHealth(devices map[string]*gpuDeviceState) []topology.GPUDevice {
+    health := make([]topology.GPUDevice, 0, len(devices))
+    for id, state := range devices {
+        health = append(health, topology.GPUDevice{
+            ID:      id,
+            Healthy: state.healthy,
+            Reason:  state.reason,
+            Since:   state.since,
+        })
+    }
+    sort.Slice(health, func(i, j int) bool {
+        return health[i].ID < health[j].ID
+    })
+    return health
+}
+
+func uniquePods(pods []types.NamespacedName) []types.NamespacedName {
+    seen := make(map[types.NamespacedName]bool, len(pods))
+    var unique []types.NamespacedName
+    for _, pod := range pods {
+        if !seen[pod] {
+            seen[pod] = true
+            unique = append(unique, pod)
+        }
+    }
+    return unique
+}
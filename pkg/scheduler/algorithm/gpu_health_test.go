@@ -0,0 +1,192 @@
+package algorithm
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    dto "github.com/prometheus/client_model/go"
+    "github.com/prometheus/common/expfmt"
+    "k8s.io/apimachinery/pkg/types"
+)
+
+func parseMetrics(t *testing.T, text string) map[string]*dto.MetricFamily {
+    t.Helper()
+    var parser expfmt.TextParser
+    families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+    if err != nil {
+        t.Fatalf("failed to parse metrics: %v", err)
+    }
+    return families
+}
+
+func TestParseGPUHealth(t *testing.T) {
+    tests := []struct {
+        name    string
+        format  string
+        metrics string
+        want    []gpuReading
+    }{
+        {
+            name:   "dcgm healthy device",
+            format: GPUHealthFormatDCGM,
+            metrics: `DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-0"} 0
+DCGM_FI_DEV_GPU_TEMP{Hostname="n1",UUID="GPU-0"} 60
+`,
+            want: []gpuReading{
+                {node: "n1", device: "GPU-0"},
+                {node: "n1", device: "GPU-0"},
+            },
+        },
+        {
+            name:   "dcgm XID error with the pod using the device",
+            format: GPUHealthFormatDCGM,
+            metrics: `DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-1",exported_pod="train-0",exported_namespace="ml"} 79
+`,
+            want: []gpuReading{{
+                node:    "n1",
+                device:  "GPU-1",
+                reasons: []string{"XID error 79"},
+                pods:    []types.NamespacedName{{Namespace: "ml", Name: "train-0"}},
+            }},
+        },
+        {
+            name:   "dcgm application XID errors leave the device healthy",
+            format: GPUHealthFormatDCGM,
+            metrics: `DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-0"} 13
+DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-1"} 31
+DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-2"} 43
+DCGM_FI_DEV_XID_ERRORS{Hostname="n1",UUID="GPU-3"} 48
+`,
+            want: []gpuReading{
+                {node: "n1", device: "GPU-0"},
+                {node: "n1", device: "GPU-1"},
+                {node: "n1", device: "GPU-2"},
+                {node: "n1", device: "GPU-3", reasons: []string{"XID error 48"}},
+            },
+        },
+        {
+            name:   "dcgm uncorrectable ECC errors and temperature",
+            format: GPUHealthFormatDCGM,
+            metrics: `DCGM_FI_DEV_ECC_DBE_VOL_TOTAL{node="n2",gpu="3"} 2
+DCGM_FI_DEV_GPU_TEMP{node="n2",gpu="4"} 95
+`,
+            want: []gpuReading{
+                {node: "n2", device: "3", reasons: []string{"2 uncorrectable ECC errors"}},
+                {node: "n2", device: "4", reasons: []string{"temperature 95C above 90C"}},
+            },
+        },
+        {
+            name:   "dcgm sample without a node is skipped",
+            format: GPUHealthFormatDCGM,
+            metrics: `DCGM_FI_DEV_XID_ERRORS{UUID="GPU-0"} 79
+`,
+        },
+        {
+            name:   "amd uncorrectable ECC errors",
+            format: GPUHealthFormatAMD,
+            metrics: `gpu_ecc_uncorrect_total{hostname="n3",gpu_id="0"} 1
+gpu_junction_temperature{hostname="n3",gpu_id="0"} 70
+`,
+            want: []gpuReading{
+                {node: "n3", device: "0", reasons: []string{"1 uncorrectable ECC errors"}},
+                {node: "n3", device: "0"},
+            },
+        },
+        {
+            name:   "agent report with and without a reason",
+            format: GPUHealthFormatAgent,
+            metrics: `topology_scheduler_gpu_healthy{node="n4",gpu="0"} 1
+topology_scheduler_gpu_healthy{node="n4",gpu="1",reason="fell off the bus"} 0
+topology_scheduler_gpu_healthy{node="n4",gpu="2"} 0
+`,
+            want: []gpuReading{
+                {node: "n4", device: "0"},
+                {node: "n4", device: "1", reasons: []string{"fell off the bus"}},
+                {node: "n4", device: "2", reasons: []string{"reported unhealthy by node agent"}},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            families := parseMetrics(t, tt.metrics)
+            var got []gpuReading
+            switch tt.format {
+            case GPUHealthFormatDCGM:
+                got = parseDCGMHealth(families, DefaultGPUTemperatureLimit)
+            case GPUHealthFormatAMD:
+                got = parseAMDHealth(families, DefaultGPUTemperatureLimit)
+            default:
+                got = parseAgentHealth(families)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseGPUHealthEndpoint(t *testing.T) {
+    tests := []struct {
+        spec    string
+        want    GPUHealthEndpoint
+        wantErr bool
+    }{
+        {spec: "dcgm=http://dcgm-exporter:9400/metrics", want: GPUHealthEndpoint{Format: GPUHealthFormatDCGM, Location: "http://dcgm-exporter:9400/metrics"}},
+        {spec: "agent=/var/run/gpu-health.prom", want: GPUHealthEndpoint{Format: GPUHealthFormatAgent, Location: "/var/run/gpu-health.prom"}},
+        {spec: "http://dcgm-exporter:9400/metrics", wantErr: true},
+        {spec: "nvml=http://exporter/metrics", wantErr: true},
+        {spec: "amd=", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.spec, func(t *testing.T) {
+            got, err := ParseGPUHealthEndpoint(tt.spec)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestGPUHealthSourceKeepsMissingUnhealthyDevices(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "gpu-health.prom")
+    write := func(metrics string) {
+        if err := os.WriteFile(path, []byte(metrics), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s := NewGPUHealthSource(testTopology(), []GPUHealthEndpoint{{Format: GPUHealthFormatAgent, Location: path}}, DefaultGPUHealthPolicy())
+
+    write(`topology_scheduler_gpu_healthy{node="n1-1",gpu="0"} 1
+topology_scheduler_gpu_healthy{node="n1-1",gpu="1"} 0
+topology_scheduler_gpu_healthy{node="n1-1",gpu="2"} 1
+`)
+    s.poll()
+
+    // Devices 1 and 2 fall off the bus and are no longer reported
+    write(`topology_scheduler_gpu_healthy{node="n1-1",gpu="0"} 1
+`)
+    s.poll()
+
+    if state := s.devices["n1-1"]["1"]; state == nil || state.healthy {
+        t.Errorf("missing unhealthy device 1 is %+v, want it kept unhealthy", state)
+    }
+    if state := s.devices["n1-1"]["2"]; state != nil {
+        t.Errorf("missing healthy device 2 is %+v, want it forgotten", state)
+    }
+
+    write(`topology_scheduler_gpu_healthy{node="n1-1",gpu="0"} 1
+topology_scheduler_gpu_healthy{node="n1-1",gpu="1"} 1
+`)
+    s.poll()
+    if state := s.devices["n1-1"]["1"]; state == nil || !state.healthy {
+        t.Errorf("device 1 reported healthy is %+v, want it healthy", state)
+    }
+}
@@ -0,0 +1,41 @@
+package algorithm
+
+// isDomainHealthy reports whether a domain may take jobs that span more than
+// one node. Single-node jobs only need their node to pass Filter.
+func (ts *TopologyScheduler) isDomainHealthy(domain *Domain) bool {
+    return domain.Health >= ts.GetConfig().Health.MinDomainHealth
+}
+
+// healthScoreFactor scales a domain score down for each recent node health
+// transition in the domain
+func (ts *TopologyScheduler) healthScoreFactor(domain *Domain) float64 {
+    penalty := float64(domain.RecentFlaps) * ts.GetConfig().Health.FlapPenalty
+    if penalty > 1 {
+        return 0
+    }
+    return 1 - penalty
+}
+
+// Score factors for domains whose uplink is impaired. A domain with a down
+// uplink can still run jobs that fit inside it, but is a last resort.
+const (
+    DegradedLinkScoreFactor = 0.5
+    DownLinkScoreFactor     = 0.25
+)
+
+// isDomainReachable reports whether a domain can take part in a placement
+// that spans several domains
+func isDomainReachable(domain *Domain) bool {
+    return domain.LinkState != LinkStateDown
+}
+
+func linkScoreFactor(domain *Domain) float64 {
+    switch domain.LinkState {
+    case LinkStateDown:
+        return DownLinkScoreFactor
+    case LinkStateDegraded:
+        return DegradedLinkScoreFactor
+    default:
+        return 1
+    }
+}
@@ -0,0 +1,289 @@
+package algorithm
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sync"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    batchv1 "k8s.io/api/batch/v1"
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    "k8s.io/apimachinery/pkg/types"
+    utilerrors "k8s.io/apimachinery/pkg/util/errors"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// Pod group labels set by the coscheduling plugin and its scheduler-plugins successor
+var podGroupLabels = []string{
+    "scheduling.x-k8s.io/pod-group",
+    "pod-group.scheduling.sigs.k8s.io",
+}
+
+// podGroupAPIVersion is the API version of scheduler-plugins PodGroups
+const podGroupAPIVersion = "scheduling.x-k8s.io/v1alpha1"
+
+// Reasons reported on the job recovery condition
+const (
+    JobRecoveryReasonMemberReplaced = "MemberReplaced"
+    JobRecoveryReasonJobRestarted   = "JobRestarted"
+    JobRecoveryReasonFailed         = "RecoveryFailed"
+)
+
+// jobGroup is the set of pods of one job lost on a failed node
+type jobGroup struct {
+    key    string
+    policy string
+    pods   []*v1.Pod
+}
+
+// podGroupKey identifies the gang a pod belongs to: its pod group if it has
+// one, otherwise its job
+func podGroupKey(pod *v1.Pod) string {
+    for _, label := range podGroupLabels {
+        if group, ok := pod.Labels[label]; ok && group != "" {
+            return pod.Namespace + "/" + group
+        }
+    }
+    return getJobName(pod)
+}
+
+// recoveryPolicy returns the job's recovery policy, defaulting to replacing the lost members
+func recoveryPolicy(pod *v1.Pod) string {
+    if pod.Annotations[v1alpha1.AnnotationRecoveryPolicy] == v1alpha1.RecoveryPolicyRestartJob {
+        return v1alpha1.RecoveryPolicyRestartJob
+    }
+    return v1alpha1.RecoveryPolicyReplaceMember
+}
+
+// groupPodsByJob groups lost pods by job, highest priority job first
+func groupPodsByJob(pods []*v1.Pod) []*jobGroup {
+    sortPodsByPriority(pods)
+
+    var groups []*jobGroup
+    byKey := make(map[string]*jobGroup)
+    for _, pod := range pods {
+        key := podGroupKey(pod)
+        group, exists := byKey[key]
+        if !exists {
+            group = &jobGroup{key: key, policy: recoveryPolicy(pod)}
+            byKey[key] = group
+            groups = append(groups, group)
+        }
+        // Any member asking for a restart restarts the whole job
+        if recoveryPolicy(pod) == v1alpha1.RecoveryPolicyRestartJob {
+            group.policy = v1alpha1.RecoveryPolicyRestartJob
+        }
+        group.pods = append(group.pods, pod)
+    }
+    return groups
+}
+
+// executeJobPlan runs the migrations planned for one job and reports the
+// outcome on the job's owner
+func (rm *RecoveryManager) executeJobPlan(ctx context.Context, record *RecoveryRecord, jobPlan *JobPlan) error {
+    ctx, span := tracer.Start(ctx, "RecoveryManager.executeJobPlan", trace.WithAttributes(
+        attribute.String("job", jobPlan.Job),
+        attribute.String("policy", string(jobPlan.Policy)),
+        attribute.Int("migrations", len(jobPlan.Migrations)),
+    ))
+    pods := make(map[string]*v1.Pod, len(jobPlan.pods))
+    for _, pod := range jobPlan.pods {
+        pods[pod.Namespace+"/"+pod.Name] = pod
+    }
+
+    var err error
+    if jobPlan.Error != "" {
+        err = fmt.Errorf("%s", jobPlan.Error)
+    } else {
+        var (
+            wg   sync.WaitGroup
+            mu   sync.Mutex
+            errs []error
+        )
+        for _, m := range jobPlan.Migrations {
+            pod, exists := pods[m.Namespace+"/"+m.Pod]
+            if !exists {
+                continue
+            }
+            wg.Add(1)
+            go func(m Migration, pod *v1.Pod) {
+                defer wg.Done()
+                if m.SpareNode != "" && !rm.scheduler.spares.claim(m) {
+                    klog.V(2).Infof("Spare node %s is no longer available for pod %s/%s", m.SpareNode, m.Namespace, m.Pod)
+                }
+                // Lost pods of a failed node cannot be evicted, only deleted
+                force := record.Plan.Trigger == RecoveryTriggerNodeFailure && m.Lost
+                err := rm.migrateLimited(ctx, pod, m.TargetDomain, force)
+                switch {
+                case err == nil:
+                    rm.scheduler.recoveries.setOutcome(record, m.Namespace, m.Pod, MigrationEvicted, nil)
+                    rm.scheduler.events.Eventf(pod, v1.EventTypeNormal, EventReasonRecoveryMigration,
+                        "Moving from node %s to domain %s (recovery %d)", m.FromNode, describeTarget(m), record.ID)
+                    return
+                case errors.Is(err, errRecoveryPaused):
+                    rm.scheduler.recoveries.setOutcome(record, m.Namespace, m.Pod, MigrationSkipped, err)
+                default:
+                    rm.scheduler.recoveries.setOutcome(record, m.Namespace, m.Pod, MigrationFailed, err)
+                }
+                rm.scheduler.events.Eventf(pod, v1.EventTypeWarning, EventReasonRecoveryFailed,
+                    "Not moved from node %s to domain %s (recovery %d): %v", m.FromNode, describeTarget(m), record.ID, err)
+                mu.Lock()
+                errs = append(errs, err)
+                mu.Unlock()
+            }(m, pod)
+        }
+        wg.Wait()
+        err = utilerrors.NewAggregate(errs)
+    }
+
+    status, reason := v1.ConditionTrue, JobRecoveryReasonMemberReplaced
+    message := fmt.Sprintf("replaced %d lost pod(s)", len(jobPlan.Migrations))
+    if jobPlan.Policy == v1alpha1.RecoveryPolicyRestartJob {
+        reason = JobRecoveryReasonJobRestarted
+        if len(jobPlan.Migrations) > 0 {
+            message = fmt.Sprintf("restarted %d pod(s) on domain %s", len(jobPlan.Migrations), jobPlan.Migrations[0].TargetDomain)
+        }
+    }
+    if err != nil {
+        status, reason, message = v1.ConditionFalse, JobRecoveryReasonFailed, err.Error()
+    }
+    klog.Infof("Recovery of job %s (%s): %s", jobPlan.Job, jobPlan.Policy, message)
+    rm.setJobRecoveryCondition(ctx, jobPlan.pods, status, reason, message)
+    endSpan(span, err)
+    return err
+}
+
+// describeTarget names a migration's target domain and the spare it takes, if any
+func describeTarget(m Migration) string {
+    if m.SpareNode != "" {
+        return fmt.Sprintf("%s on spare node %s", m.TargetDomain, m.SpareNode)
+    }
+    return m.TargetDomain
+}
+
+// listJobMembers returns every live pod of the job, including those on
+// healthy nodes. Members are read from the pod informer, selected by their
+// pod group or job label when they have one.
+func (rm *RecoveryManager) listJobMembers(group *jobGroup) ([]*v1.Pod, error) {
+    if rm.podLister == nil {
+        return group.pods, nil
+    }
+    namespace := group.pods[0].Namespace
+    pods, err := rm.podLister.Pods(namespace).List(jobSelector(group.pods[0]))
+    if err != nil {
+        return nil, fmt.Errorf("failed to list pods of job %s: %v", group.key, err)
+    }
+
+    var members []*v1.Pod
+    for _, pod := range pods {
+        if isTerminalPod(pod) {
+            continue
+        }
+        if podGroupKey(pod) == group.key {
+            members = append(members, pod)
+        }
+    }
+    if len(members) == 0 {
+        // The lost pods may already be gone from the informer
+        members = group.pods
+    }
+    return members, nil
+}
+
+// jobSelector selects the pods sharing a pod's pod group or job label. Pods
+// grouped only by their controller are matched on podGroupKey instead.
+func jobSelector(pod *v1.Pod) labels.Selector {
+    for _, label := range podGroupLabels {
+        if group, ok := pod.Labels[label]; ok && group != "" {
+            return labels.SelectorFromSet(labels.Set{label: group})
+        }
+    }
+    if jobName, ok := pod.Labels["job-name"]; ok {
+        return labels.SelectorFromSet(labels.Set{"job-name": jobName})
+    }
+    return labels.Everything()
+}
+
+// jobOwner refers to the object a job's recovery is reported on: its
+// PodGroup, its Job, or else its controller. Standalone pods have none.
+func jobOwner(pod *v1.Pod) *v1.ObjectReference {
+    for _, label := range podGroupLabels {
+        if group, ok := pod.Labels[label]; ok && group != "" {
+            return &v1.ObjectReference{
+                Kind:       "PodGroup",
+                APIVersion: podGroupAPIVersion,
+                Namespace:  pod.Namespace,
+                Name:       group,
+            }
+        }
+    }
+    for _, owner := range pod.OwnerReferences {
+        if owner.Controller != nil && *owner.Controller {
+            return &v1.ObjectReference{
+                Kind:       owner.Kind,
+                APIVersion: owner.APIVersion,
+                Namespace:  pod.Namespace,
+                Name:       owner.Name,
+                UID:        owner.UID,
+            }
+        }
+    }
+    if jobName, ok := pod.Labels["job-name"]; ok {
+        return &v1.ObjectReference{
+            Kind:       "Job",
+            APIVersion: batchv1.SchemeGroupVersion.String(),
+            Namespace:  pod.Namespace,
+            Name:       jobName,
+        }
+    }
+    return nil
+}
+
+// setJobRecoveryCondition reports a job's recovery on its owner rather than
+// on pods that recovery may just have evicted. The owner gets an event, and
+// a Job also gets the JobRecovery condition. Failures are only logged.
+func (rm *RecoveryManager) setJobRecoveryCondition(ctx context.Context, pods []*v1.Pod, status v1.ConditionStatus, reason, message string) {
+    if len(pods) == 0 {
+        return
+    }
+    owner := jobOwner(pods[0])
+    if owner == nil {
+        return
+    }
+    eventType := v1.EventTypeNormal
+    if status != v1.ConditionTrue {
+        eventType = v1.EventTypeWarning
+    }
+    rm.scheduler.events.Eventf(owner, eventType, reason, "%s", message)
+
+    if owner.Kind != "Job" || owner.APIVersion != batchv1.SchemeGroupVersion.String() {
+        return
+    }
+    patch, err := json.Marshal(map[string]interface{}{
+        "status": map[string]interface{}{
+            "conditions": []batchv1.JobCondition{{
+                Type:               v1alpha1.ConditionJobRecovery,
+                Status:             status,
+                LastProbeTime:      metav1.Now(),
+                LastTransitionTime: metav1.Now(),
+                Reason:             reason,
+                Message:            message,
+            }},
+        },
+    })
+    if err != nil {
+        klog.Errorf("Failed to build job recovery condition: %v", err)
+        return
+    }
+    _, err = rm.client.BatchV1().Jobs(owner.Namespace).Patch(ctx, owner.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+    if err != nil {
+        klog.V(2).Infof("Failed to set job recovery condition on job %s/%s: %v", owner.Namespace, owner.Name, err)
+    }
+}
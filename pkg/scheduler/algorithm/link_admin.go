@@ -0,0 +1,65 @@
+package algorithm
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+
+    "k8s.io/klog/v2"
+)
+
+// NewLinkAdminHandler lets operators inspect and override link state.
+//
+//   GET    lists every link
+//   PUT    sets an override from a JSON LinkOverride body
+//   DELETE ?source=a&target=b clears the override, or with &remove=true
+//          removes the link from the graph
+func NewLinkAdminHandler(cache *TopologyCache) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodGet:
+            writeJSON(w, http.StatusOK, cache.GetLinks())
+
+        case http.MethodPut, http.MethodPost:
+            var override LinkOverride
+            if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+                http.Error(w, fmt.Sprintf("invalid link override: %v", err), http.StatusBadRequest)
+                return
+            }
+            if err := cache.SetLinkState(override); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            writeJSON(w, http.StatusOK, override)
+
+        case http.MethodDelete:
+            source := r.URL.Query().Get("source")
+            target := r.URL.Query().Get("target")
+            if source == "" || target == "" {
+                http.Error(w, "source and target are required", http.StatusBadRequest)
+                return
+            }
+            if r.URL.Query().Get("remove") == "true" {
+                if err := cache.RemoveSpineConnection(source, target); err != nil {
+                    http.Error(w, err.Error(), http.StatusNotFound)
+                    return
+                }
+            } else {
+                cache.ClearLinkState(source, target)
+            }
+            w.WriteHeader(http.StatusNoContent)
+
+        default:
+            w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        }
+    })
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(v); err != nil {
+        klog.Errorf("Failed to write response: %v", err)
+    }
+}
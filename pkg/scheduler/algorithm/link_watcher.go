@@ -0,0 +1,117 @@
+package algorithm
+
+import (
+    "sync"
+
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    informers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/informers/externalversions/topology/v1alpha1"
+)
+
+// LinkWatcher applies the link overrides and lifecycle declared on
+// DomainConfigs to the topology cache and clears them when they are removed
+// from the spec
+type LinkWatcher struct {
+    mu      sync.Mutex
+    cache   *TopologyCache
+    applied map[string][]LinkOverride
+}
+
+func NewLinkWatcher(cache *TopologyCache) *LinkWatcher {
+    return &LinkWatcher{
+        cache:   cache,
+        applied: make(map[string][]LinkOverride),
+    }
+}
+
+func (lw *LinkWatcher) Watch(informer informers.DomainConfigInformer) {
+    informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc: func(obj interface{}) {
+            if domain, ok := obj.(*v1alpha1.DomainConfig); ok {
+                lw.sync(domain.Name, declaredLinks(domain))
+                lw.syncLifecycle(domain)
+            }
+        },
+        UpdateFunc: func(oldObj, newObj interface{}) {
+            if domain, ok := newObj.(*v1alpha1.DomainConfig); ok {
+                lw.sync(domain.Name, declaredLinks(domain))
+                lw.syncLifecycle(domain)
+            }
+        },
+        DeleteFunc: func(obj interface{}) {
+            if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+                obj = tombstone.Obj
+            }
+            if domain, ok := obj.(*v1alpha1.DomainConfig); ok {
+                lw.sync(domain.Name, nil)
+                lw.cache.SetDomainLifecycle(domain.Name, "")
+            }
+        },
+    })
+}
+
+func (lw *LinkWatcher) sync(domainName string, links []v1alpha1.LinkSpec) {
+    lw.mu.Lock()
+    defer lw.mu.Unlock()
+
+    desired := make([]LinkOverride, 0, len(links))
+    wanted := make(map[string]bool, len(links))
+    for _, link := range links {
+        override := LinkOverride{
+            Source:        domainName,
+            Target:        link.Peer,
+            CapacityGbps:  link.CapacityGbps,
+            LatencyMicros: link.LatencyMicros,
+            State:         LinkState(link.State),
+            Reason:        link.Reason,
+        }
+        if err := lw.cache.SetLinkState(override); err != nil {
+            klog.Errorf("Invalid link %s <-> %s on DomainConfig %s: %v", domainName, link.Peer, domainName, err)
+            continue
+        }
+        desired = append(desired, override)
+        wanted[linkKey(domainName, link.Peer)] = true
+    }
+
+    for _, previous := range lw.applied[domainName] {
+        if !wanted[linkKey(previous.Source, previous.Target)] {
+            lw.cache.ClearLinkState(previous.Source, previous.Target)
+        }
+    }
+
+    if len(desired) == 0 {
+        delete(lw.applied, domainName)
+        return
+    }
+    lw.applied[domainName] = desired
+}
+
+func (lw *LinkWatcher) syncLifecycle(domain *v1alpha1.DomainConfig) {
+    lifecycle, err := ParseDomainLifecycle(domain.Spec.Lifecycle)
+    if err != nil {
+        klog.Errorf("Invalid lifecycle on DomainConfig %s: %v", domain.Name, err)
+        return
+    }
+    lw.cache.SetDomainLifecycle(domain.Name, lifecycle)
+}
+
+// declaredLinks returns the link overrides of a DomainConfig. A leaf's
+// bandwidth is its uplink capacity unless the uplink is listed explicitly.
+func declaredLinks(domain *v1alpha1.DomainConfig) []v1alpha1.LinkSpec {
+    links := domain.Spec.Links
+    if domain.Spec.Parent == "" || domain.Spec.Bandwidth <= 0 {
+        return links
+    }
+    for _, link := range links {
+        if link.Peer == domain.Spec.Parent {
+            return links
+        }
+    }
+    return append(append([]v1alpha1.LinkSpec(nil), links...), v1alpha1.LinkSpec{
+        Peer:         domain.Spec.Parent,
+        State:        string(LinkStateUp),
+        CapacityGbps: domain.Spec.Bandwidth,
+    })
+}
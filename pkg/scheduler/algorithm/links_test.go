@@ -0,0 +1,95 @@
+package algorithm
+
+import (
+    "testing"
+)
+
+func TestSetLinkState(t *testing.T) {
+    ts := testTopology()
+    cache := ts.cache
+
+    if err := cache.SetLinkState(LinkOverride{Source: "leaf-1", Target: "spine-a", State: "Flaky"}); err == nil {
+        t.Error("invalid link state was accepted")
+    }
+    if err := cache.SetLinkState(LinkOverride{Source: "leaf-1", Target: "leaf-1", State: LinkStateDown}); err == nil {
+        t.Error("link without two distinct endpoints was accepted")
+    }
+
+    // Uplinks cost 1us of latency plus 10us at 100G
+    distance, err := cache.Snapshot().GetDomainDistance("leaf-1", "leaf-2")
+    if err != nil || distance != 22 {
+        t.Fatalf("leaf-1 to leaf-2 distance = %v, %v, want 22", distance, err)
+    }
+
+    if err := cache.SetLinkState(LinkOverride{Source: "spine-a", Target: "leaf-1", State: LinkStateDegraded, Reason: "CRC errors"}); err != nil {
+        t.Fatal(err)
+    }
+    snapshot := cache.Snapshot()
+    if distance, err := snapshot.GetDomainDistance("leaf-1", "leaf-2"); err != nil || distance != 32 {
+        t.Errorf("distance over a degraded uplink = %v, %v, want 32", distance, err)
+    }
+    if leaf1, _ := snapshot.GetDomain("leaf-1"); leaf1.LinkState != LinkStateDegraded {
+        t.Errorf("leaf-1 link state = %s, want %s", leaf1.LinkState, LinkStateDegraded)
+    }
+
+    if err := cache.SetLinkState(LinkOverride{Source: "leaf-1", Target: "spine-a", State: LinkStateDown}); err != nil {
+        t.Fatal(err)
+    }
+    snapshot = cache.Snapshot()
+    if _, err := snapshot.GetDomainDistance("leaf-1", "leaf-2"); err == nil {
+        t.Error("leaf-1 is reachable over a down uplink")
+    }
+    for _, domain := range snapshot.GetConnectedDomains("leaf-2") {
+        if domain.Name == "leaf-1" {
+            t.Error("leaf-2 is still connected to leaf-1 over a down uplink")
+        }
+    }
+
+    cache.ClearLinkState("leaf-1", "spine-a")
+    if distance, err := cache.Snapshot().GetDomainDistance("leaf-1", "leaf-2"); err != nil || distance != 22 {
+        t.Errorf("distance after clearing the override = %v, %v, want 22", distance, err)
+    }
+    for _, link := range cache.GetLinks() {
+        if link.State != LinkStateUp {
+            t.Errorf("link %s <-> %s is %s after clearing, want %s", link.Source, link.Target, link.State, LinkStateUp)
+        }
+    }
+}
+
+func TestLinkOverrideAppliedWhenLinkAppears(t *testing.T) {
+    ts := testTopology()
+    cache := ts.cache
+
+    override := LinkOverride{Source: "leaf-4", Target: "leaf-1", CapacityGbps: 400, State: LinkStateDegraded}
+    if err := cache.SetLinkState(override); err != nil {
+        t.Fatal(err)
+    }
+    if got := len(cache.GetLinks()); got != 4 {
+        t.Fatalf("links after overriding a missing link = %d, want the 4 uplinks", got)
+    }
+
+    if err := cache.AddSpineConnection("leaf-1", "leaf-4"); err != nil {
+        t.Fatal(err)
+    }
+    var found bool
+    for _, link := range cache.GetLinks() {
+        if linkKey(link.Source, link.Target) != linkKey("leaf-1", "leaf-4") {
+            continue
+        }
+        found = true
+        if link.State != LinkStateDegraded || link.CapacityGbps != 400 {
+            t.Errorf("new link is %s at %dG, want %s at 400G", link.State, link.CapacityGbps, LinkStateDegraded)
+        }
+    }
+    if !found {
+        t.Fatal("spine connection leaf-1 <-> leaf-4 was not added")
+    }
+
+    // A degraded 400G link delivers 200G: 1us plus 5us
+    if distance, err := cache.Snapshot().GetDomainDistance("leaf-1", "leaf-4"); err != nil || distance != 6 {
+        t.Errorf("leaf-1 to leaf-4 distance = %v, %v, want 6", distance, err)
+    }
+    if hops, err := cache.Snapshot().GetDomainHops("leaf-2", "leaf-4"); err != nil || hops != 3 {
+        t.Errorf("leaf-2 to leaf-4 hops = %v, %v, want 3", hops, err)
+    }
+}
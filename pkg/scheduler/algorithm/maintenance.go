@@ -0,0 +1,213 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
+    "k8s.io/apimachinery/pkg/api/meta"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    "k8s.io/apimachinery/pkg/util/wait"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    clientset "github.com/nod-ai/topology-aware-scheduler/pkg/generated/clientset/versioned"
+)
+
+// DefaultDrainMaxDisruptedPods is how many pods of a draining domain may be
+// moving at once
+const DefaultDrainMaxDisruptedPods = 4
+
+const drainCheckPeriod = 10 * time.Second
+
+// DomainDrainer moves the GPU pods out of draining domains through the
+// recovery path, a few at a time, and reports on each DomainConfig when its
+// domain is empty and safe to service
+type DomainDrainer struct {
+    scheduler    *TopologyScheduler
+    recovery     *RecoveryManager
+    client       clientset.Interface
+    podLister    corelisters.PodLister
+    maxDisrupted int
+    // reported is the last status written per DomainConfig
+    reported map[string]string
+}
+
+func NewDomainDrainer(ts *TopologyScheduler, recovery *RecoveryManager, client clientset.Interface, maxDisrupted int) *DomainDrainer {
+    if maxDisrupted <= 0 {
+        maxDisrupted = DefaultDrainMaxDisruptedPods
+    }
+    return &DomainDrainer{
+        scheduler:    ts,
+        recovery:     recovery,
+        client:       client,
+        maxDisrupted: maxDisrupted,
+        reported:     make(map[string]string),
+    }
+}
+
+func (d *DomainDrainer) Watch(pods coreinformers.PodInformer) {
+    d.podLister = pods.Lister()
+}
+
+// Run drains domains until stopCh is closed. Only the leader should run it.
+func (d *DomainDrainer) Run(stopCh <-chan struct{}) {
+    if d.podLister == nil {
+        klog.Warningf("Domain drain disabled, the drainer is not watching pods")
+        return
+    }
+    wait.Until(d.check, drainCheckPeriod, stopCh)
+}
+
+func (d *DomainDrainer) check() {
+    pods, err := d.podLister.List(labels.Everything())
+    if err != nil {
+        klog.Errorf("Failed to list pods for domain drain: %v", err)
+        return
+    }
+    byNode := make(map[string][]*v1.Pod)
+    for _, pod := range pods {
+        if pod.Spec.NodeName == "" || isTerminalPod(pod) || !requiresGPU(pod) {
+            continue
+        }
+        byNode[pod.Spec.NodeName] = append(byNode[pod.Spec.NodeName], pod)
+    }
+
+    snapshot := d.scheduler.cache.Snapshot()
+    lifecycles := make(map[string]DomainLifecycle)
+    remaining := make(map[string]int)
+    for _, domain := range sortedDomains(snapshot) {
+        lifecycles[domain.Name] = domain.Lifecycle
+        if !domain.Lifecycle.Drains() {
+            d.report(domain.Name, domain.Lifecycle, 0)
+            continue
+        }
+
+        var nodeNames []string
+        var domainPods []*v1.Pod
+        for _, node := range domain.Nodes {
+            nodeNames = append(nodeNames, node.Name)
+            domainPods = append(domainPods, byNode[node.Name]...)
+        }
+        remaining[domain.Name] = len(domainPods)
+        d.report(domain.Name, domain.Lifecycle, len(domainPods))
+        if len(domainPods) > 0 {
+            d.drain(snapshot, domain, nodeNames, domainPods)
+        }
+    }
+    d.scheduler.metrics.SetDomainLifecycles(lifecycles)
+    d.scheduler.metrics.SetDrainRemaining(remaining)
+}
+
+// drain moves the domain's pods that are not already terminating, keeping
+// the number in flight within the disruption budget. A pod is in flight from
+// its eviction until its replacement is bound. Evictions still honor
+// PodDisruptionBudgets.
+func (d *DomainDrainer) drain(snapshot *TopologySnapshot, domain *Domain, nodeNames []string, pods []*v1.Pod) {
+    outstanding := d.scheduler.recoveries.outstanding(nodeNames)
+    budget := d.maxDisrupted - len(outstanding)
+    var batch []*v1.Pod
+    for _, pod := range pods {
+        if pod.DeletionTimestamp != nil && !outstanding[pod.UID] {
+            budget--
+        }
+    }
+    for _, pod := range pods {
+        if len(batch) >= budget {
+            break
+        }
+        if pod.DeletionTimestamp == nil {
+            batch = append(batch, pod)
+        }
+    }
+    if len(batch) == 0 {
+        return
+    }
+
+    klog.Infof("Draining domain %s: moving %d of %d GPU pod(s)", domain.Name, len(batch), len(pods))
+    ctx, span := tracer.Start(context.Background(), "DomainDrainer.drain", trace.WithAttributes(
+        attribute.String("domain", domain.Name),
+        attribute.Int("pods", len(batch)),
+    ))
+    defer span.End()
+    d.recovery.recoveryLock.Lock()
+    plan, err := d.recovery.planForPods(ctx, snapshot, nodeNames, batch)
+    d.recovery.recoveryLock.Unlock()
+    if err != nil {
+        recordSpanError(span, err)
+        klog.Errorf("Failed to plan drain of domain %s: %v", domain.Name, err)
+        return
+    }
+    plan.Trigger = RecoveryTriggerDrain
+    if err := d.recovery.executePlan(ctx, plan); err != nil {
+        recordSpanError(span, err)
+        klog.Errorf("Failed to drain domain %s: %v", domain.Name, err)
+    }
+}
+
+// report writes the domain's lifecycle and Drained condition to its
+// DomainConfig when they change. Domains without a DomainConfig are skipped.
+func (d *DomainDrainer) report(domainName string, lifecycle DomainLifecycle, remaining int) {
+    state := string(lifecycle)
+    if lifecycle.Drains() {
+        state = fmt.Sprintf("%s/%d", lifecycle, remaining)
+    }
+    if d.reported[domainName] == state {
+        return
+    }
+
+    ctx := context.TODO()
+    config, err := d.client.TopologyV1alpha1().DomainConfigs().Get(ctx, domainName, metav1.GetOptions{})
+    if apierrors.IsNotFound(err) {
+        d.reported[domainName] = state
+        return
+    }
+    if err != nil {
+        klog.Errorf("Failed to get DomainConfig %s: %v", domainName, err)
+        return
+    }
+
+    config = config.DeepCopy()
+    changed := config.Status.Lifecycle != string(lifecycle)
+    config.Status.Lifecycle = string(lifecycle)
+    if lifecycle.Drains() {
+        condition := metav1.Condition{
+            Type:               v1alpha1.ConditionDrained,
+            Status:             metav1.ConditionTrue,
+            Reason:             "Empty",
+            Message:            "no GPU pods left, the domain is safe to service",
+            ObservedGeneration: config.Generation,
+        }
+        if remaining > 0 {
+            condition.Status = metav1.ConditionFalse
+            condition.Reason = "Draining"
+            condition.Message = fmt.Sprintf("%d GPU pod(s) left to move", remaining)
+        }
+        existing := meta.FindStatusCondition(config.Status.Conditions, v1alpha1.ConditionDrained)
+        if existing == nil || existing.Status != condition.Status || existing.Message != condition.Message {
+            meta.SetStatusCondition(&config.Status.Conditions, condition)
+            changed = true
+        }
+    } else if meta.FindStatusCondition(config.Status.Conditions, v1alpha1.ConditionDrained) != nil {
+        meta.RemoveStatusCondition(&config.Status.Conditions, v1alpha1.ConditionDrained)
+        changed = true
+    }
+
+    if changed {
+        if _, err := d.client.TopologyV1alpha1().DomainConfigs().UpdateStatus(ctx, config, metav1.UpdateOptions{}); err != nil {
+            klog.Errorf("Failed to update status of DomainConfig %s: %v", domainName, err)
+            return
+        }
+        if lifecycle.Drains() && remaining == 0 {
+            klog.Infof("Domain %s is drained and safe to service", domainName)
+        }
+    }
+    d.reported[domainName] = state
+}
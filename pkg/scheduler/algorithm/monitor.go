@@ -0,0 +1,448 @@
+package algorithm
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    coordinationv1 "k8s.io/api/coordination/v1"
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    "k8s.io/apimachinery/pkg/util/wait"
+    coordinationinformers "k8s.io/client-go/informers/coordination/v1"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    "k8s.io/client-go/kubernetes"
+    coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/client-go/util/retry"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// Node failure detection defaults. The lease grace matches the node
+// controller's default node-monitor-grace-period.
+const (
+    DefaultNotReadyGracePeriod = time.Minute
+    DefaultLeaseGracePeriod    = 40 * time.Second
+    DefaultFlapThreshold       = 3
+    DefaultQuarantineDuration  = 30 * time.Minute
+
+    monitorCheckPeriod = 5 * time.Second
+)
+
+// Reasons a node is declared failed
+const (
+    NodeFailureNotReady     = "NotReady"
+    NodeFailureLeaseExpired = "LeaseExpired"
+    NodeFailureGPUTaint     = "GPUTaint"
+)
+
+// DefaultGPUFailureTaints are the taints that mark a node's GPUs as failed
+var DefaultGPUFailureTaints = []string{
+    v1alpha1.TaintGPUUnhealthy,
+    "nvidia.com/gpu.unhealthy",
+    "amd.com/gpu.unhealthy",
+}
+
+// NodeFailurePolicy decides when an unhealthy node is declared failed and
+// when a flapping node is quarantined instead of recovered
+type NodeFailurePolicy struct {
+    // NotReadyGracePeriod is how long a node must stay unhealthy before recovery starts
+    NotReadyGracePeriod time.Duration
+    // LeaseGracePeriod is how long a node's heartbeat lease may go unrenewed
+    LeaseGracePeriod time.Duration
+    GPUFailureTaints []string
+    // A node that turns unhealthy FlapThreshold times within FlapWindow is
+    // quarantined for QuarantineDuration. Zero disables quarantine.
+    FlapThreshold      int
+    FlapWindow         time.Duration
+    QuarantineDuration time.Duration
+}
+
+func DefaultNodeFailurePolicy() NodeFailurePolicy {
+    return NodeFailurePolicy{
+        NotReadyGracePeriod: DefaultNotReadyGracePeriod,
+        LeaseGracePeriod:    DefaultLeaseGracePeriod,
+        GPUFailureTaints:    DefaultGPUFailureTaints,
+        FlapThreshold:       DefaultFlapThreshold,
+        FlapWindow:          DefaultFlapWindow,
+        QuarantineDuration:  DefaultQuarantineDuration,
+    }
+}
+
+// nodeFailureState tracks a node between turning unhealthy and being declared failed
+type nodeFailureState struct {
+    // unhealthySince is zero while the node is healthy
+    unhealthySince   time.Time
+    reason           string
+    failed           bool
+    transitions      []time.Time
+    quarantinedUntil time.Time
+}
+
+// quarantineChange is a quarantine taint to add to or remove from a node
+type quarantineChange struct {
+    node        string
+    quarantined bool
+}
+
+// domainObservation is the state of a domain last reported in events
+type domainObservation struct {
+    health    string
+    link      LinkState
+    lifecycle DomainLifecycle
+}
+
+// DomainMonitor detects failed nodes from their Ready condition, heartbeat
+// lease and GPU taints, and hands them to recovery once the grace period has passed
+type DomainMonitor struct {
+    sync.Mutex
+    scheduler  *TopologyScheduler
+    recovery   *RecoveryManager
+    client     kubernetes.Interface
+    policy     NodeFailurePolicy
+    nodeLister corelisters.NodeLister
+    podLister  corelisters.PodLister
+    leases     coordinationlisters.LeaseNamespaceLister
+    nodes      map[string]*nodeFailureState
+    domains    map[string]domainObservation
+}
+
+func NewDomainMonitor(ts *TopologyScheduler) *DomainMonitor {
+    return &DomainMonitor{
+        scheduler: ts,
+        policy:    DefaultNodeFailurePolicy(),
+        nodes:     make(map[string]*nodeFailureState),
+        domains:   make(map[string]domainObservation),
+    }
+}
+
+// GetMonitor returns the scheduler's node failure detector
+func (ts *TopologyScheduler) GetMonitor() *DomainMonitor {
+    return ts.monitor
+}
+
+func (m *DomainMonitor) SetPolicy(policy NodeFailurePolicy) {
+    m.Lock()
+    defer m.Unlock()
+    m.policy = policy
+}
+
+// SetRecoveryManager sets where failed nodes are sent, and the client used to quarantine nodes
+func (m *DomainMonitor) SetRecoveryManager(recovery *RecoveryManager, client kubernetes.Interface) {
+    m.Lock()
+    defer m.Unlock()
+    m.recovery = recovery
+    m.client = client
+}
+
+// Watch reads nodes, their pods and their leases from the given informers.
+// Leases must come from the kube-node-lease namespace.
+func (m *DomainMonitor) Watch(nodes coreinformers.NodeInformer, pods coreinformers.PodInformer, leases coordinationinformers.LeaseInformer) {
+    m.nodeLister = nodes.Lister()
+    m.podLister = pods.Lister()
+    m.leases = leases.Lister().Leases(v1.NamespaceNodeLease)
+}
+
+// Start checks node health until stopCh is closed. Only the leader should
+// run it, since a declared failure starts recovery.
+func (m *DomainMonitor) Start(stopCh <-chan struct{}) {
+    if m.nodeLister == nil {
+        klog.Warningf("Node failure detection disabled, the monitor is not watching nodes")
+        return
+    }
+    wait.Until(m.check, monitorCheckPeriod, stopCh)
+}
+
+func (m *DomainMonitor) check() {
+    nodes, err := m.nodeLister.List(labels.Everything())
+    if err != nil {
+        klog.Errorf("Failed to list nodes for failure detection: %v", err)
+        return
+    }
+
+    m.Lock()
+    now := time.Now()
+    seen := make(map[string]bool, len(nodes))
+    quarantined := 0
+    var changes []quarantineChange
+    for _, node := range nodes {
+        seen[node.Name] = true
+        m.checkNodeLocked(node, now, &changes)
+        if now.Before(m.nodes[node.Name].quarantinedUntil) {
+            quarantined++
+        }
+    }
+    for name := range m.nodes {
+        if !seen[name] {
+            delete(m.nodes, name)
+        }
+    }
+    m.scheduler.metrics.SetQuarantinedNodes(quarantined)
+    m.observeDomainsLocked()
+    client := m.client
+    m.Unlock()
+
+    // Taints are written without the lock, so a slow API server does not
+    // hold up recovery callbacks waiting on the monitor
+    applyQuarantineTaints(client, changes)
+}
+
+// observeDomainsLocked publishes an event on a domain's DomainConfig when its
+// health, links or lifecycle change. Domains seen for the first time are not reported.
+func (m *DomainMonitor) observeDomainsLocked() {
+    seen := make(map[string]bool)
+    for _, domain := range sortedDomains(m.scheduler.cache.Snapshot()) {
+        seen[domain.Name] = true
+        current := domainObservation{
+            health:    domainHealthState(domain),
+            link:      domain.LinkState,
+            lifecycle: domain.Lifecycle,
+        }
+        if current.link == "" {
+            current.link = LinkStateUp
+        }
+        previous, exists := m.domains[domain.Name]
+        m.domains[domain.Name] = current
+        if !exists {
+            continue
+        }
+
+        ref := domainRef(domain.Name)
+        if previous.health != current.health {
+            eventtype := v1.EventTypeWarning
+            if current.health == DomainStateHealthy {
+                eventtype = v1.EventTypeNormal
+            }
+            m.scheduler.events.Eventf(ref, eventtype, EventReasonDomainStateChanged,
+                "Health changed from %s to %s, %d/%d nodes healthy",
+                previous.health, current.health, domain.HealthyNodes, len(domain.Nodes))
+        }
+        if previous.link != current.link {
+            eventtype := v1.EventTypeWarning
+            if current.link == LinkStateUp {
+                eventtype = v1.EventTypeNormal
+            }
+            m.scheduler.events.Eventf(ref, eventtype, EventReasonDomainStateChanged,
+                "Links changed from %s to %s", previous.link, current.link)
+        }
+        if previous.lifecycle != current.lifecycle {
+            m.scheduler.events.Eventf(ref, v1.EventTypeNormal, EventReasonDomainStateChanged,
+                "Lifecycle changed from %s to %s", previous.lifecycle, current.lifecycle)
+        }
+    }
+    for name := range m.domains {
+        if !seen[name] {
+            delete(m.domains, name)
+        }
+    }
+}
+
+// checkNodeLocked updates a node's failure state and appends the quarantine
+// taints to change to changes
+func (m *DomainMonitor) checkNodeLocked(node *v1.Node, now time.Time, changes *[]quarantineChange) {
+    state, exists := m.nodes[node.Name]
+    if !exists {
+        state = &nodeFailureState{}
+        m.nodes[node.Name] = state
+    }
+
+    if !state.quarantinedUntil.IsZero() && !now.Before(state.quarantinedUntil) {
+        state.quarantinedUntil = time.Time{}
+        *changes = append(*changes, quarantineChange{node: node.Name, quarantined: false})
+        klog.Infof("Node %s released from quarantine", node.Name)
+    }
+
+    reason := m.failureReason(node, now)
+    if domain, err := m.scheduler.cache.GetDomainForNode(node.Name); err == nil && domain.Lifecycle == DomainMaintenance {
+        // Nodes in a domain under maintenance are expected to go down
+        reason = ""
+    }
+    if reason == "" {
+        if !state.unhealthySince.IsZero() {
+            klog.Infof("Node %s is healthy again after %s", node.Name, now.Sub(state.unhealthySince).Round(time.Second))
+        }
+        state.unhealthySince = time.Time{}
+        state.reason = ""
+        state.failed = false
+        return
+    }
+
+    if state.unhealthySince.IsZero() {
+        state.unhealthySince = now
+        state.reason = reason
+        state.transitions = append(state.transitions, now)
+        state.transitions = state.transitions[len(state.transitions)-recentTransitions(state.transitions, now, m.policy.FlapWindow):]
+        klog.V(2).Infof("Node %s is unhealthy (%s), waiting %s before declaring it failed", node.Name, reason, m.policy.NotReadyGracePeriod)
+
+        if m.policy.FlapThreshold > 0 && len(state.transitions) >= m.policy.FlapThreshold && state.quarantinedUntil.IsZero() {
+            state.quarantinedUntil = now.Add(m.policy.QuarantineDuration)
+            *changes = append(*changes, quarantineChange{node: node.Name, quarantined: true})
+            m.scheduler.events.Eventf(node, v1.EventTypeWarning, EventReasonNodeQuarantined,
+                "Turned unhealthy %d times within %s, quarantined until %s",
+                len(state.transitions), m.policy.FlapWindow, state.quarantinedUntil.Format(time.RFC3339))
+            klog.Warningf("Node %s turned unhealthy %d times within %s, quarantined until %s",
+                node.Name, len(state.transitions), m.policy.FlapWindow, state.quarantinedUntil.Format(time.RFC3339))
+        }
+    }
+
+    if state.failed || now.Sub(state.unhealthySince) < m.policy.NotReadyGracePeriod {
+        return
+    }
+    if now.Before(state.quarantinedUntil) {
+        // A flapping node would only trigger migrations back and forth
+        klog.V(2).Infof("Node %s is quarantined, not recovering its pods", node.Name)
+        return
+    }
+
+    // A node refused while recovery is paused is not declared failed, so it
+    // is declared and recovered once recovery resumes
+    if m.recovery != nil {
+        if err := m.recovery.admitNodeFailure(node.Name); err != nil {
+            klog.V(2).Infof("Node %s has been unhealthy for %s: %v",
+                node.Name, now.Sub(state.unhealthySince).Round(time.Second), err)
+            return
+        }
+    }
+
+    state.failed = true
+    m.scheduler.metrics.IncNodeFailure(state.reason)
+    klog.Warningf("Node %s declared failed (%s) after %s", node.Name, state.reason, now.Sub(state.unhealthySince).Round(time.Second))
+    if m.recovery == nil {
+        return
+    }
+
+    pods := m.podsOnNode(node.Name)
+    m.scheduler.events.Eventf(node, v1.EventTypeWarning, EventReasonNodeFailed,
+        "Declared failed (%s) after %s, recovering %d pod(s)",
+        state.reason, now.Sub(state.unhealthySince).Round(time.Second), len(pods))
+    go func(node *v1.Node) {
+        err := m.recovery.recoverNode(node, pods)
+        if err == nil {
+            return
+        }
+        klog.Errorf("Recovery of node %s failed: %v", node.Name, err)
+        // The breaker opened while the node was recovered; the pods it
+        // skipped are recovered once recovery resumes
+        if m.recovery.breaker.allow() != nil {
+            m.redeclareAfterResume(node.Name)
+        }
+    }(node.DeepCopy())
+}
+
+// redeclareAfterResume clears a node's failed state so that, while it stays
+// unhealthy, it is declared failed again as soon as recovery resumes
+func (m *DomainMonitor) redeclareAfterResume(nodeName string) {
+    m.Lock()
+    defer m.Unlock()
+
+    if state, exists := m.nodes[nodeName]; exists {
+        state.failed = false
+    }
+}
+
+// failureReason returns why a node is unhealthy, or "" if it is healthy
+func (m *DomainMonitor) failureReason(node *v1.Node, now time.Time) string {
+    gpuTaints := make(map[string]bool, len(m.policy.GPUFailureTaints))
+    for _, key := range m.policy.GPUFailureTaints {
+        gpuTaints[key] = true
+    }
+    for _, taint := range node.Spec.Taints {
+        if gpuTaints[taint.Key] {
+            return NodeFailureGPUTaint
+        }
+    }
+
+    ready := false
+    for _, condition := range node.Status.Conditions {
+        if condition.Type == v1.NodeReady {
+            ready = condition.Status == v1.ConditionTrue
+        }
+    }
+    if !ready {
+        return NodeFailureNotReady
+    }
+
+    if m.leases != nil {
+        lease, err := m.leases.Get(node.Name)
+        if err == nil && leaseExpired(lease, now, m.policy.LeaseGracePeriod) {
+            return NodeFailureLeaseExpired
+        }
+    }
+    return ""
+}
+
+func leaseExpired(lease *coordinationv1.Lease, now time.Time, grace time.Duration) bool {
+    if lease.Spec.RenewTime == nil {
+        return false
+    }
+    return now.Sub(lease.Spec.RenewTime.Time) > grace
+}
+
+func (m *DomainMonitor) podsOnNode(nodeName string) []*v1.Pod {
+    all, err := m.podLister.List(labels.Everything())
+    if err != nil {
+        klog.Errorf("Failed to list pods on node %s: %v", nodeName, err)
+        return nil
+    }
+
+    var pods []*v1.Pod
+    for _, pod := range all {
+        if pod.Spec.NodeName == nodeName && !isTerminalPod(pod) {
+            pods = append(pods, pod.DeepCopy())
+        }
+    }
+    return pods
+}
+
+// applyQuarantineTaints keeps new pods off quarantined nodes, in the order
+// the changes were made
+func applyQuarantineTaints(client kubernetes.Interface, changes []quarantineChange) {
+    if client == nil {
+        return
+    }
+
+    taint := v1.Taint{Key: v1alpha1.TaintQuarantined, Effect: v1.TaintEffectNoSchedule}
+    for _, change := range changes {
+        if err := setNodeTaint(context.Background(), client, change.node, taint, change.quarantined); err != nil {
+            klog.Errorf("Failed to update quarantine taint on node %s: %v", change.node, err)
+        }
+    }
+}
+
+// setNodeTaint adds or removes one taint, keyed by its key and effect. The
+// node is read and updated at its resourceVersion so taints set by others in
+// the meantime are never overwritten.
+func setNodeTaint(ctx context.Context, client kubernetes.Interface, nodeName string, taint v1.Taint, present bool) error {
+    return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+        node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+        if err != nil {
+            return err
+        }
+
+        taints := make([]v1.Taint, 0, len(node.Spec.Taints)+1)
+        found := false
+        for _, existing := range node.Spec.Taints {
+            if existing.Key == taint.Key && existing.Effect == taint.Effect {
+                found = true
+                if !present {
+                    continue
+                }
+            }
+            taints = append(taints, existing)
+        }
+        if found == present {
+            return nil
+        }
+        if present {
+            taints = append(taints, taint)
+        }
+
+        node = node.DeepCopy()
+        node.Spec.Taints = taints
+        _, err = client.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+        return err
+    })
+}
@@ -0,0 +1,184 @@
+package algorithm
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/client-go/informers"
+    "k8s.io/client-go/kubernetes/fake"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// testMonitor returns a monitor on testTopology that hands failed nodes to a
+// recovery manager with no pods to move
+func testMonitor(t *testing.T, policy NodeFailurePolicy) (*DomainMonitor, *RecoveryManager) {
+    t.Helper()
+    ts := testTopology()
+    client := fake.NewSimpleClientset()
+    factory := informers.NewSharedInformerFactory(client, 0)
+
+    recovery := NewRecoveryManager(NewDomainManager(), ts, client)
+    monitor := NewDomainMonitor(ts)
+    monitor.SetPolicy(policy)
+    monitor.SetRecoveryManager(recovery, client)
+    monitor.podLister = factory.Core().V1().Pods().Lister()
+    return monitor, recovery
+}
+
+func notReadyNode(name, leaf, spine string) *v1.Node {
+    node := testNode(name, leaf, spine)
+    node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}
+    return node
+}
+
+func TestMonitorDefersFailuresWhileRecoveryIsPaused(t *testing.T) {
+    policy := DefaultNodeFailurePolicy()
+    policy.FlapThreshold = 0
+    monitor, recovery := testMonitor(t, policy)
+    recovery.restoreBreaker(&BreakerCheckpoint{Open: true, Reason: "too many failures"})
+
+    node := notReadyNode("n1-1", "leaf-1", "spine-a")
+    start := time.Now()
+    var changes []quarantineChange
+    monitor.Lock()
+    monitor.checkNodeLocked(node, start, &changes)
+    monitor.checkNodeLocked(node, start.Add(2*policy.NotReadyGracePeriod), &changes)
+    failed := monitor.nodes["n1-1"].failed
+    monitor.Unlock()
+    if failed {
+        t.Fatal("node was declared failed while recovery was paused")
+    }
+    if got := recovery.Breaker().Status().RecentNodeFailures; got != 0 {
+        t.Errorf("node failures counted while paused = %d, want 0", got)
+    }
+
+    recovery.ResumeRecovery()
+    monitor.Lock()
+    monitor.checkNodeLocked(node, start.Add(3*policy.NotReadyGracePeriod), &changes)
+    failed = monitor.nodes["n1-1"].failed
+    monitor.Unlock()
+    if !failed {
+        t.Error("node was not declared failed once recovery resumed")
+    }
+    if got := recovery.Breaker().Status().RecentNodeFailures; got != 1 {
+        t.Errorf("node failures after resuming = %d, want 1", got)
+    }
+}
+
+func TestMonitorGracePeriod(t *testing.T) {
+    policy := DefaultNodeFailurePolicy()
+    policy.FlapThreshold = 0
+    monitor := NewDomainMonitor(testTopology())
+    monitor.SetPolicy(policy)
+
+    start := time.Now()
+    steps := []struct {
+        name   string
+        node   *v1.Node
+        offset time.Duration
+        failed bool
+    }{
+        {name: "turns unhealthy", node: notReadyNode("n1-1", "leaf-1", "spine-a")},
+        {name: "within the grace period", node: notReadyNode("n1-1", "leaf-1", "spine-a"), offset: policy.NotReadyGracePeriod / 2},
+        {name: "grace period passed", node: notReadyNode("n1-1", "leaf-1", "spine-a"), offset: policy.NotReadyGracePeriod, failed: true},
+        {name: "healthy again", node: testNode("n1-1", "leaf-1", "spine-a"), offset: 2 * policy.NotReadyGracePeriod},
+        // The grace period starts over
+        {name: "unhealthy again", node: notReadyNode("n1-1", "leaf-1", "spine-a"), offset: 3 * policy.NotReadyGracePeriod},
+        {name: "not yet failed again", node: notReadyNode("n1-1", "leaf-1", "spine-a"), offset: 3*policy.NotReadyGracePeriod + policy.NotReadyGracePeriod/2},
+    }
+
+    var changes []quarantineChange
+    for _, step := range steps {
+        monitor.Lock()
+        monitor.checkNodeLocked(step.node, start.Add(step.offset), &changes)
+        failed := monitor.nodes["n1-1"].failed
+        monitor.Unlock()
+        if failed != step.failed {
+            t.Errorf("%s: failed = %v, want %v", step.name, failed, step.failed)
+        }
+    }
+    if len(changes) != 0 {
+        t.Errorf("quarantine changes with quarantine disabled: %v", changes)
+    }
+}
+
+func TestMonitorQuarantinesFlappingNode(t *testing.T) {
+    policy := DefaultNodeFailurePolicy()
+    policy.FlapThreshold = 3
+    policy.FlapWindow = 10 * time.Minute
+    policy.QuarantineDuration = 30 * time.Minute
+    monitor := NewDomainMonitor(testTopology())
+    monitor.SetPolicy(policy)
+
+    healthy := testNode("n1-1", "leaf-1", "spine-a")
+    unhealthy := notReadyNode("n1-1", "leaf-1", "spine-a")
+    start := time.Now()
+    steps := []struct {
+        name        string
+        node        *v1.Node
+        offset      time.Duration
+        failed      bool
+        quarantined bool
+        // changes are the quarantine taint changes made by the step
+        changes []quarantineChange
+    }{
+        {name: "first flap", node: unhealthy},
+        {name: "recovers", node: healthy, offset: time.Minute},
+        {name: "second flap", node: unhealthy, offset: 2 * time.Minute},
+        {name: "recovers again", node: healthy, offset: 3 * time.Minute},
+        {name: "third flap quarantines", node: unhealthy, offset: 4 * time.Minute, quarantined: true,
+            changes: []quarantineChange{{node: "n1-1", quarantined: true}}},
+        {name: "quarantined node is not recovered", node: unhealthy, offset: 10 * time.Minute, quarantined: true},
+        {name: "released and declared failed", node: unhealthy, offset: 35 * time.Minute, failed: true,
+            changes: []quarantineChange{{node: "n1-1", quarantined: false}}},
+    }
+
+    for _, step := range steps {
+        var changes []quarantineChange
+        now := start.Add(step.offset)
+        monitor.Lock()
+        monitor.checkNodeLocked(step.node, now, &changes)
+        state := *monitor.nodes["n1-1"]
+        monitor.Unlock()
+
+        if state.failed != step.failed {
+            t.Errorf("%s: failed = %v, want %v", step.name, state.failed, step.failed)
+        }
+        if quarantined := now.Before(state.quarantinedUntil); quarantined != step.quarantined {
+            t.Errorf("%s: quarantined = %v, want %v", step.name, quarantined, step.quarantined)
+        }
+        if len(changes) != len(step.changes) || (len(changes) > 0 && changes[0] != step.changes[0]) {
+            t.Errorf("%s: quarantine changes = %v, want %v", step.name, changes, step.changes)
+        }
+    }
+}
+
+func TestMonitorCheckAppliesQuarantineTaints(t *testing.T) {
+    policy := DefaultNodeFailurePolicy()
+    policy.FlapThreshold = 1
+    node := notReadyNode("n1-1", "leaf-1", "spine-a")
+    client := fake.NewSimpleClientset(node)
+    factory := informers.NewSharedInformerFactory(client, 0)
+    if err := factory.Core().V1().Nodes().Informer().GetIndexer().Add(node); err != nil {
+        t.Fatal(err)
+    }
+
+    monitor := NewDomainMonitor(testTopology())
+    monitor.SetPolicy(policy)
+    monitor.SetRecoveryManager(nil, client)
+    monitor.nodeLister = factory.Core().V1().Nodes().Lister()
+    monitor.check()
+
+    updated, err := client.CoreV1().Nodes().Get(context.Background(), "n1-1", metav1.GetOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    quarantine := v1.Taint{Key: v1alpha1.TaintQuarantined, Effect: v1.TaintEffectNoSchedule}
+    if !hasTaint(updated, quarantine) {
+        t.Errorf("flapping node taints = %v, want the quarantine taint", updated.Spec.Taints)
+    }
+}
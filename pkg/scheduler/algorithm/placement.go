@@ -1,43 +1,64 @@
-package scheduler
-
-import (
-    "context"
-    "sort"
-    "k8s.io/api/core/v1"
-)
-
-type PlacementManager struct {
-    topology *TopologyManager
-    scorer   *Scorer
-}
-
-func NewPlacementManager(topology *TopologyManager, scorer *Scorer) *PlacementManager {
-    return &PlacementManager{
-        topology: topology,
-        scorer:   scorer,
-    }
-}
-
-func (pm *PlacementManager) FindOptimalPlacement(
-    ctx context.Context,
-    pod *v1.Pod,
-    nodes []*v1.Node,
-    constraints *SchedulingConstraints,
-) ([]*v1.Node, error) {
-    requirements := extractResourceRequirements(pod)
-    
-    // Score all nodes
-    nodeScores := make(map[string]float64)
-    for _, node := range nodes {
-        score := pm.scorer.ScoreNode(node, requirements, constraints)
-        nodeScores[node.Name] = score
-    }
-
-    // Sort nodes by score
-    sortedNodes := sortNodesByScore(nodeScores)
-    
-    // Group nodes by domain
-    domainGroups := pm.groupNodesByDomain(sortedNodes)
-    
-    return pm.selectOptimalNodes(domainGroups, requirements, constraints)
-}
+package scheduler
+
+import (
+    "context"
+    "sort"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    "k8s.io/api/core/v1"
+)
+
+var placementTracer = otel.Tracer("github.com/nod-ai/topology-aware-scheduler")
+
+type PlacementManager struct {
+    topology *TopologyManager
+    scorer   *Scorer
+}
+
+func NewPlacementManager(topology *TopologyManager, scorer *Scorer) *PlacementManager {
+    return &PlacementManager{
+        topology: topology,
+        scorer:   scorer,
+    }
+}
+
+func (pm *PlacementManager) FindOptimalPlacement(
+    ctx context.Context,
+    pod *v1.Pod,
+    nodes []*v1.Node,
+    constraints *SchedulingConstraints,
+) ([]*v1.Node, error) {
+    ctx, span := placementTracer.Start(ctx, "PlacementManager.FindOptimalPlacement",
+        trace.WithAttributes(
+            attribute.String("pod", pod.Namespace+"/"+pod.Name),
+            attribute.Int("candidate_nodes", len(nodes)),
+        ))
+    defer span.End()
+
+    requirements := extractResourceRequirements(pod)
+    
+    // Score all nodes
+    _, scoreSpan := placementTracer.Start(ctx, "ScoreNodes")
+    nodeScores := make(map[string]float64)
+    for _, node := range nodes {
+        score := pm.scorer.ScoreNode(node, requirements, constraints)
+        nodeScores[node.Name] = score
+    }
+    scoreSpan.End()
+
+    // Sort nodes by score
+    sortedNodes := sortNodesByScore(nodeScores)
+    
+    // Group nodes by domain
+    domainGroups := pm.groupNodesByDomain(sortedNodes)
+    
+    _, selectSpan := placementTracer.Start(ctx, "SelectOptimalNodes")
+    selected, err := pm.selectOptimalNodes(domainGroups, requirements, constraints)
+    if err != nil {
+        selectSpan.RecordError(err)
+        span.RecordError(err)
+    }
+    selectSpan.End()
+    return selected, err
+}
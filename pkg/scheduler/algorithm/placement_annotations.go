@@ -0,0 +1,80 @@
+package algorithm
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    v1 "k8s.io/api/core/v1"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// placementAnnotations describes where a pod was placed relative to the other
+// pods of its job. Peers are the job's pods bound or reserved so far.
+func (ts *TopologyScheduler) placementAnnotations(pod *v1.Pod, nodeName string, gpuReq *GPURequirements) (map[string]string, error) {
+    snapshot := ts.cache.Snapshot()
+    domain, err := snapshot.GetDomainForNode(nodeName)
+    if err != nil {
+        return nil, err
+    }
+
+    jobNodes := ts.cache.GetJobNodes(getJobName(pod))
+    jobNodes[pod.UID] = nodeName
+
+    peerSet := make(map[string]bool)
+    domains := make(map[string]bool)
+    var score float64
+    for uid, peerNode := range jobNodes {
+        if uid != pod.UID {
+            peerSet[peerNode] = true
+        }
+        peerDomain, err := snapshot.GetDomainForNode(peerNode)
+        if err != nil {
+            peerDomain = domain
+        }
+        domains[peerDomain.Name] = true
+        score += ts.calculateDomainScore(peerDomain, gpuReq) *
+            ts.healthScoreFactor(peerDomain) * linkScoreFactor(peerDomain)
+    }
+    score = score / float64(len(jobNodes)) * 100
+
+    names := make([]string, 0, len(domains))
+    for name := range domains {
+        names = append(names, name)
+    }
+    // maxHops is -1 when some domains of the job cannot reach each other
+    maxHops := 0
+    for i := range names {
+        for j := i + 1; j < len(names); j++ {
+            hops, err := snapshot.GetDomainHops(names[i], names[j])
+            if err != nil {
+                maxHops = -1
+                break
+            }
+            maxHops = max(maxHops, hops)
+        }
+        if maxHops < 0 {
+            break
+        }
+    }
+
+    peers := make([]string, 0, len(peerSet))
+    for peer := range peerSet {
+        peers = append(peers, peer)
+    }
+    sort.Strings(peers)
+
+    annotations := map[string]string{
+        v1alpha1.AnnotationPlacedStrategy: string(ts.getPlacementStrategy(gpuReq)),
+        v1alpha1.AnnotationPlacedLeaf:     domain.Name,
+        v1alpha1.AnnotationPlacedScore:    fmt.Sprintf("%.1f", score),
+        v1alpha1.AnnotationPlacedMaxHops:  strconv.Itoa(maxHops),
+        v1alpha1.AnnotationPlacedPeers:    strings.Join(peers, ","),
+    }
+    if domain.SpineSwitch != "" {
+        annotations[v1alpha1.AnnotationPlacedSpine] = domain.SpineSwitch
+    }
+    return annotations, nil
+}
@@ -0,0 +1,166 @@
+package algorithm
+
+import (
+    "sort"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// placementHintTTL is how long a hint waits for the replacement pod to show up
+const placementHintTTL = 10 * time.Minute
+
+// PreferredDomainScoreBonus is added to the score of nodes in a pod's preferred domain
+const PreferredDomainScoreBonus = 0.25
+
+// placementHint counts the replacements of a controller's pods still expected
+// in one domain
+type placementHint struct {
+    pending int
+    expires time.Time
+}
+
+// AddPlacementHint steers one more pod created by the given controller to a
+// domain until the hint expires or a replacement is bound. Recovery adds one
+// for each replacement of an evicted pod, which the controller builds from its
+// own template without the annotation.
+func (ts *TopologyScheduler) AddPlacementHint(ownerUID types.UID, domain string) {
+    ts.Lock()
+    defer ts.Unlock()
+
+    now := time.Now()
+    ts.expirePlacementHintsLocked(now)
+    if ts.placementHints[ownerUID] == nil {
+        ts.placementHints[ownerUID] = make(map[string]*placementHint)
+    }
+    hint := ts.placementHints[ownerUID][domain]
+    if hint == nil {
+        hint = &placementHint{}
+        ts.placementHints[ownerUID][domain] = hint
+    }
+    hint.pending++
+    hint.expires = now.Add(placementHintTTL)
+}
+
+// preferredDomain returns the domain a pod should be placed in, if any. The
+// pod's own annotation wins; otherwise the domain still expecting the most
+// replacements of its controller is used, and fromHint is set so the caller
+// can annotate the pod and consume the hint once the pod is bound.
+func (ts *TopologyScheduler) preferredDomain(pod *v1.Pod) (domain string, fromHint bool) {
+    if domain, ok := pod.Annotations[v1alpha1.AnnotationPreferredDomain]; ok {
+        return domain, false
+    }
+
+    owner := metav1.GetControllerOf(pod)
+    if owner == nil {
+        return "", false
+    }
+
+    ts.RLock()
+    defer ts.RUnlock()
+
+    now := time.Now()
+    pending := 0
+    for name, hint := range ts.placementHints[owner.UID] {
+        if now.After(hint.expires) {
+            continue
+        }
+        if hint.pending > pending || (hint.pending == pending && name < domain) {
+            domain, pending = name, hint.pending
+        }
+    }
+    return domain, domain != ""
+}
+
+// consumePlacementHint takes one replacement off the hints of a bound pod's
+// controller: from the domain it was bound in if that expected one, and from
+// the domain it was steered to otherwise
+func (ts *TopologyScheduler) consumePlacementHint(pod *v1.Pod, boundDomain, preferredDomain string) {
+    owner := metav1.GetControllerOf(pod)
+    if owner == nil {
+        return
+    }
+
+    ts.Lock()
+    defer ts.Unlock()
+
+    hints := ts.placementHints[owner.UID]
+    domain := boundDomain
+    if hints[domain] == nil {
+        domain = preferredDomain
+    }
+    hint := hints[domain]
+    if hint == nil {
+        return
+    }
+    if hint.pending--; hint.pending <= 0 {
+        delete(hints, domain)
+    }
+    if len(hints) == 0 {
+        delete(ts.placementHints, owner.UID)
+    }
+}
+
+func (ts *TopologyScheduler) expirePlacementHintsLocked(now time.Time) {
+    for uid, hints := range ts.placementHints {
+        for domain, hint := range hints {
+            if now.After(hint.expires) {
+                delete(hints, domain)
+            }
+        }
+        if len(hints) == 0 {
+            delete(ts.placementHints, uid)
+        }
+    }
+}
+
+func (ts *TopologyScheduler) placementHintsCheckpoint() []PlacementHintCheckpoint {
+    ts.RLock()
+    defer ts.RUnlock()
+
+    var hints []PlacementHintCheckpoint
+    for uid, domains := range ts.placementHints {
+        for domain, hint := range domains {
+            hints = append(hints, PlacementHintCheckpoint{
+                OwnerUID: uid,
+                Domain:   domain,
+                Pending:  hint.pending,
+                Expires:  hint.expires,
+            })
+        }
+    }
+    sort.Slice(hints, func(i, j int) bool {
+        if hints[i].OwnerUID != hints[j].OwnerUID {
+            return hints[i].OwnerUID < hints[j].OwnerUID
+        }
+        return hints[i].Domain < hints[j].Domain
+    })
+    return hints
+}
+
+// restorePlacementHints adds the unexpired hints of a checkpoint; hints
+// already added by this leader are newer and kept
+func (ts *TopologyScheduler) restorePlacementHints(hints []PlacementHintCheckpoint) {
+    ts.Lock()
+    defer ts.Unlock()
+
+    now := time.Now()
+    for _, hint := range hints {
+        if _, exists := ts.placementHints[hint.OwnerUID][hint.Domain]; exists || now.After(hint.Expires) {
+            continue
+        }
+        // Checkpoints from before hints were counted hold one replacement each
+        pending := hint.Pending
+        if pending <= 0 {
+            pending = 1
+        }
+        if ts.placementHints[hint.OwnerUID] == nil {
+            ts.placementHints[hint.OwnerUID] = make(map[string]*placementHint)
+        }
+        ts.placementHints[hint.OwnerUID][hint.Domain] = &placementHint{pending: pending, expires: hint.Expires}
+    }
+}
@@ -0,0 +1,64 @@
+package algorithm
+
+import (
+    "testing"
+
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+)
+
+func TestPlacementHintsPerReplacement(t *testing.T) {
+    ts := testTopology()
+    controller := true
+    owner := metav1.OwnerReference{Kind: "Job", Name: "a", UID: types.UID("job-a"), Controller: &controller}
+    replacement := testPod("a", "a-0", "", 8, "")
+    replacement.OwnerReferences = []metav1.OwnerReference{owner}
+
+    ts.AddPlacementHint(owner.UID, "leaf-3")
+    ts.AddPlacementHint(owner.UID, "leaf-2")
+    ts.AddPlacementHint(owner.UID, "leaf-2")
+
+    steps := []struct {
+        // bound is the domain the previous replacement was bound in
+        bound string
+        want  string
+    }{
+        {want: "leaf-2"},
+        {bound: "leaf-2", want: "leaf-2"},
+        {bound: "leaf-2", want: "leaf-3"},
+        // A replacement bound outside the hinted domains still counts
+        {bound: "leaf-1", want: ""},
+    }
+    for i, step := range steps {
+        if step.bound != "" {
+            preferred, _ := ts.preferredDomain(replacement)
+            ts.consumePlacementHint(replacement, step.bound, preferred)
+        }
+        domain, fromHint := ts.preferredDomain(replacement)
+        if domain != step.want || fromHint != (step.want != "") {
+            t.Errorf("step %d: preferred domain = %q, %v, want %q", i, domain, fromHint, step.want)
+        }
+    }
+
+    checkpoint := ts.placementHintsCheckpoint()
+    if len(checkpoint) != 0 {
+        t.Errorf("consumed hints are still checkpointed: %+v", checkpoint)
+    }
+}
+
+func TestRestorePlacementHints(t *testing.T) {
+    ts := testTopology()
+    ts.AddPlacementHint("job-a", "leaf-2")
+    ts.AddPlacementHint("job-a", "leaf-2")
+    ts.AddPlacementHint("job-b", "leaf-4")
+    checkpoint := ts.placementHintsCheckpoint()
+
+    restored := testTopology()
+    restored.restorePlacementHints(checkpoint)
+    if got := restored.placementHints["job-a"]["leaf-2"]; got == nil || got.pending != 2 {
+        t.Errorf("restored leaf-2 hint of job-a = %+v, want 2 pending", got)
+    }
+    if got := restored.placementHints["job-b"]["leaf-4"]; got == nil || got.pending != 1 {
+        t.Errorf("restored leaf-4 hint of job-b = %+v, want 1 pending", got)
+    }
+}
@@ -0,0 +1,93 @@
+package algorithm
+
+import (
+    "testing"
+    "time"
+)
+
+func TestAssumeAndForgetPod(t *testing.T) {
+    ts := testTopology()
+    pod := testPod("a", "a-0", "", 8, "")
+
+    if err := ts.cache.AssumePod(pod, "n1-1"); err != nil {
+        t.Fatal(err)
+    }
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 8 {
+        t.Errorf("assumed GPUs on n1-1 = %d, want 8", got)
+    }
+    domain, err := ts.cache.Snapshot().GetDomain("leaf-1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if domain.UsedGPUs != 8 {
+        t.Errorf("leaf-1 used GPUs = %d, want 8", domain.UsedGPUs)
+    }
+    if err := ts.cache.AssumePod(pod, "n1-2"); err == nil {
+        t.Error("assuming a pod twice succeeded")
+    }
+
+    ts.cache.ForgetPod(pod)
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 0 {
+        t.Errorf("GPUs on n1-1 after forget = %d, want 0", got)
+    }
+    if domains := ts.cache.GetJobDomains("a"); len(domains) != 0 {
+        t.Errorf("forgotten job is still in domains %v", domains)
+    }
+}
+
+func TestAddPodConfirmsAssumedPod(t *testing.T) {
+    ts := testTopology()
+    ts.cache.SetAssumedPodTTL(time.Minute)
+    pod := testPod("a", "a-0", "", 8, "")
+    if err := ts.cache.AssumePod(pod, "n1-1"); err != nil {
+        t.Fatal(err)
+    }
+    ts.cache.FinishBinding(pod)
+
+    ts.cache.AddPod(testPod("a", "a-0", "n1-1", 8, ""))
+
+    // A confirmed pod is neither forgotten nor expired
+    ts.cache.ForgetPod(pod)
+    ts.cache.cleanupAssumedPods(time.Now().Add(time.Hour))
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 8 {
+        t.Errorf("GPUs on n1-1 after confirmation = %d, want 8", got)
+    }
+
+    // A pod bound elsewhere than assumed moves its GPUs
+    moved := testPod("b", "b-0", "", 8, "")
+    if err := ts.cache.AssumePod(moved, "n2-1"); err != nil {
+        t.Fatal(err)
+    }
+    ts.cache.AddPod(testPod("b", "b-0", "n2-2", 8, ""))
+    snapshot := ts.cache.Snapshot()
+    if snapshot.GetNodeGPUs("n2-1") != 0 || snapshot.GetNodeGPUs("n2-2") != 8 {
+        t.Errorf("GPUs on n2-1, n2-2 = %d, %d, want 0, 8",
+            snapshot.GetNodeGPUs("n2-1"), snapshot.GetNodeGPUs("n2-2"))
+    }
+}
+
+func TestAssumedPodExpiry(t *testing.T) {
+    ts := testTopology()
+    ts.cache.SetAssumedPodTTL(time.Minute)
+    pod := testPod("a", "a-0", "", 8, "")
+    if err := ts.cache.AssumePod(pod, "n1-1"); err != nil {
+        t.Fatal(err)
+    }
+
+    // A pod waiting in Permit or PreBind has no deadline yet
+    ts.cache.cleanupAssumedPods(time.Now().Add(time.Hour))
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 8 {
+        t.Fatalf("GPUs on n1-1 before binding = %d, want 8", got)
+    }
+
+    ts.cache.FinishBinding(pod)
+    ts.cache.cleanupAssumedPods(time.Now())
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 8 {
+        t.Fatalf("GPUs on n1-1 within the TTL = %d, want 8", got)
+    }
+
+    ts.cache.cleanupAssumedPods(time.Now().Add(2 * time.Minute))
+    if got := ts.cache.Snapshot().GetNodeGPUs("n1-1"); got != 0 {
+        t.Errorf("GPUs on n1-1 after the TTL = %d, want 0", got)
+    }
+}
@@ -1,186 +1,380 @@
-package algorithm
-
-import (
-    "fmt"
-    "sync"
-    "context"
-    v1 "k8s.io/api/core/v1"
-    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-)
-
-type RecoveryManager struct {
-    domainManager *DomainManager
-    scheduler     *TopologyScheduler
-    recoveryLock  sync.Mutex
-}
-
-func NewRecoveryManager(dm *DomainManager, scheduler *TopologyScheduler) *RecoveryManager {
-    return &RecoveryManager{
-        domainManager: dm,
-        scheduler:     scheduler,
-    }
-}
-
-func (rm *RecoveryManager) HandleNodeFailure(node *v1.Node, pods []*v1.Pod) error {
-    rm.recoveryLock.Lock()
-    defer rm.recoveryLock.Unlock()
-
-    // Get the failed node's domain
-    domain, err := rm.domainManager.GetDomainByNode(node.Name)
-    if err != nil {
-        return fmt.Errorf("failed to get domain for node %s: %v", node.Name, err)
-    }
-
-    // Group pods by GPU requirements
-    gpuPods, nonGpuPods := rm.categorizePods(pods)
-
-    // Handle GPU pods first as they are more critical
-    if err := rm.recoverGPUPods(domain, gpuPods); err != nil {
-        return fmt.Errorf("failed to recover GPU pods: %v", err)
-    }
-
-    // Handle non-GPU pods
-    if err := rm.recoverNonGPUPods(domain, nonGpuPods); err != nil {
-        return fmt.Errorf("failed to recover non-GPU pods: %v", err)
-    }
-
-    // Update domain state
-    return rm.domainManager.HandleNodeRemoval(node.Name)
-}
-
-func (rm *RecoveryManager) categorizePods(pods []*v1.Pod) (gpuPods []*v1.Pod, nonGpuPods []*v1.Pod) {
-    for _, pod := range pods {
-        if requiresGPU(pod) {
-            gpuPods = append(gpuPods, pod)
-        } else {
-            nonGpuPods = append(nonGpuPods, pod)
-        }
-    }
-    return
-}
-
-func (rm *RecoveryManager) recoverGPUPods(failedDomain *Domain, pods []*v1.Pod) error {
-    // Sort pods by priority and GPU requirements
-    sortPodsByPriority(pods)
-
-    for _, pod := range pods {
-        // Get GPU requirements
-        gpuCount := getGPURequirements(pod)
-        
-        // Try to schedule in the same domain first
-        newNode, err := rm.scheduler.FindNodeInDomain(failedDomain, gpuCount)
-        if err == nil {
-            if err := rm.migratePod(pod, newNode); err != nil {
-                return err
-            }
-            continue
-        }
-
-        // Try adjacent domains if same domain failed
-        adjacentDomains := rm.domainManager.GetAdjacentDomains(failedDomain.Name)
-        scheduled := false
-        for _, adjDomain := range adjacentDomains {
-            newNode, err := rm.scheduler.FindNodeInDomain(adjDomain, gpuCount)
-            if err == nil {
-                if err := rm.migratePod(pod, newNode); err != nil {
-                    return err
-                }
-                scheduled = true
-                break
-            }
-        }
-
-        if !scheduled {
-            // Fall back to any available domain
-            newNode, err := rm.scheduler.FindNodeForGPUs(gpuCount)
-            if err != nil {
-                return fmt.Errorf("failed to find replacement node for pod %s: %v", pod.Name, err)
-            }
-            if err := rm.migratePod(pod, newNode); err != nil {
-                return err
-            }
-        }
-    }
-    return nil
-}
-
-func (rm *RecoveryManager) recoverNonGPUPods(failedDomain *Domain, pods []*v1.Pod) error {
-    sortPodsByPriority(pods)
-
-    for _, pod := range pods {
-        newNode, err := rm.scheduler.FindNodeForPod(pod)
-        if err != nil {
-            return fmt.Errorf("failed to find replacement node for pod %s: %v", pod.Name, err)
-        }
-        
-        if err := rm.migratePod(pod, newNode); err != nil {
-            return err
-        }
-    }
-    return nil
-}
-
-func (rm *RecoveryManager) migratePod(pod *v1.Pod, newNode *v1.Node) error {
-    // Create new pod spec with updated node name
-    newPod := pod.DeepCopy()
-    newPod.Spec.NodeName = newNode.Name
-    newPod.ResourceVersion = ""
-    newPod.UID = ""
-    newPod.Status = v1.PodStatus{}
-
-    // Set scheduling timestamp
-    now := metav1.Now()
-    newPod.Status.StartTime = &now
-
-    // Delete old pod and create new one
-    err := rm.scheduler.DeletePod(context.Background(), pod)
-    if err != nil {
-        return fmt.Errorf("failed to delete old pod %s: %v", pod.Name, err)
-    }
-
-    _, err = rm.scheduler.CreatePod(context.Background(), newPod)
-    if err != nil {
-        return fmt.Errorf("failed to create new pod %s on node %s: %v", 
-            newPod.Name, newNode.Name, err)
-    }
-
-    return nil
-}
-
-func requiresGPU(pod *v1.Pod) bool {
-    for _, container := range pod.Spec.Containers {
-        if _, ok := container.Resources.Limits["nvidia.com/gpu"]; ok {
-            return true
-        }
-    }
-    return false
-}
-
-func getGPURequirements(pod *v1.Pod) int {
-    var total int
-    for _, container := range pod.Spec.Containers {
-        if gpus, ok := container.Resources.Limits["nvidia.com/gpu"]; ok {
-            total += int(gpus.Value())
-        }
-    }
-    return total
-}
-
-func sortPodsByPriority(pods []*v1.Pod) {
-    sort.Slice(pods, func(i, j int) bool {
-        iPriority := pods[i].Spec.Priority
-        jPriority := pods[j].Spec.Priority
-        
-        if iPriority == nil && jPriority == nil {
-            return false
-        }
-        if iPriority == nil {
-            return false
-        }
-        if jPriority == nil {
-            return true
-        }
-        
-        return *iPriority > *jPriority
-    })
-}
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    policyv1 "k8s.io/api/policy/v1"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+    utilerrors "k8s.io/apimachinery/pkg/util/errors"
+    "k8s.io/apimachinery/pkg/util/wait"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    "k8s.io/client-go/kubernetes"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/client-go/util/flowcontrol"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// evictionBackoff retries evictions refused by a PodDisruptionBudget
+var evictionBackoff = wait.Backoff{
+    Duration: 5 * time.Second,
+    Factor:   2.0,
+    Steps:    5,
+}
+
+// recreateTimeout bounds the wait for an evicted standalone pod to be gone,
+// on top of its termination grace period
+const recreateTimeout = 30 * time.Second
+
+type RecoveryManager struct {
+    domainManager *DomainManager
+    scheduler     *TopologyScheduler
+    client        kubernetes.Interface
+    podLister     corelisters.PodLister
+    recoveryLock  sync.Mutex
+
+    // slots and limiter bound migrations across all recoveries
+    slots   chan struct{}
+    limiter flowcontrol.RateLimiter
+    breaker *CircuitBreaker
+}
+
+func NewRecoveryManager(dm *DomainManager, scheduler *TopologyScheduler, client kubernetes.Interface) *RecoveryManager {
+    rm := &RecoveryManager{
+        domainManager: dm,
+        scheduler:     scheduler,
+        client:        client,
+    }
+    rm.SetLimits(DefaultRecoveryLimits())
+    return rm
+}
+
+// Watch reads the members of recovered jobs from the pod informer
+func (rm *RecoveryManager) Watch(pods coreinformers.PodInformer) {
+    rm.podLister = pods.Lister()
+}
+
+func (rm *RecoveryManager) HandleNodeFailure(node *v1.Node, pods []*v1.Pod) error {
+    if err := rm.admitNodeFailure(node.Name); err != nil {
+        return err
+    }
+    return rm.recoverNode(node, pods)
+}
+
+// recoverNode moves the pods of a failed node that admitNodeFailure accepted
+func (rm *RecoveryManager) recoverNode(node *v1.Node, pods []*v1.Pod) error {
+    // Group pods by GPU requirements
+    gpuPods, nonGpuPods := rm.categorizePods(pods)
+
+    // Handle GPU pods first as they are more critical. The plan covers every
+    // affected job, and each job is recovered on its own so one failing job
+    // does not block the others.
+    // Plans are made one at a time, while their migrations share the
+    // concurrency and rate limits.
+    var errs []error
+    ctx, span := tracer.Start(context.Background(), "RecoveryManager.HandleNodeFailure",
+        trace.WithAttributes(attribute.String("node", node.Name), attribute.Int("pods", len(pods))))
+    rm.recoveryLock.Lock()
+    plan, err := rm.planForPods(ctx, rm.scheduler.cache.Snapshot(), []string{node.Name}, gpuPods)
+    rm.recoveryLock.Unlock()
+    if err != nil {
+        errs = append(errs, fmt.Errorf("failed to plan recovery: %v", err))
+    } else {
+        plan.Trigger = RecoveryTriggerNodeFailure
+        if err := rm.executePlan(ctx, plan); err != nil {
+            errs = append(errs, err)
+        }
+    }
+
+    // Handle non-GPU pods
+    if err := rm.recoverNonGPUPods(nonGpuPods); err != nil {
+        errs = append(errs, fmt.Errorf("failed to recover non-GPU pods: %v", err))
+    }
+
+    // Update domain state. The domain manager only tracks the nodes it was given.
+    if _, err := rm.domainManager.GetDomainByNode(node.Name); err == nil {
+        if err := rm.domainManager.HandleNodeRemoval(node.Name); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    err = utilerrors.NewAggregate(errs)
+    endSpan(span, err)
+    return err
+}
+
+// HandleGPUFailure moves the pods using a persistently unhealthy GPU. The
+// node stays in service with its remaining devices, so it is not counted as
+// a failed node.
+func (rm *RecoveryManager) HandleGPUFailure(nodeName string, refs []types.NamespacedName) error {
+    if err := rm.breaker.allow(); err != nil {
+        return fmt.Errorf("not recovering GPU pods on node %s: %v", nodeName, err)
+    }
+
+    ctx := context.Background()
+    var pods []*v1.Pod
+    for _, ref := range refs {
+        pod, err := rm.client.CoreV1().Pods(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+        if apierrors.IsNotFound(err) {
+            continue
+        }
+        if err != nil {
+            return fmt.Errorf("failed to get pod %s: %v", ref, err)
+        }
+        // The exporter may lag behind a pod that already moved
+        if pod.Spec.NodeName != nodeName || isTerminalPod(pod) || !requiresGPU(pod) {
+            continue
+        }
+        pods = append(pods, pod)
+    }
+    if len(pods) == 0 {
+        return nil
+    }
+
+    ctx, span := tracer.Start(ctx, "RecoveryManager.HandleGPUFailure",
+        trace.WithAttributes(attribute.String("node", nodeName), attribute.Int("pods", len(pods))))
+    rm.recoveryLock.Lock()
+    plan, err := rm.planForPods(ctx, rm.scheduler.cache.Snapshot(), nil, pods)
+    rm.recoveryLock.Unlock()
+    if err != nil {
+        err = fmt.Errorf("failed to plan recovery: %v", err)
+    } else {
+        plan.Trigger = RecoveryTriggerGPUFailure
+        err = rm.executePlan(ctx, plan)
+    }
+    endSpan(span, err)
+    return err
+}
+
+func (rm *RecoveryManager) categorizePods(pods []*v1.Pod) (gpuPods []*v1.Pod, nonGpuPods []*v1.Pod) {
+    for _, pod := range pods {
+        if requiresGPU(pod) {
+            gpuPods = append(gpuPods, pod)
+        } else {
+            nonGpuPods = append(nonGpuPods, pod)
+        }
+    }
+    return
+}
+
+// executePlan runs a recovery plan and records its outcome in the scheduler's
+// recovery history and decision log
+func (rm *RecoveryManager) executePlan(ctx context.Context, plan *RecoveryPlan) error {
+    record := rm.scheduler.recoveries.start(plan)
+    ctx, span := tracer.Start(ctx, "RecoveryManager.executePlan", trace.WithAttributes(
+        attribute.Int("recovery.id", record.ID),
+        attribute.String("trigger", plan.Trigger),
+        attribute.Int("jobs", len(plan.Jobs)),
+        attribute.Int("moved_pods", plan.MovedPods),
+    ))
+
+    klog.Infof("Executing recovery plan %d: %d pod(s) to move across %d job(s), %d unplaced",
+        record.ID, plan.MovedPods, len(plan.Jobs), len(plan.Unplaced))
+
+    var (
+        wg   sync.WaitGroup
+        mu   sync.Mutex
+        errs []error
+    )
+    for _, jobPlan := range plan.Jobs {
+        wg.Add(1)
+        go func(jobPlan *JobPlan) {
+            defer wg.Done()
+            if err := rm.executeJobPlan(ctx, record, jobPlan); err != nil {
+                mu.Lock()
+                errs = append(errs, fmt.Errorf("failed to recover job %s: %v", jobPlan.Job, err))
+                mu.Unlock()
+            }
+        }(jobPlan)
+    }
+    wg.Wait()
+    err := utilerrors.NewAggregate(errs)
+
+    finished := rm.scheduler.recoveries.finish(record)
+    decision := &Decision{
+        Type:          DecisionRecovery,
+        Recovery:      &finished,
+        LatencyMillis: float64(finished.FinishedAt.Sub(finished.StartedAt).Microseconds()) / 1000,
+    }
+    if err != nil {
+        decision.Error = err.Error()
+    }
+    rm.scheduler.recordDecision(decision)
+    endSpan(span, err)
+    return err
+}
+
+func (rm *RecoveryManager) recoverNonGPUPods(pods []*v1.Pod) error {
+    sortPodsByPriority(pods)
+
+    for _, pod := range pods {
+        if err := rm.migrateLimited(context.Background(), pod, "", true); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// migratePod evicts a pod so it is rescheduled elsewhere. Evictions honor
+// PodDisruptionBudgets and the pod's termination grace period. A pod on a
+// node declared failed is force deleted instead, since no kubelet is left to
+// confirm an eviction, as the node lifecycle controller does. The owning
+// controller recreates the pod, and the scheduler steers the replacement to
+// preferredDomain. Standalone pods have no controller, so they are recreated
+// here once the evicted pod is gone.
+func (rm *RecoveryManager) migratePod(ctx context.Context, pod *v1.Pod, preferredDomain string, force bool) error {
+    owner := metav1.GetControllerOf(pod)
+    if owner != nil && preferredDomain != "" {
+        rm.scheduler.AddPlacementHint(owner.UID, preferredDomain)
+    }
+
+    if force {
+        if err := rm.forceDeletePod(ctx, pod); err != nil {
+            return err
+        }
+        klog.Infof("Force deleted pod %s/%s from failed node %s, preferred domain %q",
+            pod.Namespace, pod.Name, pod.Spec.NodeName, preferredDomain)
+    } else {
+        if err := rm.evictPod(ctx, pod); err != nil {
+            return err
+        }
+        klog.Infof("Evicted pod %s/%s for recovery, preferred domain %q", pod.Namespace, pod.Name, preferredDomain)
+    }
+
+    if owner != nil {
+        return nil
+    }
+    return rm.recreatePod(ctx, pod, preferredDomain)
+}
+
+func (rm *RecoveryManager) evictPod(ctx context.Context, pod *v1.Pod) error {
+    eviction := &policyv1.Eviction{
+        ObjectMeta: metav1.ObjectMeta{
+            Name:      pod.Name,
+            Namespace: pod.Namespace,
+        },
+    }
+
+    var lastErr error
+    err := wait.ExponentialBackoffWithContext(ctx, evictionBackoff, func(ctx context.Context) (bool, error) {
+        lastErr = rm.client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+        switch {
+        case lastErr == nil, apierrors.IsNotFound(lastErr):
+            return true, nil
+        case apierrors.IsTooManyRequests(lastErr):
+            // A PodDisruptionBudget does not allow the disruption yet
+            klog.V(2).Infof("Eviction of pod %s/%s blocked: %v", pod.Namespace, pod.Name, lastErr)
+            return false, nil
+        default:
+            return false, lastErr
+        }
+    })
+    if err != nil {
+        if lastErr != nil {
+            err = lastErr
+        }
+        return fmt.Errorf("failed to evict pod %s/%s: %v", pod.Namespace, pod.Name, err)
+    }
+    return nil
+}
+
+// forceDeletePod removes a pod without waiting for its kubelet
+func (rm *RecoveryManager) forceDeletePod(ctx context.Context, pod *v1.Pod) error {
+    grace := int64(0)
+    err := rm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+        GracePeriodSeconds: &grace,
+        Preconditions:      metav1.NewUIDPreconditions(string(pod.UID)),
+    })
+    if err != nil && !apierrors.IsNotFound(err) {
+        return fmt.Errorf("failed to delete pod %s/%s: %v", pod.Namespace, pod.Name, err)
+    }
+    return nil
+}
+
+// recreatePod creates a copy of an evicted standalone pod for the scheduler to place
+func (rm *RecoveryManager) recreatePod(ctx context.Context, pod *v1.Pod, preferredDomain string) error {
+    timeout := recreateTimeout
+    if pod.Spec.TerminationGracePeriodSeconds != nil {
+        timeout += time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+    }
+
+    err := wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+        _, err := rm.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+        if apierrors.IsNotFound(err) {
+            return true, nil
+        }
+        return false, nil
+    })
+    if err != nil {
+        return fmt.Errorf("evicted pod %s/%s was not removed: %v", pod.Namespace, pod.Name, err)
+    }
+
+    newPod := &v1.Pod{
+        ObjectMeta: metav1.ObjectMeta{
+            Name:        pod.Name,
+            Namespace:   pod.Namespace,
+            Labels:      pod.Labels,
+            Annotations: make(map[string]string, len(pod.Annotations)+1),
+        },
+        Spec: *pod.Spec.DeepCopy(),
+    }
+    for key, value := range pod.Annotations {
+        newPod.Annotations[key] = value
+    }
+    if preferredDomain != "" {
+        newPod.Annotations[v1alpha1.AnnotationPreferredDomain] = preferredDomain
+    }
+    newPod.Spec.NodeName = ""
+
+    if _, err := rm.client.CoreV1().Pods(pod.Namespace).Create(ctx, newPod, metav1.CreateOptions{}); err != nil {
+        return fmt.Errorf("failed to recreate pod %s/%s: %v", pod.Namespace, pod.Name, err)
+    }
+    return nil
+}
+
+func requiresGPU(pod *v1.Pod) bool {
+    for _, container := range pod.Spec.Containers {
+        if _, ok := container.Resources.Limits["nvidia.com/gpu"]; ok {
+            return true
+        }
+    }
+    return false
+}
+
+func getGPURequirements(pod *v1.Pod) int {
+    var total int
+    for _, container := range pod.Spec.Containers {
+        if gpus, ok := container.Resources.Limits["nvidia.com/gpu"]; ok {
+            total += int(gpus.Value())
+        }
+    }
+    return total
+}
+
+func sortPodsByPriority(pods []*v1.Pod) {
+    sort.Slice(pods, func(i, j int) bool {
+        iPriority := pods[i].Spec.Priority
+        jPriority := pods[j].Spec.Priority
+        
+        if iPriority == nil && jPriority == nil {
+            return false
+        }
+        if iPriority == nil {
+            return false
+        }
+        if jPriority == nil {
+            return true
+        }
+        
+        return *iPriority > *jPriority
+    })
+}
@@ -0,0 +1,61 @@
+package algorithm
+
+import (
+    "net/http"
+    "strings"
+)
+
+// NewRecoveryAdminHandler serves recovery plans and their outcomes, and
+// controls the recovery circuit breaker.
+//
+//   GET  plan?node=a&node=b or plan?domain=leaf-1
+//        returns the plan for the nodes failing, without executing it
+//   GET  history
+//        returns executed plans with the planned and actual domain of each pod
+//   GET  breaker
+//        returns the circuit breaker state
+//   POST resume
+//        closes the circuit breaker so automatic recovery runs again
+func NewRecoveryAdminHandler(rm *RecoveryManager) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if strings.HasSuffix(r.URL.Path, "/resume") {
+            if r.Method != http.MethodPost {
+                w.Header().Set("Allow", "POST")
+                http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+                return
+            }
+            rm.ResumeRecovery()
+            writeJSON(w, http.StatusOK, rm.Breaker().Status())
+            return
+        }
+
+        if r.Method != http.MethodGet {
+            w.Header().Set("Allow", "GET")
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        switch {
+        case strings.HasSuffix(r.URL.Path, "/plan"):
+            target := RecoveryTarget{
+                Nodes:  r.URL.Query()["node"],
+                Domain: r.URL.Query().Get("domain"),
+            }
+            plan, err := rm.PlanRecovery(r.Context(), target)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            writeJSON(w, http.StatusOK, plan)
+
+        case strings.HasSuffix(r.URL.Path, "/history"):
+            writeJSON(w, http.StatusOK, rm.scheduler.recoveries.Records())
+
+        case strings.HasSuffix(r.URL.Path, "/breaker"):
+            writeJSON(w, http.StatusOK, rm.Breaker().Status())
+
+        default:
+            http.NotFound(w, r)
+        }
+    })
+}
@@ -0,0 +1,221 @@
+package algorithm
+
+import (
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+)
+
+// maxRecoveryRecords bounds how many executed plans are kept
+const maxRecoveryRecords = 50
+
+// Migration statuses, from planned to the replacement being bound
+const (
+    MigrationPlanned  = "Planned"
+    MigrationEvicted  = "Evicted"
+    MigrationPlaced   = "Placed"
+    MigrationFailed   = "Failed"
+    // MigrationSkipped is set when the circuit breaker paused recovery first
+    MigrationSkipped  = "Skipped"
+    MigrationUnplaced = "Unplaced"
+)
+
+// MigrationOutcome compares where a pod was planned to go with where its
+// replacement was actually bound
+type MigrationOutcome struct {
+    Migration
+    Status       string `json:"status"`
+    Error        string `json:"error,omitempty"`
+    ActualNode   string `json:"actualNode,omitempty"`
+    ActualDomain string `json:"actualDomain,omitempty"`
+    // MatchedPlan is set once placed, if the replacement landed in the planned domain
+    MatchedPlan bool `json:"matchedPlan"`
+}
+
+// RecoveryRecord is an executed plan and what became of each migration
+type RecoveryRecord struct {
+    ID         int                `json:"id"`
+    Plan       *RecoveryPlan      `json:"plan"`
+    Outcomes   []MigrationOutcome `json:"outcomes"`
+    StartedAt  time.Time          `json:"startedAt"`
+    FinishedAt time.Time          `json:"finishedAt,omitempty"`
+}
+
+// RecoveryHistory keeps the most recent recovery records
+type RecoveryHistory struct {
+    sync.Mutex
+    records []*RecoveryRecord
+    nextID  int
+}
+
+func NewRecoveryHistory() *RecoveryHistory {
+    return &RecoveryHistory{nextID: 1}
+}
+
+// start records a plan about to be executed, with every migration planned
+func (h *RecoveryHistory) start(plan *RecoveryPlan) *RecoveryRecord {
+    h.Lock()
+    defer h.Unlock()
+
+    record := &RecoveryRecord{ID: h.nextID, Plan: plan, StartedAt: time.Now()}
+    h.nextID++
+    for _, job := range plan.Jobs {
+        for _, m := range job.Migrations {
+            record.Outcomes = append(record.Outcomes, MigrationOutcome{Migration: m, Status: MigrationPlanned})
+        }
+        if job.Error == "" {
+            continue
+        }
+        for _, pod := range job.lost {
+            record.Outcomes = append(record.Outcomes, MigrationOutcome{
+                Migration: Migration{
+                    Namespace: pod.Namespace,
+                    Pod:       pod.Name,
+                    PodUID:    pod.UID,
+                    GPUs:      getGPURequirements(pod),
+                    FromNode:  pod.Spec.NodeName,
+                    Lost:      true,
+                },
+                Status: MigrationUnplaced,
+                Error:  job.Error,
+            })
+        }
+    }
+
+    h.records = append(h.records, record)
+    if len(h.records) > maxRecoveryRecords {
+        h.records = h.records[len(h.records)-maxRecoveryRecords:]
+    }
+    return record
+}
+
+// setOutcome records the result of executing a pod's migration
+func (h *RecoveryHistory) setOutcome(record *RecoveryRecord, namespace, name, status string, err error) {
+    h.Lock()
+    defer h.Unlock()
+
+    for i := range record.Outcomes {
+        outcome := &record.Outcomes[i]
+        if outcome.Namespace == namespace && outcome.Pod == name {
+            outcome.Status = status
+            if err != nil {
+                outcome.Error = err.Error()
+            }
+        }
+    }
+}
+
+// finish marks the record finished and returns a copy of it
+func (h *RecoveryHistory) finish(record *RecoveryRecord) RecoveryRecord {
+    h.Lock()
+    defer h.Unlock()
+    record.FinishedAt = time.Now()
+    return copyRecord(record)
+}
+
+// ObserveBinding matches a bound pod to the evicted pod it replaces, by
+// controller or, for standalone pods, by name, and records where it landed
+func (h *RecoveryHistory) ObserveBinding(pod *v1.Pod, nodeName, domainName string) {
+    h.Lock()
+    defer h.Unlock()
+
+    var ownerUID string
+    if owner := metav1.GetControllerOf(pod); owner != nil {
+        ownerUID = string(owner.UID)
+    }
+
+    for i := len(h.records) - 1; i >= 0; i-- {
+        for j := range h.records[i].Outcomes {
+            outcome := &h.records[i].Outcomes[j]
+            if outcome.Status != MigrationEvicted || outcome.PodUID == pod.UID {
+                continue
+            }
+            matches := ownerUID != "" && string(outcome.OwnerUID) == ownerUID
+            if outcome.OwnerUID == "" {
+                matches = outcome.Namespace == pod.Namespace && outcome.Pod == pod.Name
+            }
+            if !matches {
+                continue
+            }
+
+            outcome.Status = MigrationPlaced
+            outcome.ActualNode = nodeName
+            outcome.ActualDomain = domainName
+            outcome.MatchedPlan = domainName == outcome.TargetDomain
+            return
+        }
+    }
+}
+
+// outstanding returns the UIDs of pods evicted from the given nodes whose
+// replacements have not been bound yet
+func (h *RecoveryHistory) outstanding(nodeNames []string) map[types.UID]bool {
+    h.Lock()
+    defer h.Unlock()
+
+    nodes := make(map[string]bool, len(nodeNames))
+    for _, name := range nodeNames {
+        nodes[name] = true
+    }
+    uids := make(map[types.UID]bool)
+    for _, record := range h.records {
+        for _, outcome := range record.Outcomes {
+            if outcome.Status == MigrationEvicted && nodes[outcome.FromNode] {
+                uids[outcome.PodUID] = true
+            }
+        }
+    }
+    return uids
+}
+
+// Records returns copies of the kept records, oldest first
+func (h *RecoveryHistory) Records() []RecoveryRecord {
+    h.Lock()
+    defer h.Unlock()
+
+    records := make([]RecoveryRecord, len(h.records))
+    for i, record := range h.records {
+        records[i] = copyRecord(record)
+    }
+    return records
+}
+
+func (h *RecoveryHistory) checkpoint() *RecoveryHistoryCheckpoint {
+    h.Lock()
+    defer h.Unlock()
+
+    cp := &RecoveryHistoryCheckpoint{NextID: h.nextID}
+    for _, record := range h.records {
+        cp.Records = append(cp.Records, copyRecord(record))
+    }
+    return cp
+}
+
+// restore puts the checkpointed records before any this leader has started,
+// so replacements evicted by the previous leader are still matched
+func (h *RecoveryHistory) restore(cp *RecoveryHistoryCheckpoint) {
+    h.Lock()
+    defer h.Unlock()
+
+    restored := make([]*RecoveryRecord, 0, len(cp.Records)+len(h.records))
+    for i := range cp.Records {
+        record := cp.Records[i]
+        restored = append(restored, &record)
+    }
+    h.records = append(restored, h.records...)
+    if len(h.records) > maxRecoveryRecords {
+        h.records = h.records[len(h.records)-maxRecoveryRecords:]
+    }
+    if cp.NextID > h.nextID {
+        h.nextID = cp.NextID
+    }
+}
+
+func copyRecord(record *RecoveryRecord) RecoveryRecord {
+    copied := *record
+    copied.Outcomes = append([]MigrationOutcome(nil), record.Outcomes...)
+    return copied
+}
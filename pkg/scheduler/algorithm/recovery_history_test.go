@@ -0,0 +1,27 @@
+package algorithm
+
+import (
+    "testing"
+)
+
+func TestRecoveryHistoryOutstanding(t *testing.T) {
+    h := NewRecoveryHistory()
+    plan := &RecoveryPlan{Jobs: []*JobPlan{{Migrations: []Migration{
+        {Namespace: "default", Pod: "a-0", PodUID: "a-0", FromNode: "n1-1"},
+        {Namespace: "default", Pod: "a-1", PodUID: "a-1", FromNode: "n1-2"},
+        {Namespace: "default", Pod: "b-0", PodUID: "b-0", FromNode: "n2-1"},
+        {Namespace: "default", Pod: "a-2", PodUID: "a-2", FromNode: "n1-1"},
+    }}}}
+    record := h.start(plan)
+    h.setOutcome(record, "default", "a-0", MigrationEvicted, nil)
+    h.setOutcome(record, "default", "a-1", MigrationEvicted, nil)
+    h.setOutcome(record, "default", "b-0", MigrationEvicted, nil)
+
+    // The replacement for a-1 has been bound and a-2 was never evicted
+    h.ObserveBinding(testPod("a", "a-1", "n3-1", 8, ""), "n3-1", "leaf-3")
+
+    got := h.outstanding([]string{"n1-1", "n1-2"})
+    if len(got) != 1 || !got["a-0"] {
+        t.Errorf("outstanding = %v, want only a-0", got)
+    }
+}
@@ -0,0 +1,274 @@
+package algorithm
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/client-go/util/flowcontrol"
+    "k8s.io/klog/v2"
+)
+
+// Recovery limits, sized so that losing a leaf switch moves its pods over a
+// few seconds instead of all at once
+const (
+    DefaultRecoveryConcurrency          = 4
+    DefaultRecoveryQPS                  = 5.0
+    DefaultRecoveryBurst                = 10
+    DefaultRecoveryFailureThreshold     = 10
+    DefaultRecoveryNodeFailureThreshold = 16
+    DefaultRecoveryFailureWindow        = 5 * time.Minute
+)
+
+// errRecoveryPaused is returned for migrations skipped while the circuit breaker is open
+var errRecoveryPaused = errors.New("automatic recovery is paused")
+
+// RecoveryLimits bounds how fast recovery migrates pods, and when it stops
+type RecoveryLimits struct {
+    // Concurrency is how many migrations may run at once across all recoveries
+    Concurrency int
+    // QPS and Burst limit how fast evictions are issued
+    QPS   float64
+    Burst int
+    // The circuit breaker opens when FailureThreshold migrations fail, or
+    // NodeFailureThreshold nodes fail, within FailureWindow. Zero disables a trigger.
+    FailureThreshold     int
+    NodeFailureThreshold int
+    FailureWindow        time.Duration
+}
+
+func DefaultRecoveryLimits() RecoveryLimits {
+    return RecoveryLimits{
+        Concurrency:          DefaultRecoveryConcurrency,
+        QPS:                  DefaultRecoveryQPS,
+        Burst:                DefaultRecoveryBurst,
+        FailureThreshold:     DefaultRecoveryFailureThreshold,
+        NodeFailureThreshold: DefaultRecoveryNodeFailureThreshold,
+        FailureWindow:        DefaultRecoveryFailureWindow,
+    }
+}
+
+// BreakerStatus is the state of the recovery circuit breaker
+type BreakerStatus struct {
+    Open               bool      `json:"open"`
+    Reason             string    `json:"reason,omitempty"`
+    OpenedAt           time.Time `json:"openedAt,omitempty"`
+    RecentFailures     int       `json:"recentFailures"`
+    RecentNodeFailures int       `json:"recentNodeFailures"`
+}
+
+// CircuitBreaker pauses automatic recovery cluster-wide once failures pile
+// up. It stays open until an operator resumes it.
+type CircuitBreaker struct {
+    sync.Mutex
+    limits       RecoveryLimits
+    failures     []time.Time
+    nodeFailures []time.Time
+    open         bool
+    reason       string
+    openedAt     time.Time
+}
+
+func NewCircuitBreaker(limits RecoveryLimits) *CircuitBreaker {
+    return &CircuitBreaker{limits: limits}
+}
+
+// allow returns errRecoveryPaused while the breaker is open
+func (b *CircuitBreaker) allow() error {
+    b.Lock()
+    defer b.Unlock()
+    if b.open {
+        return fmt.Errorf("%w: %s", errRecoveryPaused, b.reason)
+    }
+    return nil
+}
+
+// observeFailure records a failed migration and reports whether it opened the breaker
+func (b *CircuitBreaker) observeFailure(now time.Time) bool {
+    b.Lock()
+    defer b.Unlock()
+
+    b.failures = b.trimLocked(append(b.failures, now), now)
+    if b.open || b.limits.FailureThreshold <= 0 || len(b.failures) < b.limits.FailureThreshold {
+        return false
+    }
+    b.openLocked(now, fmt.Sprintf("%d migrations failed within %s", len(b.failures), b.limits.FailureWindow))
+    return true
+}
+
+// observeNodeFailure records a failed node and reports whether it opened the breaker
+func (b *CircuitBreaker) observeNodeFailure(now time.Time) bool {
+    b.Lock()
+    defer b.Unlock()
+
+    b.nodeFailures = b.trimLocked(append(b.nodeFailures, now), now)
+    if b.open || b.limits.NodeFailureThreshold <= 0 || len(b.nodeFailures) < b.limits.NodeFailureThreshold {
+        return false
+    }
+    b.openLocked(now, fmt.Sprintf("%d nodes failed within %s", len(b.nodeFailures), b.limits.FailureWindow))
+    return true
+}
+
+// Resume closes the breaker and forgets past failures
+func (b *CircuitBreaker) Resume() {
+    b.Lock()
+    defer b.Unlock()
+
+    b.open = false
+    b.reason = ""
+    b.openedAt = time.Time{}
+    b.failures = nil
+    b.nodeFailures = nil
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+    b.Lock()
+    defer b.Unlock()
+
+    now := time.Now()
+    return BreakerStatus{
+        Open:               b.open,
+        Reason:             b.reason,
+        OpenedAt:           b.openedAt,
+        RecentFailures:     recentTransitions(b.failures, now, b.limits.FailureWindow),
+        RecentNodeFailures: recentTransitions(b.nodeFailures, now, b.limits.FailureWindow),
+    }
+}
+
+func (b *CircuitBreaker) openLocked(now time.Time, reason string) {
+    b.open = true
+    b.reason = reason
+    b.openedAt = now
+}
+
+// trimLocked drops failures older than the window
+func (b *CircuitBreaker) trimLocked(failures []time.Time, now time.Time) []time.Time {
+    return failures[len(failures)-recentTransitions(failures, now, b.limits.FailureWindow):]
+}
+
+// BreakerCheckpoint is the circuit breaker state carried over to a new leader
+type BreakerCheckpoint struct {
+    Open         bool        `json:"open"`
+    Reason       string      `json:"reason,omitempty"`
+    OpenedAt     time.Time   `json:"openedAt,omitempty"`
+    Failures     []time.Time `json:"failures,omitempty"`
+    NodeFailures []time.Time `json:"nodeFailures,omitempty"`
+}
+
+func (b *CircuitBreaker) checkpoint() *BreakerCheckpoint {
+    b.Lock()
+    defer b.Unlock()
+
+    return &BreakerCheckpoint{
+        Open:         b.open,
+        Reason:       b.reason,
+        OpenedAt:     b.openedAt,
+        Failures:     append([]time.Time(nil), b.failures...),
+        NodeFailures: append([]time.Time(nil), b.nodeFailures...),
+    }
+}
+
+// restore puts the checkpointed failures before any seen by this leader, and
+// keeps the breaker open if it was open
+func (b *CircuitBreaker) restore(cp *BreakerCheckpoint) {
+    b.Lock()
+    defer b.Unlock()
+
+    now := time.Now()
+    b.failures = b.trimLocked(append(append([]time.Time(nil), cp.Failures...), b.failures...), now)
+    b.nodeFailures = b.trimLocked(append(append([]time.Time(nil), cp.NodeFailures...), b.nodeFailures...), now)
+    if cp.Open && !b.open {
+        b.openLocked(cp.OpenedAt, cp.Reason)
+    }
+}
+
+// SetLimits replaces the recovery limits. It must be called before recovery starts.
+func (rm *RecoveryManager) SetLimits(limits RecoveryLimits) {
+    if limits.Concurrency <= 0 {
+        limits.Concurrency = 1
+    }
+    rm.slots = make(chan struct{}, limits.Concurrency)
+    rm.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(limits.QPS), limits.Burst)
+    rm.breaker = NewCircuitBreaker(limits)
+}
+
+// Breaker returns the recovery circuit breaker
+func (rm *RecoveryManager) Breaker() *CircuitBreaker {
+    return rm.breaker
+}
+
+// ResumeRecovery closes the circuit breaker so automatic recovery runs again
+func (rm *RecoveryManager) ResumeRecovery() {
+    rm.breaker.Resume()
+    rm.scheduler.metrics.SetRecoveryPaused(false)
+    klog.Infof("Automatic recovery resumed")
+}
+
+// restoreBreaker restores the previous leader's breaker, staying paused if it was
+func (rm *RecoveryManager) restoreBreaker(cp *BreakerCheckpoint) {
+    rm.breaker.restore(cp)
+    if cp.Open {
+        rm.pause()
+    }
+}
+
+// admitNodeFailure counts a failed node against the breaker and fails if
+// recovery is paused. A node refused while the breaker is already open is not
+// counted, since it is offered again once recovery resumes.
+func (rm *RecoveryManager) admitNodeFailure(nodeName string) error {
+    if err := rm.breaker.allow(); err != nil {
+        return fmt.Errorf("not recovering node %s: %v", nodeName, err)
+    }
+    if rm.breaker.observeNodeFailure(time.Now()) {
+        rm.pause()
+    }
+    if err := rm.breaker.allow(); err != nil {
+        return fmt.Errorf("not recovering node %s: %v", nodeName, err)
+    }
+    return nil
+}
+
+// migrateLimited migrates a pod within the concurrency and rate limits,
+// unless the circuit breaker is open
+func (rm *RecoveryManager) migrateLimited(ctx context.Context, pod *v1.Pod, preferredDomain string, force bool) (err error) {
+    ctx, span := tracer.Start(ctx, "RecoveryManager.migratePod", trace.WithAttributes(podAttributes(pod)...))
+    span.SetAttributes(attribute.String("preferred_domain", preferredDomain), attribute.Bool("force", force))
+    defer func() { endSpan(span, err) }()
+
+    select {
+    case rm.slots <- struct{}{}:
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+    defer func() { <-rm.slots }()
+
+    if err := rm.limiter.Wait(ctx); err != nil {
+        return err
+    }
+    span.AddEvent("limits acquired")
+    // The breaker may have opened while this migration waited
+    if err := rm.breaker.allow(); err != nil {
+        rm.scheduler.metrics.IncRecoveryMigration("skipped")
+        return err
+    }
+
+    if err := rm.migratePod(ctx, pod, preferredDomain, force); err != nil {
+        rm.scheduler.metrics.IncRecoveryMigration("failed")
+        if rm.breaker.observeFailure(time.Now()) {
+            rm.pause()
+        }
+        return err
+    }
+    rm.scheduler.metrics.IncRecoveryMigration("success")
+    return nil
+}
+
+func (rm *RecoveryManager) pause() {
+    rm.scheduler.metrics.SetRecoveryPaused(true)
+    klog.Warningf("Automatic recovery paused: %s; resume it with POST /admin/recovery/resume", rm.breaker.Status().Reason)
+}
@@ -0,0 +1,134 @@
+package algorithm
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+    limits := RecoveryLimits{
+        FailureThreshold:     3,
+        NodeFailureThreshold: 2,
+        FailureWindow:        time.Minute,
+    }
+
+    tests := []struct {
+        name   string
+        limits RecoveryLimits
+        // failures and nodeFailures are observed at these offsets from the start
+        failures     []time.Duration
+        nodeFailures []time.Duration
+        open         bool
+    }{
+        {
+            name:     "closed below the failure threshold",
+            limits:   limits,
+            failures: []time.Duration{0, time.Second},
+        },
+        {
+            name:     "opens at the failure threshold",
+            limits:   limits,
+            failures: []time.Duration{0, time.Second, 2 * time.Second},
+            open:     true,
+        },
+        {
+            name:     "failures outside the window do not count",
+            limits:   limits,
+            failures: []time.Duration{0, time.Second, 2 * time.Minute},
+        },
+        {
+            name:         "opens at the node failure threshold",
+            limits:       limits,
+            nodeFailures: []time.Duration{0, 30 * time.Second},
+            open:         true,
+        },
+        {
+            name:         "node failures outside the window do not count",
+            limits:       limits,
+            nodeFailures: []time.Duration{0, 90 * time.Second},
+        },
+        {
+            name:         "zero thresholds never open",
+            limits:       RecoveryLimits{FailureWindow: time.Minute},
+            failures:     []time.Duration{0, 0, 0, 0},
+            nodeFailures: []time.Duration{0, 0, 0},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := NewCircuitBreaker(tt.limits)
+            start := time.Now()
+            opened := false
+            for _, offset := range tt.failures {
+                opened = b.observeFailure(start.Add(offset)) || opened
+            }
+            for _, offset := range tt.nodeFailures {
+                opened = b.observeNodeFailure(start.Add(offset)) || opened
+            }
+
+            if opened != tt.open {
+                t.Errorf("opened = %v, want %v", opened, tt.open)
+            }
+            if status := b.Status(); status.Open != tt.open {
+                t.Errorf("status open = %v, want %v", status.Open, tt.open)
+            }
+            err := b.allow()
+            if tt.open != errors.Is(err, errRecoveryPaused) {
+                t.Errorf("allow() = %v, want paused = %v", err, tt.open)
+            }
+        })
+    }
+}
+
+func TestCircuitBreakerResume(t *testing.T) {
+    b := NewCircuitBreaker(RecoveryLimits{FailureThreshold: 1, FailureWindow: time.Minute})
+    if !b.observeFailure(time.Now()) {
+        t.Fatalf("breaker did not open")
+    }
+    if b.observeFailure(time.Now()) {
+        t.Errorf("an open breaker opened again")
+    }
+
+    b.Resume()
+    status := b.Status()
+    if status.Open || status.Reason != "" || status.RecentFailures != 0 {
+        t.Errorf("status after resume = %+v, want closed with no failures", status)
+    }
+    if err := b.allow(); err != nil {
+        t.Errorf("allow() after resume = %v", err)
+    }
+}
+
+func TestCircuitBreakerCheckpoint(t *testing.T) {
+    limits := RecoveryLimits{FailureThreshold: 3, FailureWindow: time.Minute}
+    now := time.Now()
+
+    previous := NewCircuitBreaker(limits)
+    previous.observeFailure(now.Add(-2 * time.Minute))
+    previous.observeFailure(now.Add(-time.Second))
+    cp := previous.checkpoint()
+
+    // The failure still in the window counts towards the new leader's threshold
+    b := NewCircuitBreaker(limits)
+    b.restore(cp)
+    if b.observeFailure(now) {
+        t.Fatalf("breaker opened with 2 recent failures")
+    }
+    if !b.observeFailure(now) {
+        t.Errorf("breaker did not open with 3 recent failures")
+    }
+
+    // An open breaker stays open across failover
+    previous = NewCircuitBreaker(RecoveryLimits{FailureThreshold: 1, FailureWindow: time.Minute})
+    previous.observeFailure(now)
+    b = NewCircuitBreaker(limits)
+    b.restore(previous.checkpoint())
+    if status := b.Status(); !status.Open || status.Reason == "" {
+        t.Errorf("status after restore = %+v, want open", status)
+    }
+    if err := b.allow(); !errors.Is(err, errRecoveryPaused) {
+        t.Errorf("allow() after restore = %v, want paused", err)
+    }
+}
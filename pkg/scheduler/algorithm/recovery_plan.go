@@ -0,0 +1,470 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// RecoveryTarget names the failed nodes to plan recovery for, directly or as a whole domain
+type RecoveryTarget struct {
+    Nodes  []string
+    Domain string
+}
+
+// Migration is one pod the plan moves and the domain it is steered to
+type Migration struct {
+    Namespace    string    `json:"namespace"`
+    Pod          string    `json:"pod"`
+    PodUID       types.UID `json:"podUID"`
+    OwnerUID     types.UID `json:"ownerUID,omitempty"`
+    GPUs         int       `json:"gpus"`
+    FromNode     string    `json:"fromNode"`
+    FromDomain   string    `json:"fromDomain,omitempty"`
+    TargetDomain string    `json:"targetDomain,omitempty"`
+    // SpareNode is the hot spare the pod replaces its failed node with, if any
+    SpareNode string `json:"spareNode,omitempty"`
+    // Distance is from the domain holding the rest of the job to the target
+    Distance float64 `json:"distance"`
+    // Lost is false for healthy members moved because their job restarts
+    Lost bool `json:"lost"`
+}
+
+// JobPlan is the recovery of one job. Error is set when the job cannot be placed.
+type JobPlan struct {
+    Job         string      `json:"job"`
+    Policy      string      `json:"policy"`
+    Migrations  []Migration `json:"migrations"`
+    Degradation float64     `json:"degradation"`
+    Error       string      `json:"error,omitempty"`
+
+    // pods are the job's live pods, for execution and status reporting
+    pods []*v1.Pod
+    // lost are the members that must move, reported as unplaced if the job cannot be placed
+    lost []*v1.Pod
+}
+
+// RecoveryPlan is the set of migrations that recovers every GPU workload on
+// the failed nodes. Degradation sums how far moved pods land from the rest of
+// their job; a plan that keeps every job together has none.
+type RecoveryPlan struct {
+    CreatedAt time.Time `json:"createdAt"`
+    // Trigger is what the plan was executed for; previews have none
+    Trigger     string     `json:"trigger,omitempty"`
+    FailedNodes []string   `json:"failedNodes"`
+    Jobs        []*JobPlan `json:"jobs"`
+    MovedPods   int        `json:"movedPods"`
+    Degradation float64    `json:"degradation"`
+    Unplaced    []string   `json:"unplaced,omitempty"`
+}
+
+// Recovery triggers
+const (
+    RecoveryTriggerNodeFailure = "node-failure"
+    RecoveryTriggerGPUFailure  = "gpu-failure"
+    RecoveryTriggerDrain       = "drain"
+)
+
+// PlanRecovery computes the migrations needed if the target failed, without
+// executing them
+func (rm *RecoveryManager) PlanRecovery(ctx context.Context, target RecoveryTarget) (*RecoveryPlan, error) {
+    snapshot := rm.scheduler.cache.Snapshot()
+
+    failedNodes := append([]string(nil), target.Nodes...)
+    if target.Domain != "" {
+        domain, err := snapshot.GetDomain(target.Domain)
+        if err != nil {
+            return nil, err
+        }
+        for _, node := range domain.Nodes {
+            failedNodes = append(failedNodes, node.Name)
+        }
+    }
+    if len(failedNodes) == 0 {
+        return nil, fmt.Errorf("a node or domain is required")
+    }
+
+    var pods []*v1.Pod
+    for _, nodeName := range failedNodes {
+        podList, err := rm.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+            FieldSelector: "spec.nodeName=" + nodeName,
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+        }
+        for i := range podList.Items {
+            if !isTerminalPod(&podList.Items[i]) {
+                pods = append(pods, &podList.Items[i])
+            }
+        }
+    }
+
+    gpuPods, _ := rm.categorizePods(pods)
+    return rm.planForPods(ctx, snapshot, failedNodes, gpuPods)
+}
+
+// planForPods plans the recovery of the GPU pods lost on the failed nodes.
+// Only lost pods are moved unless a job's policy restarts it, and the largest
+// jobs are placed first since they fit in the fewest domains.
+func (rm *RecoveryManager) planForPods(ctx context.Context, snapshot *TopologySnapshot, failedNodes []string, lost []*v1.Pod) (*RecoveryPlan, error) {
+    ctx, span := tracer.Start(ctx, "RecoveryManager.planForPods", trace.WithAttributes(
+        attribute.StringSlice("failed_nodes", failedNodes),
+        attribute.Int("lost_pods", len(lost)),
+    ))
+    defer span.End()
+
+    plan := &RecoveryPlan{
+        CreatedAt:   time.Now(),
+        FailedNodes: failedNodes,
+    }
+
+    failed := make(map[string]bool, len(failedNodes))
+    for _, nodeName := range failedNodes {
+        failed[nodeName] = true
+    }
+    free := freeCapacity(snapshot, failed)
+    spares := &spareAllocator{candidates: rm.scheduler.spares.candidates(failed)}
+
+    type plannedJob struct {
+        group   *jobGroup
+        members []*v1.Pod
+        demand  int
+    }
+    var jobs []*plannedJob
+    for _, group := range groupPodsByJob(lost) {
+        members, err := rm.listJobMembers(group)
+        if err != nil {
+            return nil, err
+        }
+        job := &plannedJob{group: group, members: members}
+        for _, pod := range group.pods {
+            job.demand += getGPURequirements(pod)
+        }
+        if group.policy == v1alpha1.RecoveryPolicyRestartJob {
+            job.demand = 0
+            for _, pod := range members {
+                job.demand += getGPURequirements(pod)
+            }
+        }
+        jobs = append(jobs, job)
+    }
+    sort.SliceStable(jobs, func(i, j int) bool {
+        return jobs[i].demand > jobs[j].demand
+    })
+
+    for _, job := range jobs {
+        var jobPlan *JobPlan
+        if job.group.policy == v1alpha1.RecoveryPolicyRestartJob {
+            jobPlan = rm.scheduler.planJobRestart(snapshot, free, failed, job.group, job.members)
+        } else {
+            jobPlan = rm.scheduler.planMemberReplacement(snapshot, free, spares, failed, job.group, job.members)
+        }
+        jobPlan.pods = job.members
+        jobPlan.lost = job.group.pods
+
+        plan.Jobs = append(plan.Jobs, jobPlan)
+        if jobPlan.Error != "" {
+            plan.Unplaced = append(plan.Unplaced, jobPlan.Job)
+            continue
+        }
+        plan.MovedPods += len(jobPlan.Migrations)
+        plan.Degradation += jobPlan.Degradation
+    }
+    return plan, nil
+}
+
+// planMemberReplacement moves only the lost pods. A pod from a failed node
+// goes to a hot spare in its domain when one is free; otherwise each pod goes
+// to the closest domain to the rest of its job with room for it.
+func (ts *TopologyScheduler) planMemberReplacement(snapshot *TopologySnapshot, free map[string]int, spares *spareAllocator, failed map[string]bool, group *jobGroup, members []*v1.Pod) *JobPlan {
+    jobPlan := &JobPlan{Job: group.key, Policy: group.policy}
+
+    lost := append([]*v1.Pod(nil), group.pods...)
+    sort.SliceStable(lost, func(i, j int) bool {
+        return getGPURequirements(lost[i]) > getGPURequirements(lost[j])
+    })
+
+    anchor := anchorDomain(snapshot, failed, members)
+    var migrations []Migration
+    for _, pod := range lost {
+        gpus := getGPURequirements(pod)
+        from := anchor
+        if from == "" {
+            if domain, err := snapshot.GetDomainForNode(pod.Spec.NodeName); err == nil {
+                from = domain.Name
+            }
+        }
+
+        if failed[pod.Spec.NodeName] {
+            if spare, distance := spares.take(snapshot, pod.Spec.NodeName, from, gpus); spare != nil {
+                m := newMigration(snapshot, pod, spare.leaf, distance, true)
+                m.SpareNode = spare.node
+                migrations = append(migrations, m)
+                if anchor == "" {
+                    anchor = spare.leaf
+                    continue
+                }
+                jobPlan.Degradation += distance
+                continue
+            }
+        }
+
+        target, distance, ok := ts.closestDomain(snapshot, free, from, gpus)
+        if !ok {
+            jobPlan.Error = fmt.Sprintf("no reachable domain has %d free GPUs for pod %s/%s", gpus, pod.Namespace, pod.Name)
+            // Give back what was taken so other jobs can use it
+            for _, m := range migrations {
+                if m.SpareNode != "" {
+                    spares.giveBack(m)
+                    continue
+                }
+                free[m.TargetDomain] += m.GPUs
+            }
+            return jobPlan
+        }
+
+        free[target] -= gpus
+        migrations = append(migrations, newMigration(snapshot, pod, target, distance, true))
+        if anchor == "" {
+            // A fully lost job has no members to stay close to; keep the
+            // rest of it with the first replacement
+            anchor = target
+            continue
+        }
+        jobPlan.Degradation += distance
+    }
+    jobPlan.Migrations = migrations
+    return jobPlan
+}
+
+// planJobRestart moves every member of a job to the healthy domain with the
+// fewest free GPUs that holds the whole job, so large free domains stay available
+func (ts *TopologyScheduler) planJobRestart(snapshot *TopologySnapshot, free map[string]int, failed map[string]bool, group *jobGroup, members []*v1.Pod) *JobPlan {
+    jobPlan := &JobPlan{Job: group.key, Policy: group.policy}
+
+    demand := 0
+    released := make(map[string]int)
+    for _, pod := range members {
+        gpus := getGPURequirements(pod)
+        demand += gpus
+        if failed[pod.Spec.NodeName] {
+            continue
+        }
+        // Healthy members give their GPUs back when they are evicted
+        if domain, err := snapshot.GetDomainForNode(pod.Spec.NodeName); err == nil {
+            released[domain.Name] += gpus
+        }
+    }
+
+    target := ""
+    for _, domain := range sortedDomains(snapshot) {
+        available := free[domain.Name] + released[domain.Name]
+        if available < demand || !ts.isDomainHealthy(domain) || !isDomainReachable(domain) || !domain.Lifecycle.AcceptsPlacements() {
+            continue
+        }
+        if target == "" || available < free[target]+released[target] {
+            target = domain.Name
+        }
+    }
+    if target == "" {
+        jobPlan.Error = fmt.Sprintf("no domain has %d free GPUs for the job", demand)
+        return jobPlan
+    }
+
+    for domain, gpus := range released {
+        free[domain] += gpus
+    }
+    free[target] -= demand
+    for _, pod := range members {
+        jobPlan.Migrations = append(jobPlan.Migrations, newMigration(snapshot, pod, target, 0, failed[pod.Spec.NodeName]))
+    }
+    return jobPlan
+}
+
+// closestDomain returns the domain nearest to from that has room for gpus,
+// preferring the fullest one among equally close domains
+func (ts *TopologyScheduler) closestDomain(snapshot *TopologySnapshot, free map[string]int, from string, gpus int) (string, float64, bool) {
+    best, bestDistance, found := "", 0.0, false
+    for _, domain := range sortedDomains(snapshot) {
+        if free[domain.Name] < gpus || !ts.isDomainHealthy(domain) || !domain.Lifecycle.AcceptsPlacements() {
+            continue
+        }
+
+        distance := 0.0
+        if from != "" && domain.Name != from {
+            d, err := snapshot.GetDomainDistance(from, domain.Name)
+            if err != nil {
+                continue
+            }
+            distance = d
+        }
+
+        if !found || distance < bestDistance || (distance == bestDistance && free[domain.Name] < free[best]) {
+            best, bestDistance, found = domain.Name, distance, true
+        }
+    }
+    return best, bestDistance, found
+}
+
+// spareCandidate is an available spare as seen by one recovery plan
+type spareCandidate struct {
+    node  string
+    leaf  string
+    spine string
+    free  int
+    // failedNode is the node this spare replaces in the plan, once taken
+    failedNode string
+}
+
+// spareAllocator hands out spares while a plan is made. Each failed node gets
+// at most one spare, and a spare replaces at most one failed node.
+type spareAllocator struct {
+    candidates []*spareCandidate
+}
+
+// take returns the spare for a pod lost on failedNode and its distance from
+// the job's domain: the spare already given to that node if it has room,
+// otherwise a free spare in the node's leaf, then one under its spine
+func (a *spareAllocator) take(snapshot *TopologySnapshot, failedNode, from string, gpus int) (*spareCandidate, float64) {
+    domain, err := snapshot.GetDomainForNode(failedNode)
+    if err != nil {
+        return nil, 0
+    }
+
+    reach := func(c *spareCandidate) (float64, bool) {
+        if from == "" || from == c.leaf {
+            return 0, true
+        }
+        distance, err := snapshot.GetDomainDistance(from, c.leaf)
+        return distance, err == nil
+    }
+
+    for _, c := range a.candidates {
+        if c.failedNode == failedNode {
+            distance, ok := reach(c)
+            if !ok || c.free < gpus {
+                return nil, 0
+            }
+            c.free -= gpus
+            return c, distance
+        }
+    }
+
+    var best *spareCandidate
+    bestDistance := 0.0
+    for _, c := range a.candidates {
+        if c.failedNode != "" || c.free < gpus {
+            continue
+        }
+        if leaf, err := snapshot.GetDomain(c.leaf); err != nil || !leaf.Lifecycle.AcceptsPlacements() {
+            continue
+        }
+        sameLeaf := c.leaf == domain.Name
+        if !sameLeaf && (domain.SpineSwitch == "" || c.spine != domain.SpineSwitch) {
+            continue
+        }
+        distance, ok := reach(c)
+        if !ok {
+            continue
+        }
+        if best == nil || (sameLeaf && best.leaf != domain.Name) {
+            best, bestDistance = c, distance
+        }
+    }
+    if best == nil {
+        return nil, 0
+    }
+    best.failedNode = failedNode
+    best.free -= gpus
+    return best, bestDistance
+}
+
+// giveBack returns a migration's GPUs to its spare
+func (a *spareAllocator) giveBack(m Migration) {
+    for _, c := range a.candidates {
+        if c.node == m.SpareNode {
+            c.free += m.GPUs
+        }
+    }
+}
+
+// freeCapacity returns the GPUs each domain can take once the failed nodes are gone
+func freeCapacity(snapshot *TopologySnapshot, failed map[string]bool) map[string]int {
+    free := make(map[string]int)
+    for _, domain := range snapshot.GetAllDomains() {
+        available := domain.TotalGPUs - domain.UsedGPUs
+        for _, node := range domain.Nodes {
+            if failed[node.Name] {
+                available -= snapshot.GetNodeCapacity(node.Name) - snapshot.GetNodeGPUs(node.Name)
+            }
+        }
+        free[domain.Name] = max(available, 0)
+    }
+    return free
+}
+
+// anchorDomain returns the domain holding most of a job's surviving members
+func anchorDomain(snapshot *TopologySnapshot, failed map[string]bool, members []*v1.Pod) string {
+    counts := make(map[string]int)
+    for _, pod := range members {
+        if pod.Spec.NodeName == "" || failed[pod.Spec.NodeName] {
+            continue
+        }
+        if domain, err := snapshot.GetDomainForNode(pod.Spec.NodeName); err == nil {
+            counts[domain.Name]++
+        }
+    }
+
+    anchor := ""
+    for name, count := range counts {
+        if anchor == "" || count > counts[anchor] || (count == counts[anchor] && name < anchor) {
+            anchor = name
+        }
+    }
+    return anchor
+}
+
+func newMigration(snapshot *TopologySnapshot, pod *v1.Pod, target string, distance float64, lost bool) Migration {
+    m := Migration{
+        Namespace:    pod.Namespace,
+        Pod:          pod.Name,
+        PodUID:       pod.UID,
+        GPUs:         getGPURequirements(pod),
+        FromNode:     pod.Spec.NodeName,
+        TargetDomain: target,
+        Distance:     distance,
+        Lost:         lost,
+    }
+    if owner := metav1.GetControllerOf(pod); owner != nil {
+        m.OwnerUID = owner.UID
+    }
+    if domain, err := snapshot.GetDomainForNode(pod.Spec.NodeName); err == nil {
+        m.FromDomain = domain.Name
+    }
+    return m
+}
+
+// sortedDomains returns the snapshot's domains by name, so plans are deterministic
+func sortedDomains(snapshot *TopologySnapshot) []*Domain {
+    domains := snapshot.GetAllDomains()
+    sort.Slice(domains, func(i, j int) bool {
+        return domains[i].Name < domains[j].Name
+    })
+    return domains
+}
+
+func max(a, b int) int {
+    if a > b {
+        return a
+    }
+    return b
+}
@@ -0,0 +1,376 @@
+package algorithm
+
+import (
+    "context"
+    "testing"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/resource"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/client-go/kubernetes/fake"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// testTopology has three leaves under spine-a, the last one twice as large,
+// and one leaf under spine-b. Every node has 8 GPUs.
+func testTopology() *TopologyScheduler {
+    cache := NewTopologyCache(NewNodeCache())
+    for _, node := range []*v1.Node{
+        testNode("n1-1", "leaf-1", "spine-a"),
+        testNode("n1-2", "leaf-1", "spine-a"),
+        testNode("n2-1", "leaf-2", "spine-a"),
+        testNode("n2-2", "leaf-2", "spine-a"),
+        testNode("n3-1", "leaf-3", "spine-a"),
+        testNode("n3-2", "leaf-3", "spine-a"),
+        testNode("n3-3", "leaf-3", "spine-a"),
+        testNode("n3-4", "leaf-3", "spine-a"),
+        testNode("n4-1", "leaf-4", "spine-b"),
+        testNode("n4-2", "leaf-4", "spine-b"),
+    } {
+        cache.UpsertNode(node)
+    }
+    return NewTopologyScheduler(cache)
+}
+
+func testNode(name, leaf, spine string) *v1.Node {
+    return &v1.Node{
+        ObjectMeta: metav1.ObjectMeta{
+            Name: name,
+            Labels: map[string]string{
+                v1alpha1.LabelLeafDomain:  leaf,
+                v1alpha1.LabelSpineDomain: spine,
+            },
+        },
+        Status: v1.NodeStatus{
+            Allocatable: v1.ResourceList{
+                gpuResourceName: *resource.NewQuantity(8, resource.DecimalSI),
+            },
+            Conditions: []v1.NodeCondition{
+                {Type: v1.NodeReady, Status: v1.ConditionTrue},
+            },
+        },
+    }
+}
+
+func testPod(job, name, nodeName string, gpus int64, policy string) *v1.Pod {
+    pod := &v1.Pod{
+        ObjectMeta: metav1.ObjectMeta{
+            Name:      name,
+            Namespace: "default",
+            UID:       "uid-" + types.UID(name),
+            Labels:    map[string]string{"job-name": job},
+        },
+        Spec: v1.PodSpec{
+            NodeName: nodeName,
+            Containers: []v1.Container{{
+                Name: "train",
+                Resources: v1.ResourceRequirements{
+                    Limits: v1.ResourceList{
+                        gpuResourceName: *resource.NewQuantity(gpus, resource.DecimalSI),
+                    },
+                },
+            }},
+        },
+    }
+    if policy != "" {
+        pod.Annotations = map[string]string{v1alpha1.AnnotationRecoveryPolicy: policy}
+    }
+    return pod
+}
+
+func TestPlanForPods(t *testing.T) {
+    tests := []struct {
+        name        string
+        failedNodes []string
+        lost        []*v1.Pod
+        // targets is the planned domain per lost pod
+        targets   map[string]string
+        movedPods int
+        unplaced  []string
+    }{
+        {
+            name:        "lost pod stays in its leaf",
+            failedNodes: []string{"n1-1"},
+            lost:        []*v1.Pod{testPod("a", "a-0", "n1-1", 8, "")},
+            targets:     map[string]string{"a-0": "leaf-1"},
+            movedPods:   1,
+        },
+        {
+            name:        "lost pod goes to the fullest close leaf with room",
+            failedNodes: []string{"n1-1"},
+            lost:        []*v1.Pod{testPod("a", "a-0", "n1-1", 16, "")},
+            targets:     map[string]string{"a-0": "leaf-2"},
+            movedPods:   1,
+        },
+        {
+            name:        "replacements of a fully lost job stay together",
+            failedNodes: []string{"n1-1", "n1-2"},
+            lost: []*v1.Pod{
+                testPod("a", "a-0", "n1-1", 8, ""),
+                testPod("a", "a-1", "n1-2", 8, ""),
+            },
+            targets:   map[string]string{"a-0": "leaf-2", "a-1": "leaf-2"},
+            movedPods: 2,
+        },
+        {
+            name:        "largest job is placed first",
+            failedNodes: []string{"n1-1", "n2-1", "n2-2"},
+            lost: []*v1.Pod{
+                testPod("small", "small-0", "n2-1", 16, ""),
+                testPod("large", "large-0", "n2-2", 32, ""),
+            },
+            targets:   map[string]string{"large-0": "leaf-3"},
+            movedPods: 1,
+            unplaced:  []string{"default/small"},
+        },
+        {
+            name:        "job with no room anywhere is unplaced",
+            failedNodes: []string{"n1-1"},
+            lost:        []*v1.Pod{testPod("a", "a-0", "n1-1", 64, "")},
+            targets:     map[string]string{},
+            unplaced:    []string{"default/a"},
+        },
+        {
+            name:        "restarted job is moved whole",
+            failedNodes: []string{"n1-1"},
+            lost:        []*v1.Pod{testPod("r", "r-0", "n1-1", 8, v1alpha1.RecoveryPolicyRestartJob)},
+            targets:     map[string]string{"r-0": "leaf-1"},
+            movedPods:   1,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ts := testTopology()
+            rm := NewRecoveryManager(NewDomainManager(), ts, fake.NewSimpleClientset())
+
+            plan, err := rm.planForPods(context.Background(), ts.cache.Snapshot(), tt.failedNodes, tt.lost)
+            if err != nil {
+                t.Fatalf("planForPods failed: %v", err)
+            }
+            if plan.MovedPods != tt.movedPods {
+                t.Errorf("moved %d pods, want %d", plan.MovedPods, tt.movedPods)
+            }
+            if len(plan.Unplaced) != len(tt.unplaced) {
+                t.Fatalf("unplaced %v, want %v", plan.Unplaced, tt.unplaced)
+            }
+            for i := range tt.unplaced {
+                if plan.Unplaced[i] != tt.unplaced[i] {
+                    t.Errorf("unplaced %v, want %v", plan.Unplaced, tt.unplaced)
+                }
+            }
+
+            targets := make(map[string]string)
+            for _, jobPlan := range plan.Jobs {
+                for _, m := range jobPlan.Migrations {
+                    targets[m.Pod] = m.TargetDomain
+                }
+            }
+            if len(targets) != len(tt.targets) {
+                t.Errorf("planned %v, want %v", targets, tt.targets)
+            }
+            for pod, want := range tt.targets {
+                if targets[pod] != want {
+                    t.Errorf("pod %s planned to %q, want %q", pod, targets[pod], want)
+                }
+            }
+        })
+    }
+}
+
+func TestPlanMemberReplacementUsesSpare(t *testing.T) {
+    ts := testTopology()
+    snapshot := ts.cache.Snapshot()
+    failed := map[string]bool{"n1-1": true}
+    free := freeCapacity(snapshot, failed)
+    spares := &spareAllocator{candidates: []*spareCandidate{
+        {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 8},
+    }}
+    lost := []*v1.Pod{testPod("a", "a-0", "n1-1", 8, "")}
+    group := groupPodsByJob(lost)[0]
+
+    jobPlan := ts.planMemberReplacement(snapshot, free, spares, failed, group, lost)
+    if jobPlan.Error != "" {
+        t.Fatalf("plan failed: %s", jobPlan.Error)
+    }
+    if len(jobPlan.Migrations) != 1 {
+        t.Fatalf("planned %d migrations, want 1", len(jobPlan.Migrations))
+    }
+    m := jobPlan.Migrations[0]
+    if m.SpareNode != "n1-2" || m.TargetDomain != "leaf-1" {
+        t.Errorf("planned to spare %q in %q, want n1-2 in leaf-1", m.SpareNode, m.TargetDomain)
+    }
+    if free["leaf-1"] != 8 {
+        t.Errorf("leaf-1 has %d free GPUs, want the spare not to take from it", free["leaf-1"])
+    }
+}
+
+func TestPlanJobRestart(t *testing.T) {
+    members := []*v1.Pod{
+        testPod("r", "r-0", "n1-1", 8, v1alpha1.RecoveryPolicyRestartJob),
+        testPod("r", "r-1", "n2-1", 8, v1alpha1.RecoveryPolicyRestartJob),
+    }
+
+    tests := []struct {
+        name   string
+        free   map[string]int
+        target string
+        // freeAfter is the free GPUs left in each listed domain
+        freeAfter map[string]int
+    }{
+        {
+            name:      "fullest leaf holding the job, counting the GPUs its members release",
+            free:      map[string]int{"leaf-1": 8, "leaf-2": 8, "leaf-3": 32, "leaf-4": 24},
+            target:    "leaf-2",
+            freeAfter: map[string]int{"leaf-2": 0, "leaf-3": 32},
+        },
+        {
+            name:      "leaf with room for the whole job",
+            free:      map[string]int{"leaf-1": 0, "leaf-2": 0, "leaf-3": 32, "leaf-4": 8},
+            target:    "leaf-3",
+            freeAfter: map[string]int{"leaf-2": 8, "leaf-3": 16},
+        },
+        {
+            name:   "no leaf holds the job",
+            free:   map[string]int{"leaf-1": 8, "leaf-2": 0, "leaf-3": 8, "leaf-4": 8},
+            target: "",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ts := testTopology()
+            failed := map[string]bool{"n1-1": true}
+            group := groupPodsByJob(members[:1])[0]
+
+            jobPlan := ts.planJobRestart(ts.cache.Snapshot(), tt.free, failed, group, members)
+            if tt.target == "" {
+                if jobPlan.Error == "" {
+                    t.Fatalf("planned %v, want an error", jobPlan.Migrations)
+                }
+                return
+            }
+            if jobPlan.Error != "" {
+                t.Fatalf("plan failed: %s", jobPlan.Error)
+            }
+            if len(jobPlan.Migrations) != len(members) {
+                t.Fatalf("planned %d migrations, want %d", len(jobPlan.Migrations), len(members))
+            }
+            for _, m := range jobPlan.Migrations {
+                if m.TargetDomain != tt.target {
+                    t.Errorf("pod %s planned to %q, want %q", m.Pod, m.TargetDomain, tt.target)
+                }
+                if wantLost := m.FromNode == "n1-1"; m.Lost != wantLost {
+                    t.Errorf("pod %s lost = %v, want %v", m.Pod, m.Lost, wantLost)
+                }
+            }
+            for domain, want := range tt.freeAfter {
+                if tt.free[domain] != want {
+                    t.Errorf("%s has %d free GPUs, want %d", domain, tt.free[domain], want)
+                }
+            }
+        })
+    }
+}
+
+func TestSpareAllocatorTake(t *testing.T) {
+    type take struct {
+        failedNode string
+        from       string
+        gpus       int
+        // spare is the spare node expected, or "" for none
+        spare string
+    }
+
+    tests := []struct {
+        name       string
+        candidates []*spareCandidate
+        takes      []take
+    }{
+        {
+            name: "spare in the failed node's leaf before one under its spine",
+            candidates: []*spareCandidate{
+                {node: "n2-2", leaf: "leaf-2", spine: "spine-a", free: 8},
+                {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 8},
+            },
+            takes: []take{{failedNode: "n1-1", gpus: 8, spare: "n1-2"}},
+        },
+        {
+            name: "spare under the same spine when the leaf has none",
+            candidates: []*spareCandidate{
+                {node: "n2-2", leaf: "leaf-2", spine: "spine-a", free: 8},
+            },
+            takes: []take{{failedNode: "n1-1", gpus: 8, spare: "n2-2"}},
+        },
+        {
+            name: "no spare under another spine",
+            candidates: []*spareCandidate{
+                {node: "n4-2", leaf: "leaf-4", spine: "spine-b", free: 8},
+            },
+            takes: []take{{failedNode: "n1-1", gpus: 8}},
+        },
+        {
+            name: "no spare too small for the pod",
+            candidates: []*spareCandidate{
+                {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 4},
+            },
+            takes: []take{{failedNode: "n1-1", gpus: 8}},
+        },
+        {
+            name: "pods of one failed node share its spare",
+            candidates: []*spareCandidate{
+                {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 8},
+            },
+            takes: []take{
+                {failedNode: "n1-1", gpus: 4, spare: "n1-2"},
+                {failedNode: "n1-1", gpus: 4, spare: "n1-2"},
+                {failedNode: "n1-1", gpus: 4},
+            },
+        },
+        {
+            name: "a spare replaces one failed node",
+            candidates: []*spareCandidate{
+                {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 8},
+            },
+            takes: []take{
+                {failedNode: "n1-1", gpus: 4, spare: "n1-2"},
+                {failedNode: "n2-1", gpus: 4},
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            snapshot := testTopology().cache.Snapshot()
+            allocator := &spareAllocator{candidates: tt.candidates}
+            for i, tk := range tt.takes {
+                spare, _ := allocator.take(snapshot, tk.failedNode, tk.from, tk.gpus)
+                got := ""
+                if spare != nil {
+                    got = spare.node
+                }
+                if got != tk.spare {
+                    t.Errorf("take %d for %s got spare %q, want %q", i, tk.failedNode, got, tk.spare)
+                }
+            }
+        })
+    }
+}
+
+func TestSpareAllocatorGiveBack(t *testing.T) {
+    snapshot := testTopology().cache.Snapshot()
+    allocator := &spareAllocator{candidates: []*spareCandidate{
+        {node: "n1-2", leaf: "leaf-1", spine: "spine-a", free: 8},
+    }}
+
+    spare, _ := allocator.take(snapshot, "n1-1", "", 8)
+    if spare == nil {
+        t.Fatalf("no spare taken")
+    }
+    allocator.giveBack(Migration{SpareNode: "n1-2", GPUs: 8})
+    if spare.free != 8 {
+        t.Errorf("spare has %d free GPUs after give back, want 8", spare.free)
+    }
+}
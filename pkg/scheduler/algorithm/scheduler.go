@@ -1,147 +1,314 @@
-package algorithm
-
-import (
-    "context"
-    "fmt"
-    "sort"
-    "sync"
-    "time"
-    v1 "k8s.io/api/core/v1"
-)
-
-type TopologyScheduler struct {
-    sync.RWMutex
-    cache            *TopologyCache
-    scoreWeights     TopologyScore
-    domains          map[string]*Domain
-    spineConnections map[string][]string
-    metrics          *MetricsCollector
-    monitor          *DomainMonitor
-}
-
-func NewTopologyScheduler(cache *TopologyCache) *TopologyScheduler {
-    ts := &TopologyScheduler{
-        cache: cache,
-        scoreWeights: TopologyScore{
-            ResourceAvailability: 0.4,
-            TopologyAlignment:    0.3,
-            DomainUtilization:    0.2,
-            HistoricalPerf:       0.1,
-        },
-        domains:          make(map[string]*Domain),
-        spineConnections: make(map[string][]string),
-        metrics:          NewMetricsCollector(),
-    }
-    ts.monitor = NewDomainMonitor(ts)
-    return ts
-}
-
-func (ts *TopologyScheduler) Schedule(ctx context.Context, pod *v1.Pod) (*v1.Node, error) {
-    startTime := time.Now()
-    defer func() {
-        ts.metrics.ObserveSchedulingLatency(time.Since(startTime))
-    }()
-
-    gpuReq, err := ts.getGPURequirements(pod)
-    if err != nil {
-        ts.metrics.IncSchedulingError("invalid_gpu_requirements")
-        return nil, fmt.Errorf("failed to get GPU requirements: %v", err)
-    }
-
-    strategy := ts.getPlacementStrategy(gpuReq)
-    var result *PlacementResult
-    
-    switch strategy {
-    case SingleDomain:
-        result, err = ts.placePodSingleDomain(ctx, pod, gpuReq)
-    case CompleteDomain:
-        result, err = ts.placeCompleteDomain(ctx, pod, gpuReq)
-    case AdjacentDomains:
-        result, err = ts.placePodAdjacentDomains(ctx, pod, gpuReq)
-    case MultipleDomains:
-        result, err = ts.placePodMultipleDomains(ctx, pod, gpuReq)
-    default:
-        ts.metrics.IncSchedulingError("invalid_strategy")
-        return nil, fmt.Errorf("unsupported placement strategy")
-    }
-
-    if err != nil {
-        ts.metrics.IncSchedulingError(fmt.Sprintf("placement_%s", strategy))
-        return nil, err
-    }
-
-    ts.metrics.ObservePlacementResult(result)
-    ts.updateDomainState(result)
-
-    return result.Nodes[0], nil
-}
-
-func (ts *TopologyScheduler) getPlacementStrategy(gpuReq *GPURequirements) PlacementStrategy {
-    switch {
-    case gpuReq.NodesNeeded <= 2:
-        return SingleDomain
-    case gpuReq.NodesNeeded == 4:
-        return CompleteDomain
-    case gpuReq.NodesNeeded <= 8:
-        return AdjacentDomains
-    default:
-        return MultipleDomains
-    }
-}
-
-func (ts *TopologyScheduler) findCompleteFreeDomains() []*Domain {
-    var freeDomains []*Domain
-    for _, domain := range ts.domains {
-        if domain.UsedGPUs == 0 {
-            freeDomains = append(freeDomains, domain)
-        }
-    }
-    return freeDomains
-}
-
-func (ts *TopologyScheduler) selectNodesAcrossDomains(domains []*Domain, gpuReq *GPURequirements) ([]*v1.Node, error) {
-    var selectedNodes []*v1.Node
-    remainingNodes := gpuReq.NodesNeeded
-
-    for _, domain := range domains {
-        availableNodes := ts.getAvailableNodes(domain)
-        if len(availableNodes) == 0 {
-            continue
-        }
-
-        nodesFromDomain := min(remainingNodes, len(availableNodes))
-        selectedNodes = append(selectedNodes, availableNodes[:nodesFromDomain]...)
-        remainingNodes -= nodesFromDomain
-
-        if remainingNodes == 0 {
-            break
-        }
-    }
-
-    if remainingNodes > 0 {
-        return nil, fmt.Errorf("insufficient nodes across domains")
-    }
-
-    return selectedNodes, nil
-}
-
-func (ts *TopologyScheduler) updateNodeState(node *v1.Node) error {
-    ts.Lock()
-    defer ts.Unlock()
-
-    domain := ts.getDomainForNode(node)
-    if domain == nil {
-        return fmt.Errorf("node %s not found in any domain", node.Name)
-    }
-
-    gpus := ts.getUsedGPUs(node)
-    domain.UsedGPUs += gpus
-    return nil
-}
-
-func min(a, b int) int {
-    if a < b {
-        return a
-    }
-    return b
-}
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/klog/v2"
+)
+
+type TopologyScheduler struct {
+    sync.RWMutex
+    cache            *TopologyCache
+    config           *SchedulerConfig
+    domains          map[string]*Domain
+    spineConnections map[string][]string
+    metrics          *MetricsCollector
+    monitor          *DomainMonitor
+    cycleAuditor     *CacheAuditor
+    // placementHints are keyed by controller UID and then domain
+    placementHints   map[types.UID]map[string]*placementHint
+    recoveries       *RecoveryHistory
+    spares           *SparePool
+    events           *EventEmitter
+    decisions        atomic.Pointer[DecisionLog]
+}
+
+var (
+    metricsOnce      sync.Once
+    metricsCollector *MetricsCollector
+)
+
+// sharedMetricsCollector returns the process's metrics collector. Its metrics
+// are registered with the default registry, which allows one registration.
+func sharedMetricsCollector() *MetricsCollector {
+    metricsOnce.Do(func() {
+        metricsCollector = NewMetricsCollector()
+    })
+    return metricsCollector
+}
+
+func NewTopologyScheduler(cache *TopologyCache) *TopologyScheduler {
+    config := DefaultSchedulerConfig()
+    ts := &TopologyScheduler{
+        cache:            cache,
+        config:           config,
+        domains:          make(map[string]*Domain),
+        spineConnections: make(map[string][]string),
+        metrics:          sharedMetricsCollector(),
+        placementHints:   make(map[types.UID]map[string]*placementHint),
+        recoveries:       NewRecoveryHistory(),
+        events:           NewEventEmitter(),
+    }
+    ts.monitor = NewDomainMonitor(ts)
+    ts.spares = NewSparePool(ts)
+    cache.SetHealthObserver(ts.metrics)
+    return ts
+}
+
+func (ts *TopologyScheduler) Schedule(ctx context.Context, pod *v1.Pod) (node *v1.Node, err error) {
+    startTime := time.Now()
+    ctx, span := tracer.Start(ctx, "TopologyScheduler.Schedule", trace.WithAttributes(podAttributes(pod)...))
+    defer func() {
+        ts.metrics.ObserveSchedulingLatency(time.Since(startTime))
+        endSpan(span, err)
+    }()
+    defer ts.auditCycle(ctx)
+
+    gpuReq, err := ts.getGPURequirements(pod)
+    if err != nil {
+        ts.metrics.IncSchedulingError("invalid_gpu_requirements")
+        return nil, fmt.Errorf("failed to get GPU requirements: %v", err)
+    }
+
+    decision := podDecision(DecisionPlacement, pod, gpuReq)
+    decision.PreferredDomain, _ = ts.preferredDomain(pod)
+    strategy := ts.getPlacementStrategy(gpuReq)
+    span.SetAttributes(
+        attribute.Int("gpus", decision.Requirements.GPUs),
+        attribute.Int("nodes_needed", gpuReq.NodesNeeded),
+        attribute.String("strategy", string(strategy)),
+    )
+    result, err := ts.tracedPlaceWithStrategy(ctx, pod, gpuReq, strategy)
+    if err != nil {
+        ts.metrics.IncSchedulingError(fmt.Sprintf("placement_%s", strategy))
+        decision.Type = DecisionRejection
+        decision.Strategy = string(strategy)
+        decision.Error = err.Error()
+        decision.LatencyMillis = millisSince(startTime)
+        ts.recordDecision(decision)
+        return nil, err
+    }
+
+    ts.metrics.ObservePlacementResult(result)
+    ts.updateDomainState(result)
+    ts.recordJobPlacement(pod, result)
+    ts.events.Eventf(pod, v1.EventTypeNormal, EventReasonPlaced,
+        "Placed with strategy %s on domains %s", result.Strategy, strings.Join(ts.resultDomains(result), ", "))
+
+    decision.Strategy = string(result.Strategy)
+    decision.Candidates = ts.resultCandidates(result, gpuReq)
+    for _, node := range result.Nodes {
+        decision.Nodes = append(decision.Nodes, node.Name)
+    }
+    decision.LatencyMillis = millisSince(startTime)
+    ts.recordDecision(decision)
+
+    return result.Nodes[0], nil
+}
+
+// tracedPlaceWithStrategy places the pod with the strategy in its own span
+func (ts *TopologyScheduler) tracedPlaceWithStrategy(ctx context.Context, pod *v1.Pod, gpuReq *GPURequirements, strategy PlacementStrategy) (*PlacementResult, error) {
+    ctx, span := tracer.Start(ctx, "TopologyScheduler.placeWithStrategy",
+        trace.WithAttributes(attribute.String("strategy", string(strategy))))
+    result, err := ts.placeWithStrategy(ctx, pod, gpuReq, strategy)
+    if err == nil {
+        span.SetAttributes(attribute.StringSlice("domains", ts.resultDomains(result)))
+    }
+    endSpan(span, err)
+    return result, err
+}
+
+func (ts *TopologyScheduler) placeWithStrategy(ctx context.Context, pod *v1.Pod, gpuReq *GPURequirements, strategy PlacementStrategy) (*PlacementResult, error) {
+    switch strategy {
+    case SingleDomain:
+        return ts.placePodSingleDomain(ctx, pod, gpuReq)
+    case CompleteDomain:
+        return ts.placeCompleteDomain(ctx, pod, gpuReq)
+    case AdjacentDomains:
+        return ts.placePodAdjacentDomains(ctx, pod, gpuReq)
+    case MultipleDomains:
+        return ts.placePodMultipleDomains(ctx, pod, gpuReq)
+    default:
+        ts.metrics.IncSchedulingError("invalid_strategy")
+        return nil, fmt.Errorf("unsupported placement strategy")
+    }
+}
+
+func (ts *TopologyScheduler) getPlacementStrategy(gpuReq *GPURequirements) PlacementStrategy {
+    switch {
+    case gpuReq.NodesNeeded <= 2:
+        return SingleDomain
+    case gpuReq.NodesNeeded == 4:
+        return CompleteDomain
+    case gpuReq.NodesNeeded <= 8:
+        return AdjacentDomains
+    default:
+        return MultipleDomains
+    }
+}
+
+func (ts *TopologyScheduler) findCompleteFreeDomains() []*Domain {
+    var freeDomains []*Domain
+    for _, domain := range ts.domains {
+        if domain.UsedGPUs == 0 && ts.isDomainHealthy(domain) && domain.Lifecycle.AcceptsPlacements() {
+            freeDomains = append(freeDomains, domain)
+        }
+    }
+    return freeDomains
+}
+
+func (ts *TopologyScheduler) selectNodesAcrossDomains(domains []*Domain, gpuReq *GPURequirements) ([]*v1.Node, error) {
+    var selectedNodes []*v1.Node
+    remainingNodes := gpuReq.NodesNeeded
+
+    for _, domain := range domains {
+        if !ts.isDomainHealthy(domain) || !domain.Lifecycle.AcceptsPlacements() || (len(domains) > 1 && !isDomainReachable(domain)) {
+            continue
+        }
+        availableNodes := ts.getAvailableNodes(domain)
+        if len(availableNodes) == 0 {
+            continue
+        }
+
+        nodesFromDomain := min(remainingNodes, len(availableNodes))
+        selectedNodes = append(selectedNodes, availableNodes[:nodesFromDomain]...)
+        remainingNodes -= nodesFromDomain
+
+        if remainingNodes == 0 {
+            break
+        }
+    }
+
+    if remainingNodes > 0 {
+        return nil, fmt.Errorf("insufficient nodes across domains")
+    }
+
+    return selectedNodes, nil
+}
+
+func (ts *TopologyScheduler) updateNodeState(node *v1.Node) error {
+    ts.Lock()
+    defer ts.Unlock()
+
+    domain := ts.getDomainForNode(node)
+    if domain == nil {
+        return fmt.Errorf("node %s not found in any domain", node.Name)
+    }
+
+    gpus := ts.getUsedGPUs(node)
+    domain.UsedGPUs += gpus
+    return nil
+}
+
+// SetCycleAuditor makes the scheduler audit its caches after every scheduling
+// cycle. This is a debug aid for tests; pass nil to disable it.
+func (ts *TopologyScheduler) SetCycleAuditor(auditor *CacheAuditor) {
+    ts.Lock()
+    defer ts.Unlock()
+    ts.cycleAuditor = auditor
+}
+
+func (ts *TopologyScheduler) auditCycle(ctx context.Context) {
+    ts.RLock()
+    auditor := ts.cycleAuditor
+    ts.RUnlock()
+
+    if auditor == nil {
+        return
+    }
+    if _, err := auditor.RunOnce(ctx); err != nil {
+        klog.Errorf("Post-cycle cache audit failed: %v", err)
+    }
+}
+
+func (ts *TopologyScheduler) recordJobPlacement(pod *v1.Pod, result *PlacementResult) {
+    jobName := getJobName(pod)
+    for _, node := range result.Nodes {
+        domain, err := ts.cache.GetDomainForNode(node.Name)
+        if err != nil {
+            continue
+        }
+        if err := ts.cache.AddJobToDomain(jobName, domain.Name); err != nil {
+            klog.V(4).Infof("Failed to record job %s on domain %s: %v", jobName, domain.Name, err)
+        }
+    }
+}
+
+// resultDomains returns the sorted domains a placement spans
+func (ts *TopologyScheduler) resultDomains(result *PlacementResult) []string {
+    seen := make(map[string]bool)
+    var names []string
+    for _, node := range result.Nodes {
+        domain, err := ts.cache.GetDomainForNode(node.Name)
+        if err != nil || seen[domain.Name] {
+            continue
+        }
+        seen[domain.Name] = true
+        names = append(names, domain.Name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// resultCandidates scores every domain the placement could have used for the
+// decision log, best first, with the domains it chose among them
+func (ts *TopologyScheduler) resultCandidates(result *PlacementResult, gpuReq *GPURequirements) []DomainCandidate {
+    chosen := make(map[string]bool)
+    for _, name := range ts.resultDomains(result) {
+        chosen[name] = true
+    }
+
+    var candidates []DomainCandidate
+    for _, domain := range ts.domains {
+        available := len(ts.getAvailableNodes(domain))
+        usable := available > 0 && ts.isDomainHealthy(domain) && domain.Lifecycle.AcceptsPlacements()
+        if !usable && !chosen[domain.Name] {
+            continue
+        }
+        candidate := DomainCandidate{
+            Domain:       domain.Name,
+            Nodes:        available,
+            Base:         ts.calculateDomainScore(domain, gpuReq),
+            HealthFactor: ts.healthScoreFactor(domain),
+            LinkFactor:   linkScoreFactor(domain),
+        }
+        candidate.Score = candidate.Base * candidate.HealthFactor * candidate.LinkFactor
+        candidates = append(candidates, candidate)
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        if candidates[i].Score != candidates[j].Score {
+            return candidates[i].Score > candidates[j].Score
+        }
+        return candidates[i].Domain < candidates[j].Domain
+    })
+    return candidates
+}
+
+// getJobName returns the workload a pod belongs to, falling back to the pod itself
+func getJobName(pod *v1.Pod) string {
+    if jobName, ok := pod.Labels["job-name"]; ok {
+        return pod.Namespace + "/" + jobName
+    }
+    for _, owner := range pod.OwnerReferences {
+        if owner.Controller != nil && *owner.Controller {
+            return pod.Namespace + "/" + owner.Name
+        }
+    }
+    return pod.Namespace + "/" + pod.Name
+}
+
+func min(a, b int) int {
+    if a < b {
+        return a
+    }
+    return b
+}
@@ -1,41 +1,71 @@
-package scheduler
-
-import (
-    "k8s.io/api/core/v1"
-    "math"
-)
-
-type Scorer struct {
-    topology *TopologyManager
-    weights  *ScoringWeights
-}
-
-type ScoringWeights struct {
-    GPUUtilization     float64
-    NetworkProximity   float64
-    DomainAffinity     float64
-    LoadBalance        float64
-}
-
-func NewScorer(topology *TopologyManager, weights *ScoringWeights) *Scorer {
-    return &Scorer{
-        topology: topology,
-        weights:  weights,
-    }
-}
-
-func (s *Scorer) ScoreNode(
-    node *v1.Node,
-    requirements *ResourceRequirements,
-    constraints *SchedulingConstraints,
-) float64 {
-    gpuScore := s.scoreGPUUtilization(node, requirements)
-    networkScore := s.scoreNetworkProximity(node, constraints)
-    affinityScore := s.scoreDomainAffinity(node, constraints)
-    loadScore := s.scoreLoadBalance(node)
-
-    return (gpuScore * s.weights.GPUUtilization) +
-           (networkScore * s.weights.NetworkProximity) +
-           (affinityScore * s.weights.DomainAffinity) +
-           (loadScore * s.weights.LoadBalance)
-}
+package scheduler
+
+import (
+    "k8s.io/api/core/v1"
+    "math"
+)
+
+type Scorer struct {
+    topology *TopologyManager
+    weights  *ScoringWeights
+}
+
+type ScoringWeights struct {
+    GPUUtilization     float64
+    NetworkProximity   float64
+    DomainAffinity     float64
+    LoadBalance        float64
+}
+
+// SchedulingConstraints describe where the rest of a job is already placed
+type SchedulingConstraints struct {
+    // PeerNodes run other pods of the same job
+    PeerNodes []string
+}
+
+// proximityScale is the distance, in link cost microseconds, at which the
+// network proximity score drops to one half
+const proximityScale = 25.0
+
+func NewScorer(topology *TopologyManager, weights *ScoringWeights) *Scorer {
+    return &Scorer{
+        topology: topology,
+        weights:  weights,
+    }
+}
+
+func (s *Scorer) ScoreNode(
+    node *v1.Node,
+    requirements *ResourceRequirements,
+    constraints *SchedulingConstraints,
+) float64 {
+    gpuScore := s.scoreGPUUtilization(node, requirements)
+    networkScore := s.scoreNetworkProximity(node, constraints)
+    affinityScore := s.scoreDomainAffinity(node, constraints)
+    loadScore := s.scoreLoadBalance(node)
+
+    return (gpuScore * s.weights.GPUUtilization) +
+           (networkScore * s.weights.NetworkProximity) +
+           (affinityScore * s.weights.DomainAffinity) +
+           (loadScore * s.weights.LoadBalance)
+}
+
+// scoreNetworkProximity favors nodes close to the job's other pods. The score
+// is 1 for a node next to all its peers and 0 if any peer is unreachable.
+func (s *Scorer) scoreNetworkProximity(node *v1.Node, constraints *SchedulingConstraints) float64 {
+    if constraints == nil || len(constraints.PeerNodes) == 0 {
+        return 1.0
+    }
+
+    var total float64
+    for _, peer := range constraints.PeerNodes {
+        distance, err := s.topology.GetTopologyDistance(node.Name, peer)
+        if err != nil {
+            return 0.0
+        }
+        total += distance
+    }
+
+    average := total / float64(len(constraints.PeerNodes))
+    return proximityScale / (proximityScale + average)
+}
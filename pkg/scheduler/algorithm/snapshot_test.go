@@ -0,0 +1,68 @@
+package algorithm
+
+import (
+    "testing"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+    ts := testTopology()
+    before := ts.cache.Snapshot()
+    if again := ts.cache.Snapshot(); again != before {
+        t.Error("snapshot of an unchanged cache was rebuilt")
+    }
+
+    if err := ts.cache.AssumePod(testPod("a", "a-0", "", 8, ""), "n1-1"); err != nil {
+        t.Fatal(err)
+    }
+    ts.cache.UpsertNode(testNode("n1-3", "leaf-1", "spine-a"))
+    ts.cache.DeleteNode("n4-2")
+    ts.cache.DeleteNode("n2-1")
+
+    after := ts.cache.Snapshot()
+    if after == before || after.Generation <= before.Generation {
+        t.Fatalf("generation after changes = %d, want more than %d", after.Generation, before.Generation)
+    }
+
+    // The earlier snapshot still shows the cache as it was
+    if got := before.GetNodeGPUs("n1-1"); got != 0 {
+        t.Errorf("old snapshot GPUs on n1-1 = %d, want 0", got)
+    }
+    if usage := before.GetJobDomainUsage("a", "leaf-1"); usage.GPUs != 0 {
+        t.Errorf("old snapshot job usage in leaf-1 = %d, want 0", usage.GPUs)
+    }
+    leaf1, err := before.GetDomain("leaf-1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(leaf1.Nodes) != 2 || leaf1.UsedGPUs != 0 {
+        t.Errorf("old snapshot leaf-1 has %d nodes and %d used GPUs, want 2 and 0", len(leaf1.Nodes), leaf1.UsedGPUs)
+    }
+    if _, err := before.GetDomainForNode("n4-2"); err != nil {
+        t.Errorf("old snapshot lost deleted node n4-2: %v", err)
+    }
+    // Removing a node from a domain must not shift the old snapshot's node list
+    leaf2, err := before.GetDomain("leaf-2")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(leaf2.Nodes) != 2 || leaf2.Nodes[0].Name != "n2-1" || leaf2.Nodes[1].Name != "n2-2" {
+        t.Errorf("old snapshot leaf-2 nodes changed after n2-1 was deleted")
+    }
+
+    if got := after.GetNodeGPUs("n1-1"); got != 8 {
+        t.Errorf("new snapshot GPUs on n1-1 = %d, want 8", got)
+    }
+    if usage := after.GetJobDomainUsage("a", "leaf-1"); usage.GPUs != 8 || !usage.Nodes["n1-1"] {
+        t.Errorf("new snapshot job usage in leaf-1 = %+v, want 8 GPUs on n1-1", usage)
+    }
+    leaf1, err = after.GetDomain("leaf-1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(leaf1.Nodes) != 3 || leaf1.UsedGPUs != 8 {
+        t.Errorf("new snapshot leaf-1 has %d nodes and %d used GPUs, want 3 and 8", len(leaf1.Nodes), leaf1.UsedGPUs)
+    }
+    if _, err := after.GetDomainForNode("n4-2"); err == nil {
+        t.Error("new snapshot still has deleted node n4-2")
+    }
+}
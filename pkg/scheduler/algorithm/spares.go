@@ -0,0 +1,673 @@
+package algorithm
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/apimachinery/pkg/util/wait"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    "k8s.io/client-go/kubernetes"
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/client-go/util/retry"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// Spare states reported in metrics
+const (
+    SpareAvailable = "available"
+    SpareClaimed   = "claimed"
+    SpareBackfill  = "backfill"
+)
+
+const (
+    sparePoolReconcilePeriod = 15 * time.Second
+    // spareClaimTTL is how long a claimed spare waits for its replacement pods
+    spareClaimTTL = placementHintTTL
+)
+
+// spareTaint keeps other schedulers and profiles off spares
+var spareTaint = v1.Taint{Key: v1alpha1.TaintSpare, Effect: v1.TaintEffectNoSchedule}
+
+// spareToleration is added to the replacements a spare is claimed for
+var spareToleration = v1.Toleration{
+    Key:      v1alpha1.TaintSpare,
+    Operator: v1.TolerationOpExists,
+    Effect:   v1.TaintEffectNoSchedule,
+}
+
+type spareNode struct {
+    leaf  string
+    spine string
+    // capacity is the node's usable GPUs; the cache counts a reserved spare as having none
+    capacity int
+    // claims counts the replacements a claimed spare still waits for, by
+    // claim key. It is nil while the spare is available.
+    claims map[string]int
+    // pods maps the pending replacements matched to a claim to their claim
+    // key. Only these pods may be placed on the spare.
+    pods      map[types.UID]string
+    claimedAt time.Time
+    expires   time.Time
+}
+
+func (s *spareNode) claimed() bool {
+    return s.claims != nil
+}
+
+// spareBackfill is the pool a failed node refills once it returns
+type spareBackfill struct {
+    leaf  string
+    spine string
+}
+
+// SparePool keeps idle nodes in reserve per leaf domain or per spine.
+// Normal scheduling never uses them; recovery claims one to replace a failed
+// node so the replacement pods stay in the domain. The failed node takes the
+// claimed spare's place once it returns. Spares are tainted so that other
+// schedulers keep off them too.
+type SparePool struct {
+    sync.Mutex
+    scheduler *TopologyScheduler
+    policy    v1alpha1.SparePoolPolicy
+    spares    map[string]*spareNode
+    // backfills is keyed by failed node
+    backfills map[string]spareBackfill
+    // client taints spares and is set on the leader only
+    client kubernetes.Interface
+    pods   corelisters.PodLister
+}
+
+func NewSparePool(ts *TopologyScheduler) *SparePool {
+    return &SparePool{
+        scheduler: ts,
+        spares:    make(map[string]*spareNode),
+        backfills: make(map[string]spareBackfill),
+    }
+}
+
+// GetSparePool returns the scheduler's hot-spare pools
+func (ts *TopologyScheduler) GetSparePool() *SparePool {
+    return ts.spares
+}
+
+// SetPolicy changes the pool sizes. Spares are added or released on the next reconcile.
+func (p *SparePool) SetPolicy(policy v1alpha1.SparePoolPolicy) {
+    p.Lock()
+    defer p.Unlock()
+    p.policy = policy
+}
+
+// SetClient makes the pool taint its spares and let claimed replacements
+// tolerate them. Only the leader should set it.
+func (p *SparePool) SetClient(client kubernetes.Interface) {
+    p.Lock()
+    defer p.Unlock()
+    p.client = client
+}
+
+// Watch reads pending pods from the informer, so a replacement gets its
+// toleration as soon as it is created
+func (p *SparePool) Watch(pods coreinformers.PodInformer) {
+    p.pods = pods.Lister()
+    pods.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc: func(obj interface{}) {
+            if pod, ok := obj.(*v1.Pod); ok && pod.Spec.NodeName == "" {
+                go p.tolerateClaim(pod)
+            }
+        },
+    })
+}
+
+// Run keeps the pools filled until stopCh is closed
+func (p *SparePool) Run(stopCh <-chan struct{}) {
+    wait.Until(func() {
+        p.reconcile()
+        p.syncTaints()
+    }, sparePoolReconcilePeriod, stopCh)
+}
+
+func (p *SparePool) reconcile() {
+    snapshot := p.scheduler.cache.Snapshot()
+
+    p.Lock()
+    defer p.Unlock()
+
+    now := time.Now()
+    for name, spare := range p.spares {
+        switch {
+        case spare.claimed():
+            // A claimed spare becomes a regular node once its replacements are bound
+            if len(spare.claims) == 0 && len(spare.pods) == 0 || now.After(spare.expires) {
+                delete(p.spares, name)
+                klog.V(2).Infof("Spare node %s promoted to a regular node", name)
+            }
+        case p.spareCapacity(snapshot, name) == 0:
+            delete(p.spares, name)
+            klog.V(2).Infof("Node %s is no longer usable as a spare", name)
+        }
+    }
+
+    for name := range p.backfills {
+        domain, err := snapshot.GetDomainForNode(name)
+        if err != nil {
+            // The failed node is gone for good; the pool refills from idle nodes
+            delete(p.backfills, name)
+            continue
+        }
+        if capacity := p.spareCapacity(snapshot, name); capacity > 0 {
+            p.spares[name] = &spareNode{leaf: domain.Name, spine: domain.SpineSwitch, capacity: capacity}
+            delete(p.backfills, name)
+            klog.Infof("Node %s returned and backfills the spare pool of domain %s", name, domain.Name)
+        }
+    }
+
+    leafCounts, spineCounts := p.countLocked()
+    domains := sortedDomains(snapshot)
+    if want := int(p.policy.NodesPerLeaf); want > 0 {
+        for _, domain := range domains {
+            for leafCounts[domain.Name] < want {
+                if !p.designateLocked(snapshot, domain, leafCounts, spineCounts) {
+                    break
+                }
+            }
+        }
+    }
+    if want := int(p.policy.NodesPerSpine); want > 0 {
+        for _, domain := range domains {
+            if domain.SpineSwitch == "" {
+                continue
+            }
+            for spineCounts[domain.SpineSwitch] < want {
+                if !p.designateLocked(snapshot, domain, leafCounts, spineCounts) {
+                    break
+                }
+            }
+        }
+    }
+    p.releaseExtrasLocked(leafCounts, spineCounts)
+
+    p.syncCacheLocked()
+    p.scheduler.metrics.SetSparePools(p.occupancyLocked())
+}
+
+// spareCapacity returns the usable GPUs of a node that can serve as a spare:
+// healthy, schedulable, running no GPU pods and in an active domain. It is
+// zero otherwise.
+func (p *SparePool) spareCapacity(snapshot *TopologySnapshot, nodeName string) int {
+    domain, err := snapshot.GetDomainForNode(nodeName)
+    if err != nil || snapshot.GetNodeGPUs(nodeName) > 0 || !snapshot.IsNodeHealthy(nodeName) {
+        return 0
+    }
+    if !domain.Lifecycle.AcceptsPlacements() {
+        return 0
+    }
+    for _, node := range domain.Nodes {
+        if node.Name == nodeName && node.Spec.Unschedulable {
+            return 0
+        }
+    }
+
+    info, err := p.scheduler.cache.GetNodeGPUInfo(nodeName)
+    if err != nil {
+        return 0
+    }
+    return info.UsableGPUs()
+}
+
+// designateLocked reserves one idle node of a domain as a spare, reporting
+// whether one was found
+func (p *SparePool) designateLocked(snapshot *TopologySnapshot, domain *Domain, leafCounts, spineCounts map[string]int) bool {
+    names := make([]string, 0, len(domain.Nodes))
+    for _, node := range domain.Nodes {
+        names = append(names, node.Name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        if _, exists := p.spares[name]; exists {
+            continue
+        }
+        if _, exists := p.backfills[name]; exists {
+            continue
+        }
+        capacity := p.spareCapacity(snapshot, name)
+        if capacity == 0 {
+            continue
+        }
+
+        p.spares[name] = &spareNode{leaf: domain.Name, spine: domain.SpineSwitch, capacity: capacity}
+        leafCounts[domain.Name]++
+        spineCounts[domain.SpineSwitch]++
+        klog.Infof("Node %s reserved as a spare for domain %s", name, domain.Name)
+        return true
+    }
+    return false
+}
+
+// releaseExtrasLocked returns available spares that no pool needs to regular scheduling
+func (p *SparePool) releaseExtrasLocked(leafCounts, spineCounts map[string]int) {
+    names := make([]string, 0, len(p.spares))
+    for name, spare := range p.spares {
+        if !spare.claimed() {
+            names = append(names, name)
+        }
+    }
+    sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+    for _, name := range names {
+        spare := p.spares[name]
+        leafNeeded := leafCounts[spare.leaf] <= int(p.policy.NodesPerLeaf)
+        spineNeeded := spare.spine != "" && spineCounts[spare.spine] <= int(p.policy.NodesPerSpine)
+        if leafNeeded || spineNeeded {
+            continue
+        }
+        delete(p.spares, name)
+        leafCounts[spare.leaf]--
+        spineCounts[spare.spine]--
+        klog.Infof("Spare node %s released to regular scheduling", name)
+    }
+}
+
+// countLocked counts available spares and pending backfills per leaf and per spine
+func (p *SparePool) countLocked() (map[string]int, map[string]int) {
+    leafCounts := make(map[string]int)
+    spineCounts := make(map[string]int)
+    for _, spare := range p.spares {
+        if !spare.claimed() {
+            leafCounts[spare.leaf]++
+            spineCounts[spare.spine]++
+        }
+    }
+    for _, backfill := range p.backfills {
+        leafCounts[backfill.leaf]++
+        spineCounts[backfill.spine]++
+    }
+    return leafCounts, spineCounts
+}
+
+// syncCacheLocked hands the available spares and the claims on every spare
+// to the cache, where the scheduling snapshot picks them up
+func (p *SparePool) syncCacheLocked() {
+    claims := make(map[string]map[string]bool, len(p.spares))
+    for name, spare := range p.spares {
+        claims[name] = make(map[string]bool, len(spare.pods))
+        for uid := range spare.pods {
+            claims[name][string(uid)] = true
+        }
+    }
+    p.scheduler.cache.SetReservedNodes(p.availableLocked())
+    p.scheduler.cache.SetSpareClaims(claims)
+}
+
+func (p *SparePool) availableLocked() map[string]bool {
+    available := make(map[string]bool, len(p.spares))
+    for name, spare := range p.spares {
+        if !spare.claimed() {
+            available[name] = true
+        }
+    }
+    return available
+}
+
+// occupancyLocked counts spares by pool and state. A spare belongs to its
+// leaf pool and to its spine pool when the policy keeps that kind of pool.
+func (p *SparePool) occupancyLocked() map[string]map[string]int {
+    pools := make(map[string]map[string]int)
+    add := func(leaf, spine, state string) {
+        var keys []string
+        if p.policy.NodesPerLeaf > 0 {
+            keys = append(keys, "leaf/"+leaf)
+        }
+        if p.policy.NodesPerSpine > 0 && spine != "" {
+            keys = append(keys, "spine/"+spine)
+        }
+        for _, key := range keys {
+            if pools[key] == nil {
+                pools[key] = map[string]int{SpareAvailable: 0, SpareClaimed: 0, SpareBackfill: 0}
+            }
+            pools[key][state]++
+        }
+    }
+
+    for _, spare := range p.spares {
+        state := SpareAvailable
+        if spare.claimed() {
+            state = SpareClaimed
+        }
+        add(spare.leaf, spare.spine, state)
+    }
+    for _, backfill := range p.backfills {
+        add(backfill.leaf, backfill.spine, SpareBackfill)
+    }
+    return pools
+}
+
+// candidates returns the available spares, outside the failed nodes, that a
+// recovery plan may place lost pods on
+func (p *SparePool) candidates(failed map[string]bool) []*spareCandidate {
+    p.Lock()
+    defer p.Unlock()
+
+    var candidates []*spareCandidate
+    for name, spare := range p.spares {
+        if spare.claimed() || failed[name] {
+            continue
+        }
+        candidates = append(candidates, &spareCandidate{
+            node:  name,
+            leaf:  spare.leaf,
+            spine: spare.spine,
+            free:  spare.capacity,
+        })
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        return candidates[i].node < candidates[j].node
+    })
+    return candidates
+}
+
+// claim hands a planned spare to a migration's replacement, releasing its GPUs
+// to the domain. The first claim of a spare leaves its pool waiting for the
+// failed node to return. It fails if the spare is no longer available.
+func (p *SparePool) claim(m Migration) bool {
+    p.Lock()
+    defer p.Unlock()
+
+    spare, exists := p.spares[m.SpareNode]
+    if !exists {
+        return false
+    }
+    if !spare.claimed() {
+        spare.claims = make(map[string]int)
+        spare.pods = make(map[types.UID]string)
+        spare.claimedAt = time.Now()
+        p.backfills[m.FromNode] = spareBackfill{leaf: spare.leaf, spine: spare.spine}
+        klog.Infof("Spare node %s claimed to replace node %s", m.SpareNode, m.FromNode)
+    }
+    spare.claims[migrationClaimKey(m)]++
+    spare.expires = time.Now().Add(spareClaimTTL)
+
+    p.syncCacheLocked()
+    p.scheduler.metrics.SetSparePools(p.occupancyLocked())
+    return true
+}
+
+// ObserveBinding settles the claim a bound replacement was matched to
+func (p *SparePool) ObserveBinding(pod *v1.Pod) {
+    p.Lock()
+    defer p.Unlock()
+
+    for _, spare := range p.spares {
+        if _, exists := spare.pods[pod.UID]; exists {
+            delete(spare.pods, pod.UID)
+            p.syncCacheLocked()
+            return
+        }
+    }
+}
+
+// matchLocked matches a pending pod to an open claim on a spare, reporting
+// whether the pod is matched and whether it was matched just now. A claim
+// admits as many pods as it waits for replacements, and only pods created
+// after the spare was claimed, so that other pending pods of the same
+// controller keep off the spare.
+func (p *SparePool) matchLocked(pod *v1.Pod) (bool, bool) {
+    names := make([]string, 0, len(p.spares))
+    for name, spare := range p.spares {
+        if _, exists := spare.pods[pod.UID]; exists {
+            return true, false
+        }
+        if spare.claimed() {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+
+    key := spareClaimKey(pod)
+    for _, name := range names {
+        spare := p.spares[name]
+        // Creation timestamps are truncated to the second
+        if spare.claims[key] == 0 || pod.CreationTimestamp.Time.Before(spare.claimedAt.Truncate(time.Second)) {
+            continue
+        }
+        if spare.claims[key]--; spare.claims[key] == 0 {
+            delete(spare.claims, key)
+        }
+        spare.pods[pod.UID] = key
+        klog.V(2).Infof("Pod %s/%s matched to the claim on spare node %s", pod.Namespace, pod.Name, name)
+        return true, true
+    }
+    return false, false
+}
+
+// syncTaints taints the spares, untaints nodes that are no longer spares and
+// lets pending replacements tolerate the spares claimed for them
+func (p *SparePool) syncTaints() {
+    p.Lock()
+    client := p.client
+    spares := make(map[string]bool, len(p.spares))
+    claimed := false
+    for name, spare := range p.spares {
+        spares[name] = true
+        claimed = claimed || len(spare.claims) > 0 || len(spare.pods) > 0
+    }
+    p.Unlock()
+    if client == nil {
+        return
+    }
+
+    ctx := context.Background()
+    for _, domain := range p.scheduler.cache.Snapshot().GetAllDomains() {
+        for _, node := range domain.Nodes {
+            if hasTaint(node, spareTaint) == spares[node.Name] {
+                continue
+            }
+            if err := setNodeTaint(ctx, client, node.Name, spareTaint, spares[node.Name]); err != nil {
+                klog.Errorf("Failed to update spare taint on node %s: %v", node.Name, err)
+            }
+        }
+    }
+
+    if p.pods == nil || !claimed {
+        return
+    }
+    pods, err := p.pods.List(labels.Everything())
+    if err != nil {
+        klog.Errorf("Failed to list pods for spare claims: %v", err)
+        return
+    }
+    // Match the oldest pods first, as they were created first after the claim
+    sort.Slice(pods, func(i, j int) bool {
+        if !pods[i].CreationTimestamp.Equal(&pods[j].CreationTimestamp) {
+            return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+        }
+        return pods[i].UID < pods[j].UID
+    })
+
+    var matched []*v1.Pod
+    p.Lock()
+    changed := false
+    for _, pod := range pods {
+        if pod.Spec.NodeName != "" || isTerminalPod(pod) {
+            continue
+        }
+        ok, added := p.matchLocked(pod)
+        if ok {
+            matched = append(matched, pod)
+        }
+        changed = changed || added
+    }
+    if changed {
+        p.syncCacheLocked()
+    }
+    p.Unlock()
+
+    for _, pod := range matched {
+        tolerateSpare(ctx, client, pod)
+    }
+}
+
+// tolerateClaim lets a new pod tolerate the spares if it matches a claim
+func (p *SparePool) tolerateClaim(pod *v1.Pod) {
+    p.Lock()
+    client := p.client
+    claimed, changed := p.matchLocked(pod)
+    if changed {
+        p.syncCacheLocked()
+    }
+    p.Unlock()
+
+    if client != nil && claimed {
+        tolerateSpare(context.Background(), client, pod)
+    }
+}
+
+// tolerateSpare adds the spare toleration to a pending pod. The update
+// requeues the pod, which can then pass the taint filter.
+func tolerateSpare(ctx context.Context, client kubernetes.Interface, pod *v1.Pod) {
+    if toleratesSpare(pod) {
+        return
+    }
+    err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+        current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+        if err != nil {
+            return err
+        }
+        if current.UID != pod.UID || current.Spec.NodeName != "" || toleratesSpare(current) {
+            return nil
+        }
+        current = current.DeepCopy()
+        current.Spec.Tolerations = append(current.Spec.Tolerations, spareToleration)
+        _, err = client.CoreV1().Pods(pod.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+        return err
+    })
+    if err != nil {
+        klog.Errorf("Failed to let pod %s/%s tolerate its claimed spare: %v", pod.Namespace, pod.Name, err)
+        return
+    }
+    klog.V(2).Infof("Pod %s/%s tolerates the spare claimed for it", pod.Namespace, pod.Name)
+}
+
+func toleratesSpare(pod *v1.Pod) bool {
+    for i := range pod.Spec.Tolerations {
+        if pod.Spec.Tolerations[i].ToleratesTaint(&spareTaint) {
+            return true
+        }
+    }
+    return false
+}
+
+func hasTaint(node *v1.Node, taint v1.Taint) bool {
+    for _, existing := range node.Spec.Taints {
+        if existing.Key == taint.Key && existing.Effect == taint.Effect {
+            return true
+        }
+    }
+    return false
+}
+
+// checkpoint returns the claimed spares and pending backfills, which a new
+// leader could not rebuild from the cluster
+func (p *SparePool) checkpoint() *SparePoolCheckpoint {
+    p.Lock()
+    defer p.Unlock()
+
+    cp := &SparePoolCheckpoint{}
+    for name, spare := range p.spares {
+        if !spare.claimed() {
+            continue
+        }
+        claims := make(map[string]int, len(spare.claims))
+        for key, count := range spare.claims {
+            claims[key] = count
+        }
+        pods := make(map[types.UID]string, len(spare.pods))
+        for uid, key := range spare.pods {
+            pods[uid] = key
+        }
+        cp.Claimed = append(cp.Claimed, ClaimedSpareCheckpoint{
+            Node:      name,
+            Leaf:      spare.leaf,
+            Spine:     spare.spine,
+            Capacity:  spare.capacity,
+            Claims:    claims,
+            Pods:      pods,
+            ClaimedAt: spare.claimedAt,
+            Expires:   spare.expires,
+        })
+    }
+    for name, backfill := range p.backfills {
+        cp.Backfills = append(cp.Backfills, SpareBackfillCheckpoint{
+            FailedNode: name,
+            Leaf:       backfill.leaf,
+            Spine:      backfill.spine,
+        })
+    }
+    sort.Slice(cp.Claimed, func(i, j int) bool { return cp.Claimed[i].Node < cp.Claimed[j].Node })
+    sort.Slice(cp.Backfills, func(i, j int) bool { return cp.Backfills[i].FailedNode < cp.Backfills[j].FailedNode })
+    return cp
+}
+
+// restore brings back the previous leader's unexpired claims, replacing an
+// available spare designated on the same node, and its pending backfills
+func (p *SparePool) restore(cp *SparePoolCheckpoint) {
+    p.Lock()
+    defer p.Unlock()
+
+    now := time.Now()
+    for _, c := range cp.Claimed {
+        if len(c.Claims) == 0 && len(c.Pods) == 0 || now.After(c.Expires) {
+            continue
+        }
+        claims := make(map[string]int, len(c.Claims))
+        for key, count := range c.Claims {
+            claims[key] = count
+        }
+        pods := make(map[types.UID]string, len(c.Pods))
+        for uid, key := range c.Pods {
+            pods[uid] = key
+        }
+        p.spares[c.Node] = &spareNode{
+            leaf:      c.Leaf,
+            spine:     c.Spine,
+            capacity:  c.Capacity,
+            claims:    claims,
+            pods:      pods,
+            claimedAt: c.ClaimedAt,
+            expires:   c.Expires,
+        }
+    }
+    for _, b := range cp.Backfills {
+        if _, exists := p.backfills[b.FailedNode]; !exists {
+            p.backfills[b.FailedNode] = spareBackfill{leaf: b.Leaf, spine: b.Spine}
+        }
+    }
+
+    p.syncCacheLocked()
+    p.scheduler.metrics.SetSparePools(p.occupancyLocked())
+}
+
+// spareClaimKey matches a replacement to its claim by controller, or by name
+// for standalone pods, which recovery recreates under the same name
+func spareClaimKey(pod *v1.Pod) string {
+    if owner := metav1.GetControllerOf(pod); owner != nil {
+        return string(owner.UID)
+    }
+    return pod.Namespace + "/" + pod.Name
+}
+
+func migrationClaimKey(m Migration) string {
+    if m.OwnerUID != "" {
+        return string(m.OwnerUID)
+    }
+    return m.Namespace + "/" + m.Pod
+}
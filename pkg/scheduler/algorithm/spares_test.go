@@ -0,0 +1,151 @@
+package algorithm
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/client-go/informers"
+    "k8s.io/client-go/kubernetes/fake"
+)
+
+// replacementPod returns a pending pod of job a's controller created at a given time
+func replacementPod(name string, created time.Time) *v1.Pod {
+    controller := true
+    pod := testPod("a", name, "", 8, "")
+    pod.CreationTimestamp = metav1.NewTime(created)
+    pod.OwnerReferences = []metav1.OwnerReference{{Kind: "Job", Name: "a", UID: "job-a", Controller: &controller}}
+    return pod
+}
+
+func TestSparePoolSyncTaints(t *testing.T) {
+    ts := testTopology()
+    spares := ts.GetSparePool()
+
+    // n1-1 was a spare and has been released; n1-2 is available and n2-1 is
+    // claimed for one replacement of job a. Of job a's pending pods, a-0 was
+    // pending before the claim and a-1 is the first created after it.
+    claimedAt := time.Now()
+    released := testNode("n1-1", "leaf-1", "spine-a")
+    released.Spec.Taints = []v1.Taint{spareTaint}
+    ts.cache.UpsertNode(released)
+    pods := []*v1.Pod{
+        replacementPod("a-0", claimedAt.Add(-time.Hour)),
+        replacementPod("a-1", claimedAt.Add(time.Second)),
+        replacementPod("a-2", claimedAt.Add(2*time.Second)),
+    }
+    client := fake.NewSimpleClientset(released,
+        testNode("n1-2", "leaf-1", "spine-a"), testNode("n2-1", "leaf-2", "spine-a"),
+        pods[0], pods[1], pods[2])
+    factory := informers.NewSharedInformerFactory(client, 0)
+    for _, pod := range pods {
+        if err := factory.Core().V1().Pods().Informer().GetIndexer().Add(pod); err != nil {
+            t.Fatal(err)
+        }
+    }
+    spares.spares["n1-2"] = &spareNode{leaf: "leaf-1", spine: "spine-a", capacity: 8}
+    spares.spares["n2-1"] = &spareNode{leaf: "leaf-2", spine: "spine-a", capacity: 8,
+        claims: map[string]int{"job-a": 1}, pods: make(map[types.UID]string),
+        claimedAt: claimedAt, expires: claimedAt.Add(time.Minute)}
+    spares.SetClient(client)
+    spares.pods = factory.Core().V1().Pods().Lister()
+
+    spares.syncTaints()
+
+    for name, want := range map[string]bool{"n1-1": false, "n1-2": true, "n2-1": true} {
+        node, err := client.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := hasTaint(node, spareTaint); got != want {
+            t.Errorf("node %s tainted = %v, want %v", name, got, want)
+        }
+    }
+
+    snapshot := ts.cache.Snapshot()
+    for name, want := range map[string]bool{"a-0": false, "a-1": true, "a-2": false} {
+        pod, err := client.CoreV1().Pods("default").Get(context.Background(), name, metav1.GetOptions{})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := toleratesSpare(pod); got != want {
+            t.Errorf("pod %s tolerates spares = %v, want %v", name, got, want)
+        }
+        if got := snapshot.AdmitsOnSpare(pod, "n2-1"); got != want {
+            t.Errorf("spare n2-1 admits pod %s = %v, want %v", name, got, want)
+        }
+    }
+
+    // The matched replacement binds and settles the claim
+    spares.ObserveBinding(pods[1])
+    spares.reconcile()
+    if _, exists := spares.spares["n2-1"]; exists {
+        t.Errorf("spare n2-1 is still held after its replacement bound")
+    }
+}
+
+func TestTolerateSpare(t *testing.T) {
+    replacement := testPod("a", "a-0", "", 8, "")
+    bound := testPod("b", "b-0", "n1-1", 8, "")
+    client := fake.NewSimpleClientset(replacement, bound)
+
+    tolerateSpare(context.Background(), client, replacement)
+    tolerateSpare(context.Background(), client, replacement)
+    tolerateSpare(context.Background(), client, bound)
+
+    pod, err := client.CoreV1().Pods("default").Get(context.Background(), "a-0", metav1.GetOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(pod.Spec.Tolerations) != 1 || !toleratesSpare(pod) {
+        t.Errorf("pending replacement has tolerations %v, want the spare toleration once", pod.Spec.Tolerations)
+    }
+    pod, err = client.CoreV1().Pods("default").Get(context.Background(), "b-0", metav1.GetOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if toleratesSpare(pod) {
+        t.Errorf("bound pod was given the spare toleration")
+    }
+}
+
+func TestSparePoolCheckpoint(t *testing.T) {
+    previous := testTopology().GetSparePool()
+    previous.spares["n1-2"] = &spareNode{leaf: "leaf-1", spine: "spine-a", capacity: 8}
+    previous.spares["n2-1"] = &spareNode{leaf: "leaf-2", spine: "spine-a", capacity: 8,
+        claims: map[string]int{"job-a": 1}, pods: map[types.UID]string{"uid-a-0": "job-a"},
+        claimedAt: time.Now(), expires: time.Now().Add(time.Minute)}
+    previous.spares["n2-2"] = &spareNode{leaf: "leaf-2", spine: "spine-a", capacity: 8,
+        claims: map[string]int{"default/b-0": 1}, expires: time.Now().Add(-time.Second)}
+    previous.backfills["n2-9"] = spareBackfill{leaf: "leaf-2", spine: "spine-a"}
+    cp := previous.checkpoint()
+
+    // The new leader designated n2-1 as an available spare before restoring
+    ts := testTopology()
+    spares := ts.GetSparePool()
+    spares.spares["n2-1"] = &spareNode{leaf: "leaf-2", spine: "spine-a", capacity: 8}
+    spares.restore(cp)
+
+    if spare := spares.spares["n2-1"]; spare == nil || spare.claims["job-a"] != 1 || spare.pods["uid-a-0"] != "job-a" {
+        t.Errorf("spare n2-1 is %+v, want it claimed for job-a with a-0 matched", spare)
+    }
+    if _, exists := spares.spares["n2-2"]; exists {
+        t.Errorf("expired claim on n2-2 was restored")
+    }
+    if _, exists := spares.spares["n1-2"]; exists {
+        t.Errorf("available spare n1-2 was restored, want it designated again by reconcile")
+    }
+    if _, exists := spares.backfills["n2-9"]; !exists {
+        t.Errorf("backfill for n2-9 was not restored")
+    }
+    snapshot := ts.cache.Snapshot()
+    if !snapshot.IsSpareClaimedFor(testPod("a", "a-0", "", 8, ""), "n2-1") {
+        t.Errorf("restored claim is not in the scheduling snapshot")
+    }
+    if snapshot.IsSpareClaimedFor(testPod("a", "a-1", "", 8, ""), "n2-1") {
+        t.Errorf("spare n2-1 is claimed for a-1, which was never matched")
+    }
+}
@@ -0,0 +1,268 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "k8s.io/apimachinery/pkg/api/equality"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
+    "k8s.io/apimachinery/pkg/api/meta"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/labels"
+    utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/client-go/util/workqueue"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    clientset "github.com/nod-ai/topology-aware-scheduler/pkg/generated/clientset/versioned"
+    informers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/informers/externalversions/topology/v1alpha1"
+    listers "github.com/nod-ai/topology-aware-scheduler/pkg/generated/listers/topology/v1alpha1"
+)
+
+// DefaultSchedulerName is the scheduler a TopologyScheduler without a
+// schedulerName reports on
+const DefaultSchedulerName = "topology-aware-scheduler"
+
+const (
+    // statusResyncPeriod is how often every TopologyScheduler is refreshed,
+    // since usage and health change without any event on the object
+    statusResyncPeriod = 15 * time.Second
+    statusMaxRetries   = 5
+)
+
+// StatusController keeps the status of the TopologySchedulers naming this
+// scheduler up to date with its domains, config and recovery state. Only
+// the leader should run it.
+type StatusController struct {
+    scheduler     *TopologyScheduler
+    recovery      *RecoveryManager
+    client        clientset.Interface
+    schedulerName string
+    lister        listers.TopologySchedulerLister
+    synced        cache.InformerSynced
+    queue         workqueue.RateLimitingInterface
+}
+
+func NewStatusController(ts *TopologyScheduler, recovery *RecoveryManager, client clientset.Interface, schedulerName string) *StatusController {
+    return &StatusController{
+        scheduler:     ts,
+        recovery:      recovery,
+        client:        client,
+        schedulerName: schedulerName,
+        queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "TopologySchedulers"),
+    }
+}
+
+func (c *StatusController) Watch(informer informers.TopologySchedulerInformer) {
+    c.lister = informer.Lister()
+    c.synced = informer.Informer().HasSynced
+    informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc:    c.enqueue,
+        UpdateFunc: func(old, new interface{}) { c.enqueue(new) },
+    })
+}
+
+// Run reports status until stopCh is closed
+func (c *StatusController) Run(stopCh <-chan struct{}) {
+    if c.lister == nil {
+        klog.Warningf("TopologyScheduler status disabled, the controller is not watching TopologySchedulers")
+        return
+    }
+    defer c.queue.ShutDown()
+
+    if ok := cache.WaitForCacheSync(stopCh, c.synced); !ok {
+        klog.Errorf("Failed to sync TopologySchedulers for status reporting")
+        return
+    }
+    go wait.Until(c.runWorker, time.Second, stopCh)
+    go wait.Until(c.enqueueAll, statusResyncPeriod, stopCh)
+    <-stopCh
+}
+
+func (c *StatusController) enqueue(obj interface{}) {
+    key, err := cache.MetaNamespaceKeyFunc(obj)
+    if err != nil {
+        utilruntime.HandleError(err)
+        return
+    }
+    c.queue.Add(key)
+}
+
+func (c *StatusController) enqueueAll() {
+    objects, err := c.lister.List(labels.Everything())
+    if err != nil {
+        klog.Errorf("Failed to list TopologySchedulers: %v", err)
+        return
+    }
+    for _, obj := range objects {
+        c.enqueue(obj)
+    }
+}
+
+func (c *StatusController) runWorker() {
+    for c.processNextItem() {
+    }
+}
+
+func (c *StatusController) processNextItem() bool {
+    item, shutdown := c.queue.Get()
+    if shutdown {
+        return false
+    }
+    defer c.queue.Done(item)
+
+    key := item.(string)
+    err := c.sync(key)
+    if err == nil {
+        c.queue.Forget(item)
+        return true
+    }
+    if c.queue.NumRequeues(item) < statusMaxRetries {
+        klog.V(2).Infof("Error reporting status of TopologyScheduler %s, retrying: %v", key, err)
+        c.queue.AddRateLimited(item)
+        return true
+    }
+    c.queue.Forget(item)
+    utilruntime.HandleError(fmt.Errorf("dropping TopologyScheduler %s out of the queue: %v", key, err))
+    return true
+}
+
+func (c *StatusController) sync(key string) error {
+    namespace, name, err := cache.SplitMetaNamespaceKey(key)
+    if err != nil {
+        return err
+    }
+    obj, err := c.lister.TopologySchedulers(namespace).Get(name)
+    if apierrors.IsNotFound(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+
+    schedulerName := obj.Spec.SchedulerName
+    if schedulerName == "" {
+        schedulerName = DefaultSchedulerName
+    }
+    if schedulerName != c.schedulerName {
+        return nil
+    }
+
+    status := c.buildStatus(obj)
+    if equality.Semantic.DeepEqual(obj.Status, *status) {
+        return nil
+    }
+    updated := obj.DeepCopy()
+    updated.Status = *status
+    _, err = c.client.TopologyV1alpha1().TopologySchedulers(namespace).UpdateStatus(
+        context.TODO(), updated, metav1.UpdateOptions{})
+    if err != nil {
+        return fmt.Errorf("failed to update status of TopologyScheduler %s: %v", key, err)
+    }
+    return nil
+}
+
+// buildStatus summarizes the domains the object selects. Conditions start
+// from the current ones so their transition times are kept.
+func (c *StatusController) buildStatus(obj *v1alpha1.TopologyScheduler) *v1alpha1.TopologySchedulerStatus {
+    config := c.scheduler.GetConfig()
+    status := &v1alpha1.TopologySchedulerStatus{
+        ObservedGeneration: obj.Generation,
+        ConfigGeneration:   config.Generation,
+        ConfigSource:       config.Source,
+    }
+    for i := range obj.Status.Conditions {
+        status.Conditions = append(status.Conditions, *obj.Status.Conditions[i].DeepCopy())
+    }
+
+    selected := make(map[string]bool, len(obj.Spec.Domains))
+    for _, name := range obj.Spec.Domains {
+        selected[name] = true
+    }
+    matched := make(map[string]bool)
+    var degraded []string
+    for _, domain := range sortedDomains(c.scheduler.cache.Snapshot()) {
+        if len(selected) > 0 && !selected[domain.Name] && !selected[domain.SpineSwitch] {
+            continue
+        }
+        matched[domain.Name] = true
+        matched[domain.SpineSwitch] = true
+
+        summary := v1alpha1.DomainSummary{
+            Name:         domain.Name,
+            Spine:        domain.SpineSwitch,
+            Nodes:        int32(len(domain.Nodes)),
+            HealthyNodes: int32(domain.HealthyNodes),
+            TotalGPUs:    int32(domain.TotalGPUs),
+            UsedGPUs:     int32(domain.UsedGPUs),
+            Health:       domainHealthState(domain),
+            LinkState:    string(domain.LinkState),
+            Lifecycle:    string(domain.Lifecycle),
+        }
+        status.Domains = append(status.Domains, summary)
+        status.TotalGPUs += summary.TotalGPUs
+        status.UsedGPUs += summary.UsedGPUs
+        if summary.Health == DomainStateHealthy {
+            status.HealthyDomains++
+        } else {
+            degraded = append(degraded, fmt.Sprintf("%s is %s", domain.Name, summary.Health))
+        }
+        if !domain.Lifecycle.AcceptsPlacements() {
+            degraded = append(degraded, fmt.Sprintf("%s is %s", domain.Name, domain.Lifecycle))
+        }
+    }
+
+    ready := metav1.Condition{
+        Type:               v1alpha1.ConditionReady,
+        Status:             metav1.ConditionTrue,
+        Reason:             "Reporting",
+        Message:            fmt.Sprintf("%d domain(s) reported", len(status.Domains)),
+        ObservedGeneration: obj.Generation,
+    }
+    var unknown []string
+    for _, name := range obj.Spec.Domains {
+        if !matched[name] {
+            unknown = append(unknown, name)
+        }
+    }
+    if len(unknown) > 0 {
+        ready.Message += fmt.Sprintf(", unknown domains: %s", strings.Join(unknown, ", "))
+    }
+    meta.SetStatusCondition(&status.Conditions, ready)
+
+    condition := metav1.Condition{
+        Type:               v1alpha1.ConditionDegraded,
+        Status:             metav1.ConditionFalse,
+        Reason:             "AllHealthy",
+        Message:            "every reported domain is Healthy and Active",
+        ObservedGeneration: obj.Generation,
+    }
+    if len(degraded) > 0 {
+        condition.Status = metav1.ConditionTrue
+        condition.Reason = "DomainsDegraded"
+        condition.Message = strings.Join(degraded, ", ")
+    }
+    meta.SetStatusCondition(&status.Conditions, condition)
+
+    if c.recovery != nil {
+        breaker := c.recovery.Breaker().Status()
+        condition := metav1.Condition{
+            Type:               v1alpha1.ConditionRecoveryPaused,
+            Status:             metav1.ConditionFalse,
+            Reason:             "Running",
+            Message:            "automatic recovery is running",
+            ObservedGeneration: obj.Generation,
+        }
+        if breaker.Open {
+            condition.Status = metav1.ConditionTrue
+            condition.Reason = "CircuitOpen"
+            condition.Message = breaker.Reason
+        }
+        meta.SetStatusCondition(&status.Conditions, condition)
+    }
+    return status
+}
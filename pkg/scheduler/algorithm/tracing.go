@@ -0,0 +1,104 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "net/url"
+    "os"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+    "go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+)
+
+// TracerName is the instrumentation name of the scheduler's spans
+const TracerName = "github.com/nod-ai/topology-aware-scheduler"
+
+// DefaultTracingSampleRatio samples one cycle in a hundred
+const DefaultTracingSampleRatio = 0.01
+
+// tracer delegates to the provider SetupTracing installs; until then, or
+// without tracing configured, its spans are no-ops
+var tracer = otel.Tracer(TracerName)
+
+// SetupTracing exports spans to endpoint and returns a function that flushes
+// and stops the exporter. The endpoint is an OTLP/gRPC collector given as
+// http://host:port or https://host:port, or file:///path to write spans as
+// JSON to a file.
+func SetupTracing(ctx context.Context, endpoint, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+    u, err := url.Parse(endpoint)
+    if err != nil {
+        return nil, fmt.Errorf("invalid tracing endpoint %q: %v", endpoint, err)
+    }
+
+    var exporter sdktrace.SpanExporter
+    var file *os.File
+    switch u.Scheme {
+    case "http", "https":
+        options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(u.Host)}
+        if u.Scheme == "http" {
+            options = append(options, otlptracegrpc.WithInsecure())
+        }
+        exporter, err = otlptracegrpc.New(ctx, options...)
+    case "file":
+        file, err = os.OpenFile(u.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+            return nil, fmt.Errorf("failed to open trace file %s: %v", u.Path, err)
+        }
+        exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+    default:
+        return nil, fmt.Errorf("unsupported tracing endpoint %q, expected http://, https:// or file://", endpoint)
+    }
+    if err != nil {
+        if file != nil {
+            file.Close()
+        }
+        return nil, fmt.Errorf("failed to create trace exporter: %v", err)
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+        sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+            semconv.ServiceName(serviceName))),
+    )
+    otel.SetTracerProvider(provider)
+
+    return func(ctx context.Context) error {
+        err := provider.Shutdown(ctx)
+        if file != nil {
+            file.Close()
+        }
+        return err
+    }, nil
+}
+
+// podAttributes identify a pod and its job on a span
+func podAttributes(pod *v1.Pod) []attribute.KeyValue {
+    return []attribute.KeyValue{
+        attribute.String("pod", pod.Namespace+"/"+pod.Name),
+        attribute.String("pod.uid", string(pod.UID)),
+        attribute.String("job", getJobName(pod)),
+    }
+}
+
+// recordSpanError marks the span failed with err, if any
+func recordSpanError(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+}
+
+// endSpan records err, if any, on the span and ends it
+func endSpan(span trace.Span, err error) {
+    recordSpanError(span, err)
+    span.End()
+}
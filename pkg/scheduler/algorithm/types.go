@@ -1,29 +1,38 @@
-package algorithm
-
-import (
-    v1 "k8s.io/api/core/v1"
-)
-
-// TopologyScore represents the scoring weights for different factors
-type TopologyScore struct {
-    ResourceAvailability float64
-    TopologyAlignment    float64
-    DomainUtilization   float64
-    HistoricalPerf      float64
-}
-
-// Domain represents a leaf switch domain containing nodes
-type Domain struct {
-    ID          string
-    Nodes       []*v1.Node
-    TotalGPUs   int
-    UsedGPUs    int
-    LeafSwitch  string
-    SpineSwitch string
-}
-
-// TopologyState represents the current state of the cluster topology
-type TopologyState struct {
-    Domains          map[string]*Domain
-    SpineConnections map[string][]string
+package algorithm
+
+import (
+    v1 "k8s.io/api/core/v1"
+)
+
+// TopologyScore represents the scoring weights for different factors
+type TopologyScore struct {
+    ResourceAvailability float64
+    TopologyAlignment    float64
+    DomainUtilization   float64
+    HistoricalPerf      float64
+}
+
+// Domain represents a leaf switch domain containing nodes
+type Domain struct {
+    ID          string
+    Name        string
+    Nodes       []*v1.Node
+    TotalGPUs   int
+    UsedGPUs    int
+    LeafSwitch  string
+    SpineSwitch string
+    // HealthyNodes, Health and RecentFlaps are maintained from node conditions and taints
+    HealthyNodes int
+    Health       float64
+    RecentFlaps  int
+    // LinkState is the worst state of the domain's links
+    LinkState LinkState
+    // Lifecycle is set from DomainConfigs and node annotations
+    Lifecycle DomainLifecycle
+}
+
+// TopologyState represents the current state of the cluster topology
+type TopologyState struct {
+    Domains          map[string]*Domain
+    SpineConnections map[string][]string
 }
\ No newline at end of file
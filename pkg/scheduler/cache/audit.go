@@ -0,0 +1,95 @@
+package algorithm
+
+import (
+    v1 "k8s.io/api/core/v1"
+)
+
+// CacheAuditState is a copy of the cache fields checked by the auditor
+type CacheAuditState struct {
+    DomainForNode   map[string]string
+    NodeGPUs        map[string]int
+    AssumedNodeGPUs map[string]int
+    NodeAllocations map[string]int
+    DomainUsedGPUs  map[string]int
+    DomainTotalGPUs map[string]int
+    // WithheldGPUs are GPUs of unhealthy devices or reserved spares. They are
+    // not checked, since the API server knows neither.
+    WithheldGPUs map[string]int
+}
+
+func (tc *TopologyCache) AuditState() *CacheAuditState {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    state := &CacheAuditState{
+        DomainForNode:   make(map[string]string, len(tc.domainForNode)),
+        NodeGPUs:        make(map[string]int, len(tc.nodeGPUs)),
+        AssumedNodeGPUs: make(map[string]int),
+        NodeAllocations: make(map[string]int),
+        DomainUsedGPUs:  make(map[string]int, len(tc.domains)),
+        DomainTotalGPUs: make(map[string]int, len(tc.domains)),
+        WithheldGPUs:    make(map[string]int, len(tc.gpuDevices)),
+    }
+
+    for node, domain := range tc.domainForNode {
+        state.DomainForNode[node] = domain
+    }
+    for node, gpus := range tc.nodeGPUs {
+        state.NodeGPUs[node] = gpus
+    }
+    for node, devices := range tc.gpuDevices {
+        state.WithheldGPUs[node] = countUnhealthy(devices)
+    }
+    for node := range tc.reservedNodes {
+        if n, err := tc.nodeCache.GetNode(node); err == nil {
+            state.WithheldGPUs[node] = nodeGPUCapacity(n)
+        }
+    }
+    for _, ps := range tc.podStates {
+        if ps.assumed {
+            state.AssumedNodeGPUs[ps.nodeName] += ps.gpus
+        }
+    }
+    for name, domain := range tc.domains {
+        state.DomainUsedGPUs[name] = domain.UsedGPUs
+        state.DomainTotalGPUs[name] = domain.TotalGPUs
+    }
+
+    for _, node := range tc.nodeCache.GetAllNodes() {
+        if gpus, err := tc.nodeCache.GetGPUAllocation(node.Name); err == nil {
+            state.NodeAllocations[node.Name] = gpus
+        }
+    }
+    return state
+}
+
+// PauseEventHandlers blocks the node and pod informer handlers until
+// ResumeEventHandlers is called. Their events queue up in the informers.
+func (tc *TopologyCache) PauseEventHandlers() {
+    tc.handlerMu.Lock()
+}
+
+func (tc *TopologyCache) ResumeEventHandlers() {
+    tc.handlerMu.Unlock()
+}
+
+// ResyncPods replaces the bound pods in the cache with the given list. Assumed
+// pods are kept since the API server does not know about them yet.
+func (tc *TopologyCache) ResyncPods(pods []*v1.Pod) {
+    bound := make(map[string]bool, len(pods))
+    for _, pod := range pods {
+        bound[string(pod.UID)] = true
+    }
+
+    tc.Lock()
+    for key, state := range tc.podStates {
+        if !state.assumed && !bound[key] {
+            tc.removePodLocked(key)
+        }
+    }
+    tc.Unlock()
+
+    for _, pod := range pods {
+        tc.AddPod(pod)
+    }
+}
@@ -0,0 +1,148 @@
+package algorithm
+
+import (
+    "sort"
+    "time"
+
+    corelisters "k8s.io/client-go/listers/core/v1"
+    "k8s.io/klog/v2"
+)
+
+// AssumedPodCheckpoint is a reservation that has not yet been observed bound
+type AssumedPodCheckpoint struct {
+    UID       string    `json:"uid"`
+    Namespace string    `json:"namespace"`
+    Name      string    `json:"name"`
+    NodeName  string    `json:"nodeName"`
+    GPUs      int       `json:"gpus"`
+    // Deadline is zero while the pod's binding has not been sent
+    Deadline time.Time `json:"deadline"`
+}
+
+// CacheCheckpoint is the cache state that cannot be rebuilt from informers
+type CacheCheckpoint struct {
+    AssumedPods   []AssumedPodCheckpoint `json:"assumedPods,omitempty"`
+    DomainJobs    map[string][]string    `json:"domainJobs,omitempty"`
+    LinkOverrides []LinkOverride         `json:"linkOverrides,omitempty"`
+}
+
+func (tc *TopologyCache) Checkpoint() *CacheCheckpoint {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    cp := &CacheCheckpoint{
+        DomainJobs: make(map[string][]string, len(tc.domainJobs)),
+    }
+
+    for key, state := range tc.podStates {
+        if !state.assumed {
+            continue
+        }
+        checkpoint := AssumedPodCheckpoint{
+            UID:       key,
+            Namespace: state.pod.Namespace,
+            Name:      state.pod.Name,
+            NodeName:  state.nodeName,
+            GPUs:      state.gpus,
+        }
+        if state.bindingFinished {
+            checkpoint.Deadline = state.deadline
+        }
+        cp.AssumedPods = append(cp.AssumedPods, checkpoint)
+    }
+    // Sorted so an unchanged cache encodes to the same checkpoint
+    sort.Slice(cp.AssumedPods, func(i, j int) bool {
+        return cp.AssumedPods[i].UID < cp.AssumedPods[j].UID
+    })
+
+    for domain, jobs := range tc.domainJobs {
+        for job := range jobs {
+            cp.DomainJobs[domain] = append(cp.DomainJobs[domain], job)
+        }
+        sort.Strings(cp.DomainJobs[domain])
+    }
+
+    for _, override := range tc.linkOverrides {
+        cp.LinkOverrides = append(cp.LinkOverrides, override)
+    }
+    sort.Slice(cp.LinkOverrides, func(i, j int) bool {
+        if cp.LinkOverrides[i].Source != cp.LinkOverrides[j].Source {
+            return cp.LinkOverrides[i].Source < cp.LinkOverrides[j].Source
+        }
+        return cp.LinkOverrides[i].Target < cp.LinkOverrides[j].Target
+    })
+    return cp
+}
+
+// Restore re-applies checkpointed reservations on top of informer state. It
+// must run after the informers have synced. A reservation is only restored
+// for a pod that still exists with the same UID and is not yet bound or
+// finished; the informer already accounts for bound pods. Restored
+// reservations still expire on their original deadline. The cycle that held a
+// reservation whose binding was not yet sent is gone with the previous
+// leader, so such a reservation expires a TTL from now, and is released as
+// soon as the new leader reserves the pod again.
+func (tc *TopologyCache) Restore(cp *CacheCheckpoint, pods corelisters.PodLister) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    restored := 0
+    now := time.Now()
+    for _, ap := range cp.AssumedPods {
+        if _, exists := tc.podStates[ap.UID]; exists {
+            continue
+        }
+        pod, err := pods.Pods(ap.Namespace).Get(ap.Name)
+        if err != nil || string(pod.UID) != ap.UID || pod.Spec.NodeName != "" || isTerminalPod(pod) {
+            klog.V(2).Infof("Dropping checkpointed reservation of pod %s/%s on node %s, the pod is gone or already bound",
+                ap.Namespace, ap.Name, ap.NodeName)
+            continue
+        }
+        deadline := ap.Deadline
+        if deadline.IsZero() {
+            deadline = now.Add(tc.assumedPodTTL)
+        }
+        if now.After(deadline) {
+            continue
+        }
+
+        tc.addPodLocked(ap.UID, &podState{
+            pod:             pod,
+            nodeName:        ap.NodeName,
+            gpus:            ap.GPUs,
+            assumed:         true,
+            restored:        true,
+            bindingFinished: true,
+            deadline:        deadline,
+        })
+        restored++
+    }
+
+    for domain, jobs := range cp.DomainJobs {
+        if _, exists := tc.domains[domain]; !exists {
+            continue
+        }
+        if tc.domainJobs[domain] == nil {
+            tc.domainJobs[domain] = make(map[string]bool)
+        }
+        for _, job := range jobs {
+            tc.domainJobs[domain][job] = true
+        }
+    }
+
+    // Overrides already received from DomainConfigs are newer than the checkpoint
+    for _, override := range cp.LinkOverrides {
+        key := linkKey(override.Source, override.Target)
+        if _, exists := tc.linkOverrides[key]; exists {
+            continue
+        }
+        tc.linkOverrides[key] = override
+        if link, exists := tc.links[key]; exists {
+            applyLinkOverride(link, override)
+        }
+    }
+    tc.rebuildConnectionsLocked()
+
+    tc.touchLocked()
+    klog.Infof("Restored %d of %d checkpointed reservations", restored, len(cp.AssumedPods))
+}
@@ -0,0 +1,126 @@
+package algorithm
+
+import (
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/equality"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+// SetGPUHealth replaces the device health reported for a node. Unhealthy
+// devices are taken out of the node's usable capacity, so the node keeps
+// serving with the GPUs it has left instead of failing as a whole.
+func (tc *TopologyCache) SetGPUHealth(nodeName string, devices []topology.GPUDevice) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    before := tc.gpuDevices[nodeName]
+    if len(devices) == 0 {
+        delete(tc.gpuDevices, nodeName)
+    } else {
+        tc.gpuDevices[nodeName] = append([]topology.GPUDevice(nil), devices...)
+    }
+    if countUnhealthy(before) == countUnhealthy(devices) {
+        return
+    }
+
+    klog.V(2).Infof("Node %s has %d unhealthy GPU(s)", nodeName, countUnhealthy(devices))
+    if domainName, exists := tc.domainForNode[nodeName]; exists {
+        if domain, exists := tc.domains[domainName]; exists {
+            tc.recomputeDomainCapacityLocked(domain)
+        }
+    }
+    tc.touchLocked()
+}
+
+// SetReservedNodes replaces the set of spare nodes held back from scheduling.
+// Their GPUs are taken out of their domains' capacity.
+func (tc *TopologyCache) SetReservedNodes(nodes map[string]bool) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    changed := make(map[string]bool)
+    for name := range tc.reservedNodes {
+        if !nodes[name] {
+            changed[name] = true
+        }
+    }
+    for name := range nodes {
+        if !tc.reservedNodes[name] {
+            changed[name] = true
+        }
+    }
+    if len(changed) == 0 {
+        return
+    }
+
+    tc.reservedNodes = make(map[string]bool, len(nodes))
+    for name := range nodes {
+        tc.reservedNodes[name] = true
+    }
+    for name := range changed {
+        if domain, exists := tc.domains[tc.domainForNode[name]]; exists {
+            tc.recomputeDomainCapacityLocked(domain)
+        }
+    }
+    tc.touchLocked()
+}
+
+// SetSpareClaims replaces the spares and the replacement pods matched to each
+// one's claims, which decide what Filter lets onto a spare
+func (tc *TopologyCache) SetSpareClaims(claims map[string]map[string]bool) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if equality.Semantic.DeepEqual(tc.spareClaims, claims) {
+        return
+    }
+    tc.spareClaims = claims
+    tc.touchLocked()
+}
+
+// GetNodeGPUInfo returns a node's GPU inventory with the health of each device
+func (tc *TopologyCache) GetNodeGPUInfo(nodeName string) (*topology.NodeGPUInfo, error) {
+    node, err := tc.nodeCache.GetNode(nodeName)
+    if err != nil {
+        return nil, err
+    }
+
+    tc.RLock()
+    defer tc.RUnlock()
+
+    info, err := topology.ExtractNodeGPUInfo(node)
+    if err != nil {
+        info = &topology.NodeGPUInfo{}
+    }
+    info.TotalGPUs = nodeGPUCapacity(node)
+    info.AllocatedGPUs = tc.nodeGPUs[nodeName]
+    info.Devices = append([]topology.GPUDevice(nil), tc.gpuDevices[nodeName]...)
+    return info, nil
+}
+
+// usableGPUsLocked is a node's GPU capacity less its unhealthy devices. A
+// reserved spare has none until it is released.
+func (tc *TopologyCache) usableGPUsLocked(node *v1.Node) int {
+    if tc.reservedNodes[node.Name] {
+        return 0
+    }
+    info := topology.NodeGPUInfo{
+        TotalGPUs: nodeGPUCapacity(node),
+        Devices:   tc.gpuDevices[node.Name],
+    }
+    return info.UsableGPUs()
+}
+
+func (tc *TopologyCache) recomputeDomainCapacityLocked(domain *Domain) {
+    domain.TotalGPUs = 0
+    for _, node := range domain.Nodes {
+        domain.TotalGPUs += tc.usableGPUsLocked(node)
+    }
+}
+
+func countUnhealthy(devices []topology.GPUDevice) int {
+    info := topology.NodeGPUInfo{Devices: devices}
+    return info.UnhealthyGPUs()
+}
@@ -0,0 +1,159 @@
+package algorithm
+
+import (
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/util/wait"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+const (
+    // DefaultFlapWindow is how long a node health transition counts against its domain
+    DefaultFlapWindow = 10 * time.Minute
+
+    flapExpiryPeriod = time.Minute
+)
+
+// HealthObserver is notified of node and domain health while the cache lock
+// is held, so it must not call back into the cache
+type HealthObserver interface {
+    UpdateNodeMetrics(node string, domain string, gpuCount int, healthy bool)
+    UpdateDomainHealth(domain string, health float64, flaps int)
+}
+
+// nodeHealth is the last observed health of a node and when it last changed
+type nodeHealth struct {
+    healthy     bool
+    transitions []time.Time
+}
+
+// SetFlapWindow sets how long health transitions are counted as recent
+func (tc *TopologyCache) SetFlapWindow(window time.Duration) {
+    tc.Lock()
+    defer tc.Unlock()
+    tc.flapWindow = window
+    tc.expireFlapsLocked(time.Now())
+}
+
+func (tc *TopologyCache) SetHealthObserver(observer HealthObserver) {
+    tc.Lock()
+    defer tc.Unlock()
+    tc.healthObserver = observer
+}
+
+// IsNodeHealthy returns the last health observed for a node
+func (tc *TopologyCache) IsNodeHealthy(nodeName string) bool {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    state, exists := tc.nodeHealth[nodeName]
+    return exists && state.healthy
+}
+
+// RunFlapExpiry forgets health transitions older than the flap window until
+// stopCh is closed, so a domain's score recovers once it stops flapping
+func (tc *TopologyCache) RunFlapExpiry(stopCh <-chan struct{}) {
+    wait.Until(func() {
+        tc.Lock()
+        defer tc.Unlock()
+        tc.expireFlapsLocked(time.Now())
+    }, flapExpiryPeriod, stopCh)
+}
+
+// recordNodeHealthLocked updates a node's health from its conditions and taints
+func (tc *TopologyCache) recordNodeHealthLocked(node *v1.Node, now time.Time) {
+    healthy := topology.IsNodeHealthy(node)
+
+    state, exists := tc.nodeHealth[node.Name]
+    if !exists {
+        tc.nodeHealth[node.Name] = &nodeHealth{healthy: healthy}
+        return
+    }
+    if state.healthy != healthy {
+        state.healthy = healthy
+        state.transitions = append(state.transitions, now)
+    }
+}
+
+// recomputeDomainHealthLocked derives a domain's health from its members
+func (tc *TopologyCache) recomputeDomainHealthLocked(domain *Domain, now time.Time) {
+    domain.HealthyNodes = 0
+    domain.RecentFlaps = 0
+    for _, node := range domain.Nodes {
+        state, exists := tc.nodeHealth[node.Name]
+        if !exists {
+            continue
+        }
+        if state.healthy {
+            domain.HealthyNodes++
+        }
+        domain.RecentFlaps += recentTransitions(state.transitions, now, tc.flapWindow)
+    }
+
+    domain.Health = 0
+    if len(domain.Nodes) > 0 {
+        domain.Health = float64(domain.HealthyNodes) / float64(len(domain.Nodes))
+    }
+
+    if tc.healthObserver != nil {
+        tc.healthObserver.UpdateDomainHealth(domain.Name, domain.Health, domain.RecentFlaps)
+    }
+}
+
+func (tc *TopologyCache) observeNodeHealthLocked(nodeName string) {
+    if tc.healthObserver == nil {
+        return
+    }
+    state, exists := tc.nodeHealth[nodeName]
+    if !exists {
+        return
+    }
+    tc.healthObserver.UpdateNodeMetrics(nodeName, tc.domainForNode[nodeName], tc.nodeGPUs[nodeName], state.healthy)
+}
+
+func (tc *TopologyCache) expireFlapsLocked(now time.Time) {
+    expired := false
+    for _, state := range tc.nodeHealth {
+        recent := recentTransitions(state.transitions, now, tc.flapWindow)
+        if recent != len(state.transitions) {
+            state.transitions = state.transitions[len(state.transitions)-recent:]
+            expired = true
+        }
+    }
+    if !expired {
+        return
+    }
+
+    for _, domain := range tc.domains {
+        tc.recomputeDomainHealthLocked(domain, now)
+    }
+    tc.touchLocked()
+}
+
+// recentTransitions counts the transitions inside the window. Transitions
+// are appended in time order, so the recent ones are a suffix.
+func recentTransitions(transitions []time.Time, now time.Time, window time.Duration) int {
+    cutoff := now.Add(-window)
+    for i, t := range transitions {
+        if t.After(cutoff) {
+            return len(transitions) - i
+        }
+    }
+    return 0
+}
+
+// domainHealthState summarizes a domain's health for the topology export
+func domainHealthState(domain *Domain) string {
+    switch {
+    case len(domain.Nodes) == 0 || domain.HealthyNodes == 0:
+        return DomainStateUnhealthy
+    case domain.HealthyNodes < len(domain.Nodes):
+        return DomainStateDegraded
+    case domain.RecentFlaps > 0:
+        return DomainStateFlapping
+    default:
+        return DomainStateHealthy
+    }
+}
@@ -0,0 +1,99 @@
+package algorithm
+
+import (
+    "fmt"
+
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// DomainLifecycle is the operator-set state of a domain, for taking it out of
+// service without touching its nodes
+type DomainLifecycle string
+
+const (
+    DomainActive DomainLifecycle = v1alpha1.DomainLifecycleActive
+    // DomainCordoned takes no new placements; running pods stay
+    DomainCordoned DomainLifecycle = v1alpha1.DomainLifecycleCordoned
+    // DomainDraining takes no new placements and its GPU pods are moved out
+    DomainDraining DomainLifecycle = v1alpha1.DomainLifecycleDraining
+    // DomainMaintenance is being serviced; node failures in it are not recovered
+    DomainMaintenance DomainLifecycle = v1alpha1.DomainLifecycleMaintenance
+)
+
+var lifecycleRank = map[DomainLifecycle]int{
+    DomainActive:      0,
+    DomainCordoned:    1,
+    DomainDraining:    2,
+    DomainMaintenance: 3,
+}
+
+// ParseDomainLifecycle validates a lifecycle. An empty value is Active.
+func ParseDomainLifecycle(value string) (DomainLifecycle, error) {
+    if value == "" {
+        return DomainActive, nil
+    }
+    lifecycle := DomainLifecycle(value)
+    if _, ok := lifecycleRank[lifecycle]; !ok {
+        return "", fmt.Errorf("unknown domain lifecycle %q, must be Active, Cordoned, Draining or Maintenance", value)
+    }
+    return lifecycle, nil
+}
+
+// AcceptsPlacements reports whether new pods may be placed in the domain
+func (l DomainLifecycle) AcceptsPlacements() bool {
+    return l == "" || l == DomainActive
+}
+
+// Drains reports whether the domain's GPU pods should be moved out
+func (l DomainLifecycle) Drains() bool {
+    return l == DomainDraining || l == DomainMaintenance
+}
+
+// SetDomainLifecycle records the lifecycle declared for a leaf or spine
+// domain. An empty lifecycle clears it.
+func (tc *TopologyCache) SetDomainLifecycle(domainName string, lifecycle DomainLifecycle) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if lifecycle == "" || lifecycle == DomainActive {
+        if _, exists := tc.domainLifecycles[domainName]; !exists {
+            return
+        }
+        delete(tc.domainLifecycles, domainName)
+    } else {
+        if tc.domainLifecycles[domainName] == lifecycle {
+            return
+        }
+        tc.domainLifecycles[domainName] = lifecycle
+    }
+
+    for _, domain := range tc.domains {
+        if domain.Name == domainName || domain.SpineSwitch == domainName {
+            tc.recomputeDomainLifecycleLocked(domain)
+        }
+    }
+    tc.touchLocked()
+}
+
+// recomputeDomainLifecycleLocked applies the more restrictive of the
+// lifecycles declared for the domain and its spine
+func (tc *TopologyCache) recomputeDomainLifecycleLocked(domain *Domain) {
+    lifecycle := DomainActive
+    restrict := func(l DomainLifecycle) {
+        if lifecycleRank[l] > lifecycleRank[lifecycle] {
+            lifecycle = l
+        }
+    }
+
+    restrict(tc.domainLifecycles[domain.Name])
+    if domain.SpineSwitch != "" {
+        restrict(tc.domainLifecycles[domain.SpineSwitch])
+    }
+
+    if domain.Lifecycle != lifecycle && (domain.Lifecycle != "" || lifecycle != DomainActive) {
+        klog.Infof("Domain %s is now %s", domain.Name, lifecycle)
+    }
+    domain.Lifecycle = lifecycle
+}
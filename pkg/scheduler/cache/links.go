@@ -0,0 +1,334 @@
+package algorithm
+
+import (
+    "fmt"
+    "sort"
+
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+type LinkState string
+
+const (
+    LinkStateUp       LinkState = "Up"
+    LinkStateDegraded LinkState = "Degraded"
+    LinkStateDown     LinkState = "Down"
+)
+
+// Link is a bidirectional edge in the spine graph. Leaf domains are linked to
+// their spine by an uplink, and to other leaves by explicit spine connections.
+type Link struct {
+    Source        string    `json:"source"`
+    Target        string    `json:"target"`
+    CapacityGbps  int64     `json:"capacityGbps,omitempty"`
+    LatencyMicros float64   `json:"latencyMicros,omitempty"`
+    State         LinkState `json:"state"`
+    Reason        string    `json:"reason,omitempty"`
+}
+
+// DegradedCapacityFactor is the share of its capacity a degraded link is assumed to deliver
+const DegradedCapacityFactor = 0.5
+
+// LinkOverride is an operator-set link state. It is kept even while the link
+// does not exist, and applied as soon as the link is discovered.
+type LinkOverride struct {
+    Source        string    `json:"source"`
+    Target        string    `json:"target"`
+    CapacityGbps  int64     `json:"capacityGbps,omitempty"`
+    LatencyMicros float64   `json:"latencyMicros,omitempty"`
+    State         LinkState `json:"state"`
+    Reason        string    `json:"reason,omitempty"`
+}
+
+func (s LinkState) Validate() error {
+    switch s {
+    case LinkStateUp, LinkStateDegraded, LinkStateDown:
+        return nil
+    }
+    return fmt.Errorf("invalid link state %q, must be %s, %s or %s", s, LinkStateUp, LinkStateDegraded, LinkStateDown)
+}
+
+// severity orders link states from best to worst
+func (s LinkState) severity() int {
+    switch s {
+    case LinkStateDown:
+        return 2
+    case LinkStateDegraded:
+        return 1
+    }
+    return 0
+}
+
+// linkKey identifies a link regardless of direction
+func linkKey(a, b string) string {
+    if a > b {
+        a, b = b, a
+    }
+    return a + "|" + b
+}
+
+func (tc *TopologyCache) AddSpineConnection(source, target string) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if _, exists := tc.domains[source]; !exists {
+        return fmt.Errorf("source domain %s not found", source)
+    }
+    if _, exists := tc.domains[target]; !exists {
+        return fmt.Errorf("target domain %s not found", target)
+    }
+
+    tc.putLinkLocked(source, target)
+    tc.rebuildConnectionsLocked()
+    tc.touchLocked()
+    return nil
+}
+
+func (tc *TopologyCache) RemoveSpineConnection(source, target string) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    key := linkKey(source, target)
+    if _, exists := tc.links[key]; !exists {
+        return fmt.Errorf("no link between %s and %s", source, target)
+    }
+
+    delete(tc.links, key)
+    tc.rebuildConnectionsLocked()
+    tc.touchLocked()
+    return nil
+}
+
+// SetLinkState overrides the state of a link. A zero capacity or latency
+// keeps the declared one. The override survives the link being removed and re-added.
+func (tc *TopologyCache) SetLinkState(override LinkOverride) error {
+    if err := override.State.Validate(); err != nil {
+        return err
+    }
+    if override.Source == "" || override.Target == "" || override.Source == override.Target {
+        return fmt.Errorf("a link needs two distinct endpoints, got %q and %q", override.Source, override.Target)
+    }
+
+    tc.Lock()
+    defer tc.Unlock()
+
+    key := linkKey(override.Source, override.Target)
+    if existing, exists := tc.linkOverrides[key]; exists && existing == override {
+        return nil
+    }
+    tc.linkOverrides[key] = override
+    if link, exists := tc.links[key]; exists {
+        declared := newLink(link.Source, link.Target)
+        applyLinkOverride(declared, override)
+        tc.links[key] = declared
+        tc.rebuildConnectionsLocked()
+        tc.touchLocked()
+    }
+
+    klog.Infof("Link %s <-> %s set to %s: %s", override.Source, override.Target, override.State, override.Reason)
+    return nil
+}
+
+// ClearLinkState drops an override and returns the link to the up state with
+// its declared capacity and latency
+func (tc *TopologyCache) ClearLinkState(source, target string) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    key := linkKey(source, target)
+    delete(tc.linkOverrides, key)
+    if link, exists := tc.links[key]; exists {
+        tc.links[key] = newLink(link.Source, link.Target)
+        tc.rebuildConnectionsLocked()
+        tc.touchLocked()
+    }
+}
+
+// GetLinks returns a copy of every link, ordered by endpoints
+func (tc *TopologyCache) GetLinks() []Link {
+    tc.RLock()
+    defer tc.RUnlock()
+    return tc.linksLocked()
+}
+
+func (tc *TopologyCache) linksLocked() []Link {
+    links := make([]Link, 0, len(tc.links))
+    for _, link := range tc.links {
+        links = append(links, *link)
+    }
+    sort.Slice(links, func(i, j int) bool {
+        return linkKey(links[i].Source, links[i].Target) < linkKey(links[j].Source, links[j].Target)
+    })
+    return links
+}
+
+func (tc *TopologyCache) putLinkLocked(source, target string) {
+    key := linkKey(source, target)
+    if _, exists := tc.links[key]; exists {
+        return
+    }
+
+    link := newLink(source, target)
+    if override, exists := tc.linkOverrides[key]; exists {
+        applyLinkOverride(link, override)
+    }
+    tc.links[key] = link
+}
+
+// setUplinkLocked moves a leaf domain's uplink to a new spine
+func (tc *TopologyCache) setUplinkLocked(domain *Domain, spineName string) {
+    if domain.SpineSwitch != "" {
+        delete(tc.links, linkKey(domain.Name, domain.SpineSwitch))
+    }
+    domain.SpineSwitch = spineName
+    if spineName != "" {
+        tc.putLinkLocked(domain.Name, spineName)
+    }
+    tc.rebuildConnectionsLocked()
+}
+
+// removeDomainLinksLocked drops every link to and from a domain
+func (tc *TopologyCache) removeDomainLinksLocked(domainName string) {
+    for key, link := range tc.links {
+        if link.Source == domainName || link.Target == domainName {
+            delete(tc.links, key)
+        }
+    }
+    tc.rebuildConnectionsLocked()
+}
+
+// rebuildConnectionsLocked derives the leaf-to-leaf connections and each
+// domain's link state from the links. Leaves under the same spine are
+// connected while both their uplinks are not down.
+func (tc *TopologyCache) rebuildConnectionsLocked() {
+    tc.spineConnections = make(map[string][]string)
+    connect := func(a, b string) {
+        tc.spineConnections[a] = append(tc.spineConnections[a], b)
+        tc.spineConnections[b] = append(tc.spineConnections[b], a)
+    }
+
+    leavesBySpine := make(map[string][]string)
+    for name, domain := range tc.domains {
+        domain.LinkState = ""
+        if domain.SpineSwitch == "" {
+            continue
+        }
+        if uplink, exists := tc.links[linkKey(name, domain.SpineSwitch)]; exists && uplink.State != LinkStateDown {
+            leavesBySpine[domain.SpineSwitch] = append(leavesBySpine[domain.SpineSwitch], name)
+        }
+    }
+    for _, leaves := range leavesBySpine {
+        sort.Strings(leaves)
+        for i := range leaves {
+            for j := i + 1; j < len(leaves); j++ {
+                connect(leaves[i], leaves[j])
+            }
+        }
+    }
+
+    for _, link := range tc.links {
+        for _, endpoint := range []string{link.Source, link.Target} {
+            if domain, exists := tc.domains[endpoint]; exists {
+                if domain.LinkState == "" || link.State.severity() > domain.LinkState.severity() {
+                    domain.LinkState = link.State
+                }
+            }
+        }
+
+        _, sourceIsDomain := tc.domains[link.Source]
+        _, targetIsDomain := tc.domains[link.Target]
+        if sourceIsDomain && targetIsDomain && link.State != LinkStateDown {
+            connect(link.Source, link.Target)
+        }
+    }
+
+    tc.distances.Store(nil)
+    tc.hops.Store(nil)
+}
+
+// distanceTableLocked returns the all-pairs weighted distances over links
+// that are not down, computing them if the links changed since they were last built
+func (tc *TopologyCache) distanceTableLocked() *topology.DistanceTable {
+    if distances := tc.distances.Load(); distances != nil {
+        return distances
+    }
+
+    edges := make([]topology.Edge, 0, len(tc.links))
+    for _, link := range tc.links {
+        if link.State == LinkStateDown {
+            continue
+        }
+        edges = append(edges, topology.Edge{
+            Source: link.Source,
+            Target: link.Target,
+            Cost:   linkCost(link),
+        })
+    }
+
+    distances := topology.NewDistanceTable(edges)
+    tc.distances.Store(distances)
+    return distances
+}
+
+// hopTableLocked returns the all-pairs link counts over links that are not
+// down, computing them if the links changed since they were last built
+func (tc *TopologyCache) hopTableLocked() *topology.DistanceTable {
+    if hops := tc.hops.Load(); hops != nil {
+        return hops
+    }
+
+    edges := make([]topology.Edge, 0, len(tc.links))
+    for _, link := range tc.links {
+        if link.State == LinkStateDown {
+            continue
+        }
+        edges = append(edges, topology.Edge{Source: link.Source, Target: link.Target, Cost: 1})
+    }
+
+    hops := topology.NewDistanceTable(edges)
+    tc.hops.Store(hops)
+    return hops
+}
+
+// linkCost charges degraded links as if they delivered only part of their capacity
+func linkCost(link *Link) float64 {
+    capacity := float64(link.CapacityGbps)
+    if capacity <= 0 {
+        capacity = topology.DefaultLinkCapacityGbps
+    }
+    if link.State == LinkStateDegraded {
+        capacity *= DegradedCapacityFactor
+    }
+    return topology.LinkCost(link.LatencyMicros, capacity)
+}
+
+// GetDomainDistance returns the weighted distance between two domains, or an
+// error if every path between them is down
+func (tc *TopologyCache) GetDomainDistance(source, target string) (float64, error) {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    distance, ok := tc.distanceTableLocked().Distance(source, target)
+    if !ok {
+        return 0, fmt.Errorf("domain %s is not reachable from %s", target, source)
+    }
+    return distance, nil
+}
+
+// newLink returns a link as declared by the topology, before any override
+func newLink(source, target string) *Link {
+    return &Link{Source: source, Target: target, State: LinkStateUp}
+}
+
+func applyLinkOverride(link *Link, override LinkOverride) {
+    link.State = override.State
+    link.Reason = override.Reason
+    if override.CapacityGbps > 0 {
+        link.CapacityGbps = override.CapacityGbps
+    }
+    if override.LatencyMicros > 0 {
+        link.LatencyMicros = override.LatencyMicros
+    }
+}
@@ -0,0 +1,53 @@
+package algorithm
+
+import (
+    v1 "k8s.io/api/core/v1"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/klog/v2"
+)
+
+// AddNodeEventHandler keeps the node and topology caches in sync with the
+// cluster's nodes. Label changes move nodes between domains and condition
+// changes replace the cached node object.
+func (tc *TopologyCache) AddNodeEventHandler(informer coreinformers.NodeInformer) {
+    informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc: func(obj interface{}) {
+            node, ok := obj.(*v1.Node)
+            if !ok {
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.UpsertNode(node)
+            tc.handlerMu.RUnlock()
+        },
+        UpdateFunc: func(oldObj, newObj interface{}) {
+            node, ok := newObj.(*v1.Node)
+            if !ok {
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.UpsertNode(node)
+            tc.handlerMu.RUnlock()
+        },
+        DeleteFunc: func(obj interface{}) {
+            var node *v1.Node
+            switch t := obj.(type) {
+            case *v1.Node:
+                node = t
+            case cache.DeletedFinalStateUnknown:
+                var ok bool
+                node, ok = t.Obj.(*v1.Node)
+                if !ok {
+                    klog.Errorf("Unexpected object in node tombstone: %T", t.Obj)
+                    return
+                }
+            default:
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.DeleteNode(node.Name)
+            tc.handlerMu.RUnlock()
+        },
+    })
+}
@@ -0,0 +1,279 @@
+package algorithm
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/klog/v2"
+)
+
+const (
+    // DefaultAssumedPodTTL is how long an assumed pod holds its GPUs after its
+    // binding is sent without being observed as bound
+    DefaultAssumedPodTTL = 30 * time.Second
+
+    assumedPodCleanupPeriod = time.Second
+)
+
+// podState tracks the GPUs a pod holds on a node. Assumed pods have been
+// reserved by the scheduler but not yet observed bound by the informer.
+type podState struct {
+    pod      *v1.Pod
+    nodeName string
+    gpus     int
+    assumed  bool
+    // restored reservations were made by a previous leader
+    restored bool
+    // bindingFinished is set once the binding has been sent; only then does
+    // deadline count, so pods waiting in Permit or PreBind never expire
+    bindingFinished bool
+    deadline        time.Time
+}
+
+// AssumePod charges a pod's GPUs to a node before it is bound, so that
+// concurrent scheduling cycles see the reservation. The reservation holds
+// until FinishBinding starts its expiry clock or ForgetPod releases it.
+func (tc *TopologyCache) AssumePod(pod *v1.Pod, nodeName string) error {
+    key := string(pod.UID)
+
+    tc.Lock()
+    defer tc.Unlock()
+
+    if state, exists := tc.podStates[key]; exists {
+        if !state.assumed || !state.restored {
+            return fmt.Errorf("pod %s/%s is already in the cache", pod.Namespace, pod.Name)
+        }
+        // The previous leader's reservation gives way to this leader's
+        tc.removePodLocked(key)
+    }
+
+    tc.addPodLocked(key, &podState{
+        pod:      pod,
+        nodeName: nodeName,
+        gpus:     getGPURequirements(pod),
+        assumed:  true,
+    })
+    return nil
+}
+
+// FinishBinding starts the expiry clock of an assumed pod once its binding
+// has been sent; it expires if the informer never reports it as bound
+func (tc *TopologyCache) FinishBinding(pod *v1.Pod) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if state, exists := tc.podStates[string(pod.UID)]; exists && state.assumed {
+        state.bindingFinished = true
+        state.deadline = time.Now().Add(tc.assumedPodTTL)
+    }
+}
+
+// ForgetPod releases an assumed pod's GPUs after Unreserve or a bind failure
+func (tc *TopologyCache) ForgetPod(pod *v1.Pod) {
+    key := string(pod.UID)
+
+    tc.Lock()
+    defer tc.Unlock()
+
+    state, exists := tc.podStates[key]
+    if !exists || !state.assumed {
+        return
+    }
+    tc.removePodLocked(key)
+}
+
+// AddPod records a pod observed by the informer. Pods that are not bound,
+// have finished, or use no GPUs hold nothing.
+func (tc *TopologyCache) AddPod(pod *v1.Pod) {
+    key := string(pod.UID)
+
+    tc.Lock()
+    defer tc.Unlock()
+
+    state, exists := tc.podStates[key]
+    if pod.Spec.NodeName == "" {
+        return
+    }
+
+    if isTerminalPod(pod) {
+        if exists {
+            tc.removePodLocked(key)
+        }
+        return
+    }
+
+    gpus := getGPURequirements(pod)
+    if exists {
+        if state.assumed && state.nodeName != pod.Spec.NodeName {
+            klog.Warningf("Pod %s/%s was assumed on %s but bound to %s",
+                pod.Namespace, pod.Name, state.nodeName, pod.Spec.NodeName)
+        }
+        if state.nodeName == pod.Spec.NodeName && state.gpus == gpus {
+            state.pod = pod
+            state.assumed = false
+            return
+        }
+        tc.removePodLocked(key)
+    }
+
+    if gpus == 0 {
+        return
+    }
+    tc.addPodLocked(key, &podState{
+        pod:      pod,
+        nodeName: pod.Spec.NodeName,
+        gpus:     gpus,
+    })
+}
+
+// RemovePod releases a deleted pod's GPUs
+func (tc *TopologyCache) RemovePod(pod *v1.Pod) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if _, exists := tc.podStates[string(pod.UID)]; exists {
+        tc.removePodLocked(string(pod.UID))
+    }
+}
+
+// GetNodePods returns the pods holding GPUs on a node, bound or assumed
+func (tc *TopologyCache) GetNodePods(nodeName string) []*v1.Pod {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    var pods []*v1.Pod
+    for _, state := range tc.podStates {
+        if state.nodeName == nodeName {
+            pods = append(pods, state.pod)
+        }
+    }
+    return pods
+}
+
+// GetJobDomains returns the sorted domains holding the GPU pods of a job,
+// bound or assumed
+func (tc *TopologyCache) GetJobDomains(jobName string) []string {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    seen := make(map[string]bool)
+    var domains []string
+    for _, state := range tc.podStates {
+        if getJobName(state.pod) != jobName {
+            continue
+        }
+        domain, exists := tc.domainForNode[state.nodeName]
+        if exists && !seen[domain] {
+            seen[domain] = true
+            domains = append(domains, domain)
+        }
+    }
+    sort.Strings(domains)
+    return domains
+}
+
+// GetJobNodes returns the node of each GPU pod of a job, bound or assumed,
+// keyed by pod UID
+func (tc *TopologyCache) GetJobNodes(jobName string) map[types.UID]string {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    nodes := make(map[types.UID]string)
+    for _, state := range tc.podStates {
+        if getJobName(state.pod) == jobName {
+            nodes[state.pod.UID] = state.nodeName
+        }
+    }
+    return nodes
+}
+
+// RunAssumedPodCleanup expires assumed pods past their deadline until stopCh is closed
+func (tc *TopologyCache) RunAssumedPodCleanup(stopCh <-chan struct{}) {
+    wait.Until(func() {
+        tc.cleanupAssumedPods(time.Now())
+    }, assumedPodCleanupPeriod, stopCh)
+}
+
+func (tc *TopologyCache) cleanupAssumedPods(now time.Time) {
+    tc.Lock()
+    defer tc.Unlock()
+
+    for key, state := range tc.podStates {
+        if state.assumed && state.bindingFinished && now.After(state.deadline) {
+            klog.Warningf("Assumed pod %s/%s on node %s expired without being bound",
+                state.pod.Namespace, state.pod.Name, state.nodeName)
+            tc.removePodLocked(key)
+        }
+    }
+}
+
+func (tc *TopologyCache) addPodLocked(key string, state *podState) {
+    tc.podStates[key] = state
+    tc.nodeGPUs[state.nodeName] += state.gpus
+    tc.syncNodeUsageLocked(state.nodeName)
+    if domainName, exists := tc.domainForNode[state.nodeName]; exists {
+        if tc.domainJobs[domainName] == nil {
+            tc.domainJobs[domainName] = make(map[string]bool)
+        }
+        tc.domainJobs[domainName][getJobName(state.pod)] = true
+    }
+    tc.touchLocked()
+}
+
+func (tc *TopologyCache) removePodLocked(key string) {
+    state := tc.podStates[key]
+    delete(tc.podStates, key)
+
+    tc.nodeGPUs[state.nodeName] -= state.gpus
+    if tc.nodeGPUs[state.nodeName] <= 0 {
+        delete(tc.nodeGPUs, state.nodeName)
+    }
+    tc.syncNodeUsageLocked(state.nodeName)
+    tc.pruneJobLocked(getJobName(state.pod), state.nodeName)
+    tc.touchLocked()
+}
+
+// pruneJobLocked drops a job from the domain of a node once none of the
+// job's pods are left in that domain
+func (tc *TopologyCache) pruneJobLocked(jobName, nodeName string) {
+    domainName, exists := tc.domainForNode[nodeName]
+    if !exists || !tc.domainJobs[domainName][jobName] {
+        return
+    }
+    for _, state := range tc.podStates {
+        if tc.domainForNode[state.nodeName] == domainName && getJobName(state.pod) == jobName {
+            return
+        }
+    }
+    tc.removeJobFromDomainLocked(jobName, domainName)
+}
+
+// syncNodeUsageLocked pushes a node's GPU usage to the node cache and
+// recomputes the usage of the domain it belongs to
+func (tc *TopologyCache) syncNodeUsageLocked(nodeName string) {
+    if err := tc.nodeCache.UpdateGPUAllocation(nodeName, tc.nodeGPUs[nodeName]); err != nil {
+        klog.V(4).Infof("GPU usage for node %s recorded before the node was seen: %v", nodeName, err)
+    }
+
+    if domainName, exists := tc.domainForNode[nodeName]; exists {
+        tc.recomputeDomainUsageLocked(tc.domains[domainName])
+    }
+}
+
+func (tc *TopologyCache) recomputeDomainUsageLocked(domain *Domain) {
+    if domain == nil {
+        return
+    }
+    domain.UsedGPUs = 0
+    for _, node := range domain.Nodes {
+        domain.UsedGPUs += tc.nodeGPUs[node.Name]
+    }
+}
+
+func isTerminalPod(pod *v1.Pod) bool {
+    return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
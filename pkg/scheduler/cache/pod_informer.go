@@ -0,0 +1,52 @@
+package algorithm
+
+import (
+    v1 "k8s.io/api/core/v1"
+    coreinformers "k8s.io/client-go/informers/core/v1"
+    "k8s.io/client-go/tools/cache"
+    "k8s.io/klog/v2"
+)
+
+// AddPodEventHandler keeps GPU usage in the caches equal to the GPUs held by
+// bound pods. Pending pods are ignored; assumed pods are confirmed once seen bound.
+func (tc *TopologyCache) AddPodEventHandler(informer coreinformers.PodInformer) {
+    informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+        AddFunc: func(obj interface{}) {
+            pod, ok := obj.(*v1.Pod)
+            if !ok {
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.AddPod(pod)
+            tc.handlerMu.RUnlock()
+        },
+        UpdateFunc: func(oldObj, newObj interface{}) {
+            pod, ok := newObj.(*v1.Pod)
+            if !ok {
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.AddPod(pod)
+            tc.handlerMu.RUnlock()
+        },
+        DeleteFunc: func(obj interface{}) {
+            var pod *v1.Pod
+            switch t := obj.(type) {
+            case *v1.Pod:
+                pod = t
+            case cache.DeletedFinalStateUnknown:
+                var ok bool
+                pod, ok = t.Obj.(*v1.Pod)
+                if !ok {
+                    klog.Errorf("Unexpected object in pod tombstone: %T", t.Obj)
+                    return
+                }
+            default:
+                return
+            }
+            tc.handlerMu.RLock()
+            tc.RemovePod(pod)
+            tc.handlerMu.RUnlock()
+        },
+    })
+}
@@ -0,0 +1,200 @@
+package algorithm
+
+import (
+    "fmt"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+// TopologySnapshot is an immutable copy of the topology cache. It is shared
+// between scheduling cycles until the cache changes, so it must never be modified.
+type TopologySnapshot struct {
+    Generation       uint64
+    domains          map[string]*Domain
+    domainForNode    map[string]string
+    nodeGPUs         map[string]int
+    nodeCapacity     map[string]int
+    spineConnections map[string][]string
+    distances        *topology.DistanceTable
+    hops             *topology.DistanceTable
+    // jobUsage holds, by job and then domain, what a job's pods take there
+    jobUsage map[string]map[string]*JobDomainUsage
+    // healthyNodes and spareClaims are copies of the cache's node health and
+    // spare claims
+    healthyNodes map[string]bool
+    spareClaims  map[string]map[string]bool
+}
+
+// JobDomainUsage is what the GPU pods of one job, bound or assumed, take in a domain
+type JobDomainUsage struct {
+    Nodes map[string]bool
+    GPUs  int
+}
+
+// Snapshot returns a snapshot of the current cache state. The snapshot is
+// rebuilt only when the cache changed since the last call, and reading a
+// current snapshot does not take the cache lock.
+func (tc *TopologyCache) Snapshot() *TopologySnapshot {
+    if snapshot := tc.snapshot.Load(); snapshot != nil && snapshot.Generation == tc.generation.Load() {
+        return snapshot
+    }
+
+    tc.RLock()
+    defer tc.RUnlock()
+
+    snapshot := &TopologySnapshot{
+        Generation:       tc.generation.Load(),
+        domains:          make(map[string]*Domain, len(tc.domains)),
+        domainForNode:    make(map[string]string, len(tc.domainForNode)),
+        nodeGPUs:         make(map[string]int, len(tc.nodeGPUs)),
+        nodeCapacity:     make(map[string]int, len(tc.domainForNode)),
+        spineConnections: make(map[string][]string, len(tc.spineConnections)),
+        distances:        tc.distanceTableLocked(),
+        hops:             tc.hopTableLocked(),
+        jobUsage:         make(map[string]map[string]*JobDomainUsage),
+        healthyNodes:     make(map[string]bool, len(tc.nodeHealth)),
+        spareClaims:      make(map[string]map[string]bool, len(tc.spareClaims)),
+    }
+
+    for name, domain := range tc.domains {
+        copied := *domain
+        copied.Nodes = append([]*v1.Node(nil), domain.Nodes...)
+        snapshot.domains[name] = &copied
+        for _, node := range domain.Nodes {
+            snapshot.nodeCapacity[node.Name] = tc.usableGPUsLocked(node)
+        }
+    }
+    for node, domain := range tc.domainForNode {
+        snapshot.domainForNode[node] = domain
+    }
+    for node, gpus := range tc.nodeGPUs {
+        snapshot.nodeGPUs[node] = gpus
+    }
+    for source, targets := range tc.spineConnections {
+        snapshot.spineConnections[source] = append([]string(nil), targets...)
+    }
+    for node, health := range tc.nodeHealth {
+        if health.healthy {
+            snapshot.healthyNodes[node] = true
+        }
+    }
+    // The cache replaces the claims rather than changing them in place
+    for node, claims := range tc.spareClaims {
+        snapshot.spareClaims[node] = claims
+    }
+    for _, state := range tc.podStates {
+        domainName, exists := tc.domainForNode[state.nodeName]
+        if !exists {
+            continue
+        }
+        jobName := getJobName(state.pod)
+        if snapshot.jobUsage[jobName] == nil {
+            snapshot.jobUsage[jobName] = make(map[string]*JobDomainUsage)
+        }
+        usage := snapshot.jobUsage[jobName][domainName]
+        if usage == nil {
+            usage = &JobDomainUsage{Nodes: make(map[string]bool)}
+            snapshot.jobUsage[jobName][domainName] = usage
+        }
+        usage.Nodes[state.nodeName] = true
+        usage.GPUs += state.gpus
+    }
+
+    tc.snapshot.Store(snapshot)
+    return snapshot
+}
+
+// touchLocked records a cache mutation so the next Snapshot is rebuilt
+func (tc *TopologyCache) touchLocked() {
+    tc.generation.Add(1)
+    tc.lastUpdated = time.Now()
+}
+
+func (s *TopologySnapshot) GetDomainForNode(nodeName string) (*Domain, error) {
+    domainName, exists := s.domainForNode[nodeName]
+    if !exists {
+        return nil, fmt.Errorf("no domain found for node %s", nodeName)
+    }
+    return s.domains[domainName], nil
+}
+
+func (s *TopologySnapshot) GetDomain(domainName string) (*Domain, error) {
+    domain, exists := s.domains[domainName]
+    if !exists {
+        return nil, fmt.Errorf("domain %s not found", domainName)
+    }
+    return domain, nil
+}
+
+func (s *TopologySnapshot) GetConnectedDomains(domainName string) []*Domain {
+    var connected []*Domain
+    for _, name := range s.spineConnections[domainName] {
+        if domain, exists := s.domains[name]; exists {
+            connected = append(connected, domain)
+        }
+    }
+    return connected
+}
+
+func (s *TopologySnapshot) GetDomainDistance(source, target string) (float64, error) {
+    distance, ok := s.distances.Distance(source, target)
+    if !ok {
+        return 0, fmt.Errorf("domain %s is not reachable from %s", target, source)
+    }
+    return distance, nil
+}
+
+// GetDomainHops returns how many switch links separate two domains
+func (s *TopologySnapshot) GetDomainHops(source, target string) (int, error) {
+    hops, ok := s.hops.Distance(source, target)
+    if !ok {
+        return 0, fmt.Errorf("domain %s is not reachable from %s", target, source)
+    }
+    return int(hops), nil
+}
+
+func (s *TopologySnapshot) GetNodeGPUs(nodeName string) int {
+    return s.nodeGPUs[nodeName]
+}
+
+// GetNodeCapacity returns the usable GPUs of a node, excluding unhealthy devices
+func (s *TopologySnapshot) GetNodeCapacity(nodeName string) int {
+    return s.nodeCapacity[nodeName]
+}
+
+func (s *TopologySnapshot) GetAllDomains() []*Domain {
+    domains := make([]*Domain, 0, len(s.domains))
+    for _, domain := range s.domains {
+        domains = append(domains, domain)
+    }
+    return domains
+}
+
+// GetJobDomainUsage returns what a job already takes in a domain; the usage
+// is empty if the job has no pods there
+func (s *TopologySnapshot) GetJobDomainUsage(jobName, domainName string) JobDomainUsage {
+    if usage := s.jobUsage[jobName][domainName]; usage != nil {
+        return *usage
+    }
+    return JobDomainUsage{}
+}
+
+func (s *TopologySnapshot) IsNodeHealthy(nodeName string) bool {
+    return s.healthyNodes[nodeName]
+}
+
+// AdmitsOnSpare reports whether a pod may be placed on a node. An available
+// spare takes no pods and a claimed spare only takes the replacements
+// matched to its claims.
+func (s *TopologySnapshot) AdmitsOnSpare(pod *v1.Pod, nodeName string) bool {
+    claims, exists := s.spareClaims[nodeName]
+    return !exists || claims[string(pod.UID)]
+}
+
+// IsSpareClaimedFor reports whether a node is a spare whose claim the pod was matched to
+func (s *TopologySnapshot) IsSpareClaimedFor(pod *v1.Pod, nodeName string) bool {
+    return s.spareClaims[nodeName][string(pod.UID)]
+}
@@ -1,139 +1,376 @@
-package algorithm
-
-import (
-    "fmt"
-    "sync"
-    "time"
-    v1 "k8s.io/api/core/v1"
-)
-
-type TopologyCache struct {
-    sync.RWMutex
-    nodeCache         *NodeCache
-    domains           map[string]*Domain
-    spineConnections map[string][]string
-    domainForNode    map[string]string
-    lastUpdated      time.Time
-}
-
-func NewTopologyCache(nodeCache *NodeCache) *TopologyCache {
-    return &TopologyCache{
-        nodeCache:        nodeCache,
-        domains:         make(map[string]*Domain),
-        spineConnections: make(map[string][]string),
-        domainForNode:   make(map[string]string),
-        lastUpdated:     time.Now(),
-    }
-}
-
-func (tc *TopologyCache) AddDomain(domain *Domain) error {
-    tc.Lock()
-    defer tc.Unlock()
-
-    if _, exists := tc.domains[domain.Name]; exists {
-        return fmt.Errorf("domain %s already exists", domain.Name)
-    }
-
-    tc.domains[domain.Name] = domain
-    for _, node := range domain.Nodes {
-        tc.domainForNode[node.Name] = domain.Name
-    }
-    tc.lastUpdated = time.Now()
-    return nil
-}
-
-func (tc *TopologyCache) AddNodeToDomain(nodeName, domainName string) error {
-    tc.Lock()
-    defer tc.Unlock()
-
-    domain, exists := tc.domains[domainName]
-    if !exists {
-        return fmt.Errorf("domain %s not found", domainName)
-    }
-
-    node, err := tc.nodeCache.GetNode(nodeName)
-    if err != nil {
-        return fmt.Errorf("node %s not found in node cache", nodeName)
-    }
-
-    domain.Nodes = append(domain.Nodes, node)
-    tc.domainForNode[nodeName] = domainName
-    tc.lastUpdated = time.Now()
-    return nil
-}
-
-func (tc *TopologyCache) RemoveNodeFromDomain(nodeName, domainName string) error {
-    tc.Lock()
-    defer tc.Unlock()
-
-    domain, exists := tc.domains[domainName]
-    if !exists {
-        return fmt.Errorf("domain %s not found", domainName)
-    }
-
-    for i, node := range domain.Nodes {
-        if node.Name == nodeName {
-            domain.Nodes = append(domain.Nodes[:i], domain.Nodes[i+1:]...)
-            delete(tc.domainForNode, nodeName)
-            tc.lastUpdated = time.Now()
-            return nil
-        }
-    }
-    return fmt.Errorf("node %s not found in domain %s", nodeName, domainName)
-}
-
-func (tc *TopologyCache) AddSpineConnection(source, target string) error {
-    tc.Lock()
-    defer tc.Unlock()
-
-    if _, exists := tc.domains[source]; !exists {
-        return fmt.Errorf("source domain %s not found", source)
-    }
-    if _, exists := tc.domains[target]; !exists {
-        return fmt.Errorf("target domain %s not found", target)
-    }
-
-    tc.spineConnections[source] = append(tc.spineConnections[source], target)
-    tc.lastUpdated = time.Now()
-    return nil
-}
-
-func (tc *TopologyCache) GetDomainForNode(nodeName string) (*Domain, error) {
-    tc.RLock()
-    defer tc.RUnlock()
-
-    domainName, exists := tc.domainForNode[nodeName]
-    if !exists {
-        return nil, fmt.Errorf("no domain found for node %s", nodeName)
-    }
-    return tc.domains[domainName], nil
-}
-
-func (tc *TopologyCache) GetConnectedDomains(domainName string) ([]*Domain, error) {
-    tc.RLock()
-    defer tc.RUnlock()
-
-    connections, exists := tc.spineConnections[domainName]
-    if !exists {
-        return nil, fmt.Errorf("domain %s not found", domainName)
-    }
-
-    var connectedDomains []*Domain
-    for _, conn := range connections {
-        if domain, exists := tc.domains[conn]; exists {
-            connectedDomains = append(connectedDomains, domain)
-        }
-    }
-    return connectedDomains, nil
-}
-
-func (tc *TopologyCache) GetAllDomains() []*Domain {
-    tc.RLock()
-    defer tc.RUnlock()
-
-    domains := make([]*Domain, 0, len(tc.domains))
-    for _, domain := range tc.domains {
-        domains = append(domains, domain)
-    }
-    return domains
-}
+package algorithm
+
+import (
+    "fmt"
+    "sync"
+    "sync/atomic"
+    "time"
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/klog/v2"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+    "github.com/nod-ai/topology-aware-scheduler/pkg/utils/topology"
+)
+
+const gpuResourceName = v1.ResourceName("nvidia.com/gpu")
+
+type TopologyCache struct {
+    sync.RWMutex
+    // handlerMu is held for reading by the node and pod informer handlers and
+    // for writing while the auditor heals the cache, so a heal is not
+    // interleaved with informer updates
+    handlerMu         sync.RWMutex
+    nodeCache         *NodeCache
+    domains           map[string]*Domain
+    // spineConnections is derived from links by rebuildConnectionsLocked
+    spineConnections map[string][]string
+    links            map[string]*Link
+    linkOverrides    map[string]LinkOverride
+    domainForNode    map[string]string
+    domainJobs       map[string]map[string]bool
+    podStates        map[string]*podState
+    nodeGPUs         map[string]int
+    // gpuDevices is the per-device health reported for each node
+    gpuDevices       map[string][]topology.GPUDevice
+    // reservedNodes are held back as spares; their GPUs are not in domain capacity
+    reservedNodes    map[string]bool
+    // spareClaims holds, for every spare, the UIDs of the pending
+    // replacements matched to its claims; an available spare has none
+    spareClaims      map[string]map[string]bool
+    // domainLifecycles are declared by DomainConfigs, keyed by leaf or spine
+    domainLifecycles map[string]DomainLifecycle
+    nodeHealth       map[string]*nodeHealth
+    flapWindow       time.Duration
+    healthObserver   HealthObserver
+    assumedPodTTL    time.Duration
+    lastUpdated      time.Time
+    generation       atomic.Uint64
+    snapshot         atomic.Pointer[TopologySnapshot]
+    // distances is cleared whenever links changes
+    distances        atomic.Pointer[topology.DistanceTable]
+    // hops counts links instead of weighing them and is cleared with distances
+    hops             atomic.Pointer[topology.DistanceTable]
+}
+
+func NewTopologyCache(nodeCache *NodeCache) *TopologyCache {
+    return &TopologyCache{
+        nodeCache:        nodeCache,
+        domains:         make(map[string]*Domain),
+        spineConnections: make(map[string][]string),
+        links:            make(map[string]*Link),
+        linkOverrides:    make(map[string]LinkOverride),
+        domainForNode:   make(map[string]string),
+        domainJobs:      make(map[string]map[string]bool),
+        podStates:       make(map[string]*podState),
+        nodeGPUs:        make(map[string]int),
+        gpuDevices:      make(map[string][]topology.GPUDevice),
+        reservedNodes:   make(map[string]bool),
+        spareClaims:     make(map[string]map[string]bool),
+        domainLifecycles: make(map[string]DomainLifecycle),
+        nodeHealth:      make(map[string]*nodeHealth),
+        flapWindow:      DefaultFlapWindow,
+        assumedPodTTL:   DefaultAssumedPodTTL,
+        lastUpdated:     time.Now(),
+    }
+}
+
+// SetAssumedPodTTL sets how long assumed pods are kept without being observed bound
+func (tc *TopologyCache) SetAssumedPodTTL(ttl time.Duration) {
+    tc.Lock()
+    defer tc.Unlock()
+    tc.assumedPodTTL = ttl
+}
+
+func (tc *TopologyCache) AddDomain(domain *Domain) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if _, exists := tc.domains[domain.Name]; exists {
+        return fmt.Errorf("domain %s already exists", domain.Name)
+    }
+
+    tc.domains[domain.Name] = domain
+    for _, node := range domain.Nodes {
+        tc.domainForNode[node.Name] = domain.Name
+    }
+    tc.touchLocked()
+    return nil
+}
+
+func (tc *TopologyCache) AddNodeToDomain(nodeName, domainName string) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    domain, exists := tc.domains[domainName]
+    if !exists {
+        return fmt.Errorf("domain %s not found", domainName)
+    }
+
+    node, err := tc.nodeCache.GetNode(nodeName)
+    if err != nil {
+        return fmt.Errorf("node %s not found in node cache", nodeName)
+    }
+
+    domain.Nodes = append(domain.Nodes, node)
+    tc.domainForNode[nodeName] = domainName
+    tc.recomputeDomainUsageLocked(domain)
+    tc.touchLocked()
+    return nil
+}
+
+func (tc *TopologyCache) RemoveNodeFromDomain(nodeName, domainName string) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    domain, exists := tc.domains[domainName]
+    if !exists {
+        return fmt.Errorf("domain %s not found", domainName)
+    }
+
+    for i, node := range domain.Nodes {
+        if node.Name == nodeName {
+            domain.Nodes = append(domain.Nodes[:i], domain.Nodes[i+1:]...)
+            delete(tc.domainForNode, nodeName)
+            tc.recomputeDomainUsageLocked(domain)
+            tc.touchLocked()
+            return nil
+        }
+    }
+    return fmt.Errorf("node %s not found in domain %s", nodeName, domainName)
+}
+
+// UpsertNode places a node in the leaf domain named by its topology labels,
+// creating the domain if needed. A node whose leaf label changed is moved out
+// of its old domain, and a node without a leaf label is removed from all domains.
+func (tc *TopologyCache) UpsertNode(node *v1.Node) {
+    tc.nodeCache.UpdateNode(node)
+
+    tc.Lock()
+    defer tc.Unlock()
+
+    now := time.Now()
+    tc.recordNodeHealthLocked(node, now)
+    defer tc.observeNodeHealthLocked(node.Name)
+
+    leafName := node.Labels[v1alpha1.LabelLeafDomain]
+    spineName := node.Labels[v1alpha1.LabelSpineDomain]
+
+    if current, exists := tc.domainForNode[node.Name]; exists && current != leafName {
+        tc.removeNodeLocked(node.Name, current)
+        klog.V(2).Infof("Node %s moved from domain %s to %q", node.Name, current, leafName)
+    }
+
+    if leafName == "" {
+        tc.syncNodeUsageLocked(node.Name)
+        tc.touchLocked()
+        return
+    }
+
+    domain, exists := tc.domains[leafName]
+    if !exists {
+        domain = &Domain{
+            ID:   leafName,
+            Name: leafName,
+        }
+        tc.domains[leafName] = domain
+    }
+
+    if domain.SpineSwitch != spineName {
+        tc.setUplinkLocked(domain, spineName)
+    }
+
+    replaced := false
+    for i, existing := range domain.Nodes {
+        if existing.Name == node.Name {
+            domain.Nodes[i] = node
+            replaced = true
+            break
+        }
+    }
+    if !replaced {
+        domain.Nodes = append(domain.Nodes, node)
+    }
+    tc.domainForNode[node.Name] = leafName
+
+    tc.recomputeDomainCapacityLocked(domain)
+    tc.recomputeDomainLifecycleLocked(domain)
+    tc.syncNodeUsageLocked(node.Name)
+    tc.recomputeDomainHealthLocked(domain, now)
+    tc.touchLocked()
+}
+
+// DeleteNode removes a node from both caches
+func (tc *TopologyCache) DeleteNode(nodeName string) {
+    tc.Lock()
+    if domainName, exists := tc.domainForNode[nodeName]; exists {
+        tc.removeNodeLocked(nodeName, domainName)
+    }
+    delete(tc.nodeHealth, nodeName)
+    delete(tc.gpuDevices, nodeName)
+    tc.touchLocked()
+    tc.Unlock()
+
+    if err := tc.nodeCache.RemoveNode(nodeName); err != nil {
+        klog.V(4).Infof("Node %s was not in the node cache: %v", nodeName, err)
+    }
+}
+
+// removeNodeLocked drops a node from a domain and deletes the domain once it is
+// empty and has no jobs left on it
+func (tc *TopologyCache) removeNodeLocked(nodeName, domainName string) {
+    delete(tc.domainForNode, nodeName)
+
+    domain, exists := tc.domains[domainName]
+    if !exists {
+        return
+    }
+
+    for i, node := range domain.Nodes {
+        if node.Name == nodeName {
+            domain.Nodes = append(domain.Nodes[:i], domain.Nodes[i+1:]...)
+            break
+        }
+    }
+    tc.recomputeDomainCapacityLocked(domain)
+    tc.recomputeDomainUsageLocked(domain)
+    tc.recomputeDomainHealthLocked(domain, time.Now())
+
+    if len(domain.Nodes) == 0 && len(tc.domainJobs[domainName]) == 0 {
+        delete(tc.domains, domainName)
+        tc.removeDomainLinksLocked(domainName)
+    }
+}
+
+// nodeGPUCapacity returns the allocatable GPUs of a node, falling back to the GPU count label
+func nodeGPUCapacity(node *v1.Node) int {
+    if gpus, ok := node.Status.Allocatable[gpuResourceName]; ok {
+        return int(gpus.Value())
+    }
+    info, err := topology.ExtractNodeGPUInfo(node)
+    if err != nil {
+        return 0
+    }
+    return info.TotalGPUs
+}
+
+func (tc *TopologyCache) GetDomainForNode(nodeName string) (*Domain, error) {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    domainName, exists := tc.domainForNode[nodeName]
+    if !exists {
+        return nil, fmt.Errorf("no domain found for node %s", nodeName)
+    }
+    return tc.domains[domainName], nil
+}
+
+func (tc *TopologyCache) GetConnectedDomains(domainName string) ([]*Domain, error) {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    connections, exists := tc.spineConnections[domainName]
+    if !exists {
+        return nil, fmt.Errorf("domain %s not found", domainName)
+    }
+
+    var connectedDomains []*Domain
+    for _, conn := range connections {
+        if domain, exists := tc.domains[conn]; exists {
+            connectedDomains = append(connectedDomains, domain)
+        }
+    }
+    return connectedDomains, nil
+}
+
+func (tc *TopologyCache) GetAllDomains() []*Domain {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    domains := make([]*Domain, 0, len(tc.domains))
+    for _, domain := range tc.domains {
+        domains = append(domains, domain)
+    }
+    return domains
+}
+
+func (tc *TopologyCache) AddJobToDomain(jobName, domainName string) error {
+    tc.Lock()
+    defer tc.Unlock()
+
+    if _, exists := tc.domains[domainName]; !exists {
+        return fmt.Errorf("domain %s not found", domainName)
+    }
+
+    if tc.domainJobs[domainName] == nil {
+        tc.domainJobs[domainName] = make(map[string]bool)
+    }
+    tc.domainJobs[domainName][jobName] = true
+    return nil
+}
+
+func (tc *TopologyCache) RemoveJobFromDomain(jobName, domainName string) {
+    tc.Lock()
+    defer tc.Unlock()
+    tc.removeJobFromDomainLocked(jobName, domainName)
+}
+
+func (tc *TopologyCache) removeJobFromDomainLocked(jobName, domainName string) {
+    delete(tc.domainJobs[domainName], jobName)
+    if len(tc.domainJobs[domainName]) == 0 {
+        delete(tc.domainJobs, domainName)
+    }
+}
+
+// Export returns a point-in-time copy of the domains, spine connections,
+// node membership and placed jobs known to the cache.
+func (tc *TopologyCache) Export() *TopologyExport {
+    tc.RLock()
+    defer tc.RUnlock()
+
+    export := &TopologyExport{
+        Domains:     make([]DomainExport, 0, len(tc.domains)),
+        LastUpdated: tc.lastUpdated,
+    }
+
+    for _, domain := range tc.domains {
+        de := DomainExport{
+            Name:          domain.Name,
+            LeafSwitch:    domain.LeafSwitch,
+            SpineSwitch:   domain.SpineSwitch,
+            TotalGPUs:     domain.TotalGPUs,
+            UsedGPUs:      domain.UsedGPUs,
+            State:         domainHealthState(domain),
+            Health:        domain.Health,
+            RecentFlaps:   domain.RecentFlaps,
+            LinkState:     string(domain.LinkState),
+            Lifecycle:     string(domain.Lifecycle),
+            SafeToService: domain.Lifecycle.Drains() && domain.UsedGPUs == 0,
+        }
+
+        for _, node := range domain.Nodes {
+            healthy := false
+            if state, exists := tc.nodeHealth[node.Name]; exists {
+                healthy = state.healthy
+            }
+            de.Nodes = append(de.Nodes, NodeExport{Name: node.Name, Healthy: healthy})
+        }
+
+        for job := range tc.domainJobs[domain.Name] {
+            de.Jobs = append(de.Jobs, job)
+        }
+        export.Domains = append(export.Domains, de)
+    }
+
+    for source, targets := range tc.spineConnections {
+        for _, target := range targets {
+            export.SpineConnections = append(export.SpineConnections, ConnectionExport{
+                Source: source,
+                Target: target,
+            })
+        }
+    }
+    export.Links = tc.linksLocked()
+
+    export.sort()
+    return export
+}
@@ -0,0 +1,220 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    "k8s.io/kubernetes/pkg/scheduler/framework"
+)
+
+const cycleStateKey framework.StateKey = Name + "/cycle"
+
+// cycleState is computed once in PreFilter and shared by every extension point
+// of the scheduling cycle, so Filter and Score see the same topology
+type cycleState struct {
+    snapshot *TopologySnapshot
+    gpuReq   *GPURequirements
+    // config is the scheduler config in effect when the cycle started
+    config *SchedulerConfig
+    // preferredDomain came from the pod annotation or, if fromHint is set,
+    // from a recovery hint that PreBind records on the pod
+    preferredDomain string
+    fromHint        bool
+    // rejections counts the nodes Filter rejected, by reason
+    rejections *filterRejections
+    // candidates keeps how each domain scored, for the decision log
+    candidates *domainCandidates
+    started    time.Time
+    // span covers the cycle from PreFilter until the pod is bound or the
+    // cycle fails, and parents the span of every extension point
+    span    trace.Span
+    endOnce sync.Once
+}
+
+// Clone shares the immutable snapshot, requirements and config, but starts
+// fresh rejections and candidates, so that Filter and Score runs on a clone,
+// such as preemption's, do not count towards the cycle's decision
+func (s *cycleState) Clone() framework.StateData {
+    return &cycleState{
+        snapshot:        s.snapshot,
+        gpuReq:          s.gpuReq,
+        config:          s.config,
+        preferredDomain: s.preferredDomain,
+        fromHint:        s.fromHint,
+        rejections:      newFilterRejections(),
+        candidates:      newDomainCandidates(),
+        started:         s.started,
+        span:            s.span,
+    }
+}
+
+// startSpan starts the span of an extension point within the cycle's span
+func (s *cycleState) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+    return tracer.Start(trace.ContextWithSpan(ctx, s.span), name, trace.WithAttributes(attrs...))
+}
+
+// endCycle ends the cycle's span. A cycle failed by another plugin between
+// Score and Reserve never reaches an extension point that ends it, so its
+// span is not exported.
+func (s *cycleState) endCycle(err error) {
+    s.endOnce.Do(func() {
+        s.summarizeSpan()
+        endSpan(s.span, err)
+    })
+}
+
+// summarizeSpan records on the cycle's span how many nodes Filter saw and
+// rejected, with an event per rejection reason and per scored domain
+func (s *cycleState) summarizeSpan() {
+    if !s.span.IsRecording() {
+        return
+    }
+    counts := s.rejections.counts()
+    rejected := 0
+    for reason, count := range counts {
+        rejected += count
+        s.span.AddEvent("filter rejected", trace.WithAttributes(
+            attribute.String("reason", reason),
+            attribute.Int("nodes", count),
+        ))
+    }
+    candidates := s.candidates.list()
+    for _, candidate := range candidates {
+        s.span.AddEvent("domain scored", trace.WithAttributes(
+            attribute.String("domain", candidate.Domain),
+            attribute.Int("nodes", candidate.Nodes),
+            attribute.Float64("score", candidate.Score),
+        ))
+    }
+    s.span.SetAttributes(
+        attribute.Int("filter.nodes", s.rejections.filtered()),
+        attribute.Int("filter.rejected", rejected),
+        attribute.Int("score.domains", len(candidates)),
+    )
+}
+
+type filterRejections struct {
+    sync.Mutex
+    reasons map[string]int
+    // nodes is how many nodes Filter saw, rejected or not
+    nodes int
+}
+
+func newFilterRejections() *filterRejections {
+    return &filterRejections{reasons: make(map[string]int)}
+}
+
+// observe counts a filtered node and, if it was rejected, the reason
+func (r *filterRejections) observe(reason string, rejected bool) {
+    r.Lock()
+    defer r.Unlock()
+    r.nodes++
+    if rejected {
+        r.reasons[reason]++
+    }
+}
+
+func (r *filterRejections) filtered() int {
+    r.Lock()
+    defer r.Unlock()
+    return r.nodes
+}
+
+// summary lists the reasons, most frequent first
+func (r *filterRejections) summary() string {
+    r.Lock()
+    defer r.Unlock()
+
+    reasons := make([]string, 0, len(r.reasons))
+    for reason := range r.reasons {
+        reasons = append(reasons, reason)
+    }
+    sort.Slice(reasons, func(i, j int) bool {
+        if r.reasons[reasons[i]] != r.reasons[reasons[j]] {
+            return r.reasons[reasons[i]] > r.reasons[reasons[j]]
+        }
+        return reasons[i] < reasons[j]
+    })
+    parts := make([]string, 0, len(reasons))
+    for _, reason := range reasons {
+        parts = append(parts, fmt.Sprintf("%d node(s): %s", r.reasons[reason], reason))
+    }
+    return strings.Join(parts, "; ")
+}
+
+// counts returns a copy of the rejection counts
+func (r *filterRejections) counts() map[string]int {
+    r.Lock()
+    defer r.Unlock()
+
+    counts := make(map[string]int, len(r.reasons))
+    for reason, count := range r.reasons {
+        counts[reason] = count
+    }
+    return counts
+}
+
+// domainCandidates keeps the best scoring node of each domain Score saw
+type domainCandidates struct {
+    sync.Mutex
+    domains map[string]*DomainCandidate
+}
+
+func newDomainCandidates() *domainCandidates {
+    return &domainCandidates{domains: make(map[string]*DomainCandidate)}
+}
+
+func (c *domainCandidates) observe(candidate DomainCandidate) {
+    c.Lock()
+    defer c.Unlock()
+
+    best, exists := c.domains[candidate.Domain]
+    if !exists {
+        candidate.Nodes = 1
+        c.domains[candidate.Domain] = &candidate
+        return
+    }
+    candidate.Nodes = best.Nodes + 1
+    if candidate.Score <= best.Score {
+        best.Nodes = candidate.Nodes
+        return
+    }
+    *best = candidate
+}
+
+// list returns the candidates, best scoring first
+func (c *domainCandidates) list() []DomainCandidate {
+    c.Lock()
+    defer c.Unlock()
+
+    list := make([]DomainCandidate, 0, len(c.domains))
+    for _, candidate := range c.domains {
+        list = append(list, *candidate)
+    }
+    sort.Slice(list, func(i, j int) bool {
+        if list[i].Score != list[j].Score {
+            return list[i].Score > list[j].Score
+        }
+        return list[i].Domain < list[j].Domain
+    })
+    return list
+}
+
+func getCycleState(state *framework.CycleState) (*cycleState, error) {
+    data, err := state.Read(cycleStateKey)
+    if err != nil {
+        return nil, fmt.Errorf("reading %q from cycle state: %v", cycleStateKey, err)
+    }
+
+    s, ok := data.(*cycleState)
+    if !ok {
+        return nil, fmt.Errorf("%+v convert to topology cycleState error", data)
+    }
+    return s, nil
+}
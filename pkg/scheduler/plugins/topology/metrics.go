@@ -1,184 +1,445 @@
-go
-package algorithm
-
-import (
-    "sync"
-    "time"
-    "github.com/prometheus/client_golang/prometheus"
-    "github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-type MetricsCollector struct {
-    mu sync.RWMutex
-
-    // Scheduling metrics
-    schedulingLatency *prometheus.HistogramVec
-    schedulingErrors *prometheus.CounterVec
-    schedulingAttempts *prometheus.CounterVec
-    schedulingSuccess *prometheus.CounterVec
-
-    // Domain metrics
-    domainUtilization *prometheus.GaugeVec
-    gpuUtilization *prometheus.GaugeVec
-    domainFragmentation *prometheus.GaugeVec
-
-    // Node metrics
-    nodeGPUAllocation *prometheus.GaugeVec
-    nodeHealthStatus *prometheus.GaugeVec
-
-    // Placement metrics
-    placementDecisions *prometheus.CounterVec
-    placementScores *prometheus.HistogramVec
-}
-
-func NewMetricsCollector() *MetricsCollector {
-    return &MetricsCollector{
-        schedulingLatency: promauto.NewHistogramVec(
-            prometheus.HistogramOpts{
-                Name: "topology_scheduler_latency_seconds",
-                Help: "Time taken for scheduling decisions",
-                Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
-            },
-            []string{"strategy"},
-        ),
-
-        schedulingErrors: promauto.NewCounterVec(
-            prometheus.CounterOpts{
-                Name: "topology_scheduler_errors_total",
-                Help: "Total number of scheduling errors by type",
-            },
-            []string{"type"},
-        ),
-
-        schedulingAttempts: promauto.NewCounterVec(
-            prometheus.CounterOpts{
-                Name: "topology_scheduler_attempts_total",
-                Help: "Number of scheduling attempts",
-            },
-            []string{"strategy"},
-        ),
-
-        schedulingSuccess: promauto.NewCounterVec(
-            prometheus.CounterOpts{
-                Name: "topology_scheduler_success_total",
-                Help: "Number of successful schedules",
-            },
-            []string{"strategy"},
-        ),
-
-        domainUtilization: promauto.NewGaugeVec(
-            prometheus.GaugeOpts{
-                Name: "topology_domain_utilization_ratio",
-                Help: "Current utilization ratio of domains",
-            },
-            []string{"domain"},
-        ),
-
-        gpuUtilization: promauto.NewGaugeVec(
-            prometheus.GaugeOpts{
-                Name: "topology_gpu_utilization_ratio",
-                Help: "GPU utilization ratio by domain",
-            },
-            []string{"domain"},
-        ),
-
-        domainFragmentation: promauto.NewGaugeVec(
-            prometheus.GaugeOpts{
-                Name: "topology_domain_fragmentation_ratio",
-                Help: "Fragmentation ratio of domains",
-            },
-            []string{"domain"},
-        ),
-
-        nodeGPUAllocation: promauto.NewGaugeVec(
-            prometheus.GaugeOpts{
-                Name: "topology_node_gpu_allocated",
-                Help: "Number of GPUs allocated per node",
-            },
-            []string{"node", "domain"},
-        ),
-
-        nodeHealthStatus: promauto.NewGaugeVec(
-            prometheus.GaugeOpts{
-                Name: "topology_node_health_status",
-                Help: "Health status of nodes (1 for healthy, 0 for unhealthy)",
-            },
-            []string{"node", "domain"},
-        ),
-
-        placementDecisions: promauto.NewCounterVec(
-            prometheus.CounterOpts{
-                Name: "topology_placement_decisions_total",
-                Help: "Number of placement decisions by type",
-            },
-            []string{"strategy", "result"},
-        ),
-
-        placementScores: promauto.NewHistogramVec(
-            prometheus.HistogramOpts{
-                Name: "topology_placement_scores",
-                Help: "Distribution of placement scores",
-                Buckets: prometheus.LinearBuckets(0, 0.1, 10),
-            },
-            []string{"strategy"},
-        ),
-    }
-}
-
-func (mc *MetricsCollector) ObserveSchedulingLatency(duration time.Duration, strategy string) {
-    mc.schedulingLatency.WithLabelValues(strategy).Observe(duration.Seconds())
-}
-
-func (mc *MetricsCollector) IncSchedulingError(errorType string) {
-    mc.schedulingErrors.WithLabelValues(errorType).Inc()
-}
-
-func (mc *MetricsCollector) ObservePlacementResult(result *PlacementResult) {
-    if result == nil {
-        return
-    }
-    
-    mc.placementDecisions.WithLabelValues(string(result.Strategy), "success").Inc()
-    mc.placementScores.WithLabelValues(string(result.Strategy)).Observe(result.Score)
-}
-
-func (mc *MetricsCollector) UpdateDomainMetrics(domain *Domain) {
-    mc.mu.Lock()
-    defer mc.mu.Unlock()
-
-    utilization := float64(domain.UsedGPUs) / float64(domain.TotalGPUs)
-    mc.domainUtilization.WithLabelValues(domain.Name).Set(utilization)
-    
-    gpuUtil := float64(domain.UsedGPUs) / float64(len(domain.Nodes)*4) // Assuming 4 GPUs per node
-    mc.gpuUtilization.WithLabelValues(domain.Name).Set(gpuUtil)
-    
-    fragmentation := calculateFragmentation(domain)
-    mc.domainFragmentation.WithLabelValues(domain.Name).Set(fragmentation)
-}
-
-func (mc *MetricsCollector) UpdateNodeMetrics(node string, domain string, gpuCount int, healthy bool) {
-    mc.nodeGPUAllocation.WithLabelValues(node, domain).Set(float64(gpuCount))
-    
-    healthStatus := 0.0
-    if healthy {
-        healthStatus = 1.0
-    }
-    mc.nodeHealthStatus.WithLabelValues(node, domain).Set(healthStatus)
-}
-
-func calculateFragmentation(domain *Domain) float64 {
-    if domain.TotalGPUs == 0 {
-        return 0.0
-    }
-    
-    // Simple fragmentation metric: ratio of partially used nodes to total nodes
-    partialNodes := 0
-    for _, node := range domain.Nodes {
-        gpusUsed := domain.UsedGPUs // This should be per-node in real implementation
-        if gpusUsed > 0 && gpusUsed < 4 { // Assuming 4 GPUs per node
-            partialNodes++
-        }
-    }
-    
-    return float64(partialNodes) / float64(len(domain.Nodes))
-}
+go
+package algorithm
+
+import (
+    "sync"
+    "time"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type MetricsCollector struct {
+    mu sync.RWMutex
+
+    // Scheduling metrics
+    schedulingLatency *prometheus.HistogramVec
+    schedulingErrors *prometheus.CounterVec
+    schedulingAttempts *prometheus.CounterVec
+    schedulingSuccess *prometheus.CounterVec
+
+    // Domain metrics
+    domainUtilization *prometheus.GaugeVec
+    gpuUtilization *prometheus.GaugeVec
+    domainFragmentation *prometheus.GaugeVec
+
+    // Node metrics
+    nodeGPUAllocation *prometheus.GaugeVec
+    nodeHealthStatus *prometheus.GaugeVec
+    domainHealth *prometheus.GaugeVec
+    domainFlaps *prometheus.GaugeVec
+
+    // Placement metrics
+    placementDecisions *prometheus.CounterVec
+    placementScores *prometheus.HistogramVec
+
+    // Config metrics
+    configGeneration prometheus.Gauge
+    configLastReload prometheus.Gauge
+    configReloads *prometheus.CounterVec
+
+    // Cache audit metrics
+    auditRuns *prometheus.CounterVec
+    auditMismatches *prometheus.CounterVec
+    auditLastMismatches *prometheus.GaugeVec
+    auditHeals prometheus.Counter
+
+    // Recovery metrics
+    recoveryMigrations *prometheus.CounterVec
+    recoveryPaused prometheus.Gauge
+    nodeFailures *prometheus.CounterVec
+    quarantinedNodes prometheus.Gauge
+
+    // GPU health metrics
+    unhealthyGPUs prometheus.Gauge
+    gpuHealthScrapes *prometheus.CounterVec
+
+    // Spare pool metrics
+    spareNodes *prometheus.GaugeVec
+
+    // Domain lifecycle metrics
+    domainLifecycle *prometheus.GaugeVec
+    drainRemaining *prometheus.GaugeVec
+}
+
+func NewMetricsCollector() *MetricsCollector {
+    return &MetricsCollector{
+        schedulingLatency: promauto.NewHistogramVec(
+            prometheus.HistogramOpts{
+                Name: "topology_scheduler_latency_seconds",
+                Help: "Time taken for scheduling decisions",
+                Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+            },
+            []string{"strategy"},
+        ),
+
+        schedulingErrors: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_errors_total",
+                Help: "Total number of scheduling errors by type",
+            },
+            []string{"type"},
+        ),
+
+        schedulingAttempts: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_attempts_total",
+                Help: "Number of scheduling attempts",
+            },
+            []string{"strategy"},
+        ),
+
+        schedulingSuccess: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_success_total",
+                Help: "Number of successful schedules",
+            },
+            []string{"strategy"},
+        ),
+
+        domainUtilization: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_domain_utilization_ratio",
+                Help: "Current utilization ratio of domains",
+            },
+            []string{"domain"},
+        ),
+
+        gpuUtilization: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_gpu_utilization_ratio",
+                Help: "GPU utilization ratio by domain",
+            },
+            []string{"domain"},
+        ),
+
+        domainFragmentation: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_domain_fragmentation_ratio",
+                Help: "Fragmentation ratio of domains",
+            },
+            []string{"domain"},
+        ),
+
+        nodeGPUAllocation: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_node_gpu_allocated",
+                Help: "Number of GPUs allocated per node",
+            },
+            []string{"node", "domain"},
+        ),
+
+        nodeHealthStatus: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_node_health_status",
+                Help: "Health status of nodes (1 for healthy, 0 for unhealthy)",
+            },
+            []string{"node", "domain"},
+        ),
+
+        domainHealth: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_domain_health_ratio",
+                Help: "Fraction of healthy nodes in each domain",
+            },
+            []string{"domain"},
+        ),
+
+        domainFlaps: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_domain_recent_flaps",
+                Help: "Node health transitions in each domain within the flap window",
+            },
+            []string{"domain"},
+        ),
+
+        placementDecisions: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_placement_decisions_total",
+                Help: "Number of placement decisions by type",
+            },
+            []string{"strategy", "result"},
+        ),
+
+        placementScores: promauto.NewHistogramVec(
+            prometheus.HistogramOpts{
+                Name: "topology_placement_scores",
+                Help: "Distribution of placement scores",
+                Buckets: prometheus.LinearBuckets(0, 0.1, 10),
+            },
+            []string{"strategy"},
+        ),
+
+        configGeneration: promauto.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_config_generation",
+                Help: "Generation of the scheduler config currently in effect",
+            },
+        ),
+
+        configLastReload: promauto.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_config_last_reload_timestamp_seconds",
+                Help: "Unix time the current scheduler config was applied",
+            },
+        ),
+
+        configReloads: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_config_reloads_total",
+                Help: "Number of scheduler config reloads by source and result",
+            },
+            []string{"source", "result"},
+        ),
+
+        auditRuns: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_cache_audit_runs_total",
+                Help: "Number of cache consistency audits by result",
+            },
+            []string{"result"},
+        ),
+
+        auditMismatches: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_cache_audit_mismatches_total",
+                Help: "Number of mismatches found between in-memory caches and the API server",
+            },
+            []string{"cache", "kind"},
+        ),
+
+        auditLastMismatches: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_cache_audit_last_mismatches",
+                Help: "Mismatches found by the most recent audit per cache",
+            },
+            []string{"cache"},
+        ),
+
+        auditHeals: promauto.NewCounter(
+            prometheus.CounterOpts{
+                Name: "topology_cache_audit_heals_total",
+                Help: "Number of times the auditor rebuilt caches from API server state",
+            },
+        ),
+
+        recoveryMigrations: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_recovery_migrations_total",
+                Help: "Number of pod migrations attempted by recovery by result",
+            },
+            []string{"result"},
+        ),
+
+        recoveryPaused: promauto.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_recovery_paused",
+                Help: "1 while the recovery circuit breaker has paused automatic recovery",
+            },
+        ),
+
+        nodeFailures: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_node_failures_total",
+                Help: "Number of nodes declared failed by reason",
+            },
+            []string{"reason"},
+        ),
+
+        quarantinedNodes: promauto.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_quarantined_nodes",
+                Help: "Number of nodes quarantined for flapping",
+            },
+        ),
+
+        unhealthyGPUs: promauto.NewGauge(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_unhealthy_gpus",
+                Help: "Number of GPUs reported unhealthy by the GPU health sources",
+            },
+        ),
+
+        gpuHealthScrapes: promauto.NewCounterVec(
+            prometheus.CounterOpts{
+                Name: "topology_scheduler_gpu_health_scrapes_total",
+                Help: "Number of GPU health source reads by format and result",
+            },
+            []string{"format", "result"},
+        ),
+
+        spareNodes: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_spare_nodes",
+                Help: "Spare nodes per pool by state: available, claimed by recovery or awaiting backfill",
+            },
+            []string{"pool", "state"},
+        ),
+
+        domainLifecycle: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_domain_lifecycle",
+                Help: "Set to 1 for the lifecycle each domain is in",
+            },
+            []string{"domain", "lifecycle"},
+        ),
+
+        drainRemaining: promauto.NewGaugeVec(
+            prometheus.GaugeOpts{
+                Name: "topology_scheduler_drain_remaining_pods",
+                Help: "GPU pods left to move out of each draining domain",
+            },
+            []string{"domain"},
+        ),
+    }
+}
+
+func (mc *MetricsCollector) ObserveSchedulingLatency(duration time.Duration, strategy string) {
+    mc.schedulingLatency.WithLabelValues(strategy).Observe(duration.Seconds())
+}
+
+func (mc *MetricsCollector) IncSchedulingError(errorType string) {
+    mc.schedulingErrors.WithLabelValues(errorType).Inc()
+}
+
+func (mc *MetricsCollector) ObservePlacementResult(result *PlacementResult) {
+    if result == nil {
+        return
+    }
+    
+    mc.placementDecisions.WithLabelValues(string(result.Strategy), "success").Inc()
+    mc.placementScores.WithLabelValues(string(result.Strategy)).Observe(result.Score)
+}
+
+func (mc *MetricsCollector) UpdateDomainMetrics(domain *Domain) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    utilization := float64(domain.UsedGPUs) / float64(domain.TotalGPUs)
+    mc.domainUtilization.WithLabelValues(domain.Name).Set(utilization)
+    
+    gpuUtil := float64(domain.UsedGPUs) / float64(len(domain.Nodes)*4) // Assuming 4 GPUs per node
+    mc.gpuUtilization.WithLabelValues(domain.Name).Set(gpuUtil)
+    
+    fragmentation := calculateFragmentation(domain)
+    mc.domainFragmentation.WithLabelValues(domain.Name).Set(fragmentation)
+}
+
+func (mc *MetricsCollector) UpdateNodeMetrics(node string, domain string, gpuCount int, healthy bool) {
+    mc.nodeGPUAllocation.WithLabelValues(node, domain).Set(float64(gpuCount))
+    
+    healthStatus := 0.0
+    if healthy {
+        healthStatus = 1.0
+    }
+    mc.nodeHealthStatus.WithLabelValues(node, domain).Set(healthStatus)
+}
+
+func (mc *MetricsCollector) UpdateDomainHealth(domain string, health float64, flaps int) {
+    mc.domainHealth.WithLabelValues(domain).Set(health)
+    mc.domainFlaps.WithLabelValues(domain).Set(float64(flaps))
+}
+
+func (mc *MetricsCollector) ObserveConfigReload(source string, result string) {
+    mc.configReloads.WithLabelValues(source, result).Inc()
+}
+
+func (mc *MetricsCollector) SetConfigGeneration(generation int64) {
+    mc.configGeneration.Set(float64(generation))
+    mc.configLastReload.SetToCurrentTime()
+}
+
+func (mc *MetricsCollector) ObserveCacheAudit(mismatches []AuditMismatch, result string) {
+    mc.auditRuns.WithLabelValues(result).Inc()
+    if result != "success" {
+        return
+    }
+
+    perCache := map[string]int{
+        AuditCacheTopology:  0,
+        AuditCacheNode:      0,
+        AuditCacheScheduler: 0,
+        AuditCacheDomain:    0,
+    }
+    for _, m := range mismatches {
+        mc.auditMismatches.WithLabelValues(m.Cache, m.Kind).Inc()
+        perCache[m.Cache]++
+    }
+    for cache, count := range perCache {
+        mc.auditLastMismatches.WithLabelValues(cache).Set(float64(count))
+    }
+}
+
+func (mc *MetricsCollector) IncCacheHeal() {
+    mc.auditHeals.Inc()
+}
+
+func (mc *MetricsCollector) IncRecoveryMigration(result string) {
+    mc.recoveryMigrations.WithLabelValues(result).Inc()
+}
+
+func (mc *MetricsCollector) IncNodeFailure(reason string) {
+    mc.nodeFailures.WithLabelValues(reason).Inc()
+}
+
+func (mc *MetricsCollector) SetQuarantinedNodes(count int) {
+    mc.quarantinedNodes.Set(float64(count))
+}
+
+func (mc *MetricsCollector) SetUnhealthyGPUs(count int) {
+    mc.unhealthyGPUs.Set(float64(count))
+}
+
+// SetSparePools replaces the spare pool occupancy, keyed by pool then state
+func (mc *MetricsCollector) SetSparePools(pools map[string]map[string]int) {
+    mc.spareNodes.Reset()
+    for pool, states := range pools {
+        for state, count := range states {
+            mc.spareNodes.WithLabelValues(pool, state).Set(float64(count))
+        }
+    }
+}
+
+// SetDomainLifecycles replaces the lifecycle of every domain
+func (mc *MetricsCollector) SetDomainLifecycles(lifecycles map[string]DomainLifecycle) {
+    mc.domainLifecycle.Reset()
+    for domain, lifecycle := range lifecycles {
+        mc.domainLifecycle.WithLabelValues(domain, string(lifecycle)).Set(1)
+    }
+}
+
+// SetDrainRemaining replaces the pods left to move, keyed by draining domain
+func (mc *MetricsCollector) SetDrainRemaining(remaining map[string]int) {
+    mc.drainRemaining.Reset()
+    for domain, count := range remaining {
+        mc.drainRemaining.WithLabelValues(domain).Set(float64(count))
+    }
+}
+
+func (mc *MetricsCollector) IncGPUHealthScrape(format, result string) {
+    mc.gpuHealthScrapes.WithLabelValues(format, result).Inc()
+}
+
+func (mc *MetricsCollector) SetRecoveryPaused(paused bool) {
+    value := 0.0
+    if paused {
+        value = 1.0
+    }
+    mc.recoveryPaused.Set(value)
+}
+
+func calculateFragmentation(domain *Domain) float64 {
+    if domain.TotalGPUs == 0 {
+        return 0.0
+    }
+    
+    // Simple fragmentation metric: ratio of partially used nodes to total nodes
+    partialNodes := 0
+    for _, node := range domain.Nodes {
+        gpusUsed := domain.UsedGPUs // This should be per-node in real implementation
+        if gpusUsed > 0 && gpusUsed < 4 { // Assuming 4 GPUs per node
+            partialNodes++
+        }
+    }
+    
+    return float64(partialNodes) / float64(len(domain.Nodes))
+}
@@ -1,92 +1,479 @@
-package algorithm
-
-import (
-    "context"
-    "fmt"
-    v1 "k8s.io/api/core/v1"
-    "k8s.io/apimachinery/pkg/runtime"
-    "k8s.io/kubernetes/pkg/scheduler/framework"
-)
-
-type TopologySchedulerPlugin struct {
-    handle    framework.Handle
-    scheduler *TopologyScheduler
-}
-
-const (
-    Name = "topology-aware-scheduler"
-)
-
-var _ framework.FilterPlugin = &TopologySchedulerPlugin{}
-var _ framework.ScorePlugin = &TopologySchedulerPlugin{}
-
-func New(obj runtime.Object, h framework.Handle) (framework.Plugin, error) {
-    cache := NewTopologyCache(NewNodeCache())
-    scheduler := NewTopologyScheduler(cache)
-    
-    return &TopologySchedulerPlugin{
-        handle:    h,
-        scheduler: scheduler,
-    }, nil
-}
-
-func (tp *TopologySchedulerPlugin) Name() string {
-    return Name
-}
-
-func (tp *TopologySchedulerPlugin) Filter(
-    ctx context.Context,
-    state *framework.CycleState,
-    pod *v1.Pod,
-    nodeInfo *framework.NodeInfo,
-) *framework.Status {
-    if nodeInfo.Node() == nil {
-        return framework.NewStatus(framework.Error, "node not found")
-    }
-
-    gpuReq, err := tp.scheduler.getGPURequirements(pod)
-    if err != nil {
-        return framework.NewStatus(framework.Unschedulable, 
-            fmt.Sprintf("failed to get GPU requirements: %v", err))
-    }
-
-    domain, err := tp.scheduler.cache.GetDomainForNode(nodeInfo.Node().Name)
-    if err != nil {
-        return framework.NewStatus(framework.Error, 
-            fmt.Sprintf("failed to get domain: %v", err))
-    }
-
-    if !tp.scheduler.isDomainEligible(domain, gpuReq) {
-        return framework.NewStatus(framework.Unschedulable,
-            "node's domain does not meet GPU requirements")
-    }
-
-    return framework.NewStatus(framework.Success, "")
-}
-
-func (tp *TopologySchedulerPlugin) Score(
-    ctx context.Context,
-    state *framework.CycleState,
-    pod *v1.Pod,
-    nodeName string,
-) (int64, *framework.Status) {
-    nodeInfo, err := tp.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
-    if err != nil {
-        return 0, framework.NewStatus(framework.Error,
-            fmt.Sprintf("failed to get node info: %v", err))
-    }
-
-    gpuReq, err := tp.scheduler.getGPURequirements(pod)
-    if err != nil {
-        return 0, framework.NewStatus(framework.Error,
-            fmt.Sprintf("failed to get GPU requirements: %v", err))
-    }
-
-    domain, err := tp.scheduler.cache.GetDomainForNode(nodeName)
-    if err != nil {
-        return 0, framework.NewStatus(framework.Error,
-            fmt.Sprintf("failed to get domain: %v", err))
-    }
-
-    score := tp.scheduler.calculateDomainScore(domain, gpuReq)
-    return int64(score * 100), framework.NewStatus(framework.Success,
+package algorithm
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+    v1 "k8s.io/api/core/v1"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/runtime"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/apimachinery/pkg/util/wait"
+    "k8s.io/client-go/tools/events"
+    "k8s.io/klog/v2"
+    "k8s.io/kubernetes/pkg/scheduler/framework"
+    frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+type TopologySchedulerPlugin struct {
+    handle    framework.Handle
+    scheduler *TopologyScheduler
+}
+
+const (
+    Name = "topology-aware-scheduler"
+)
+
+var _ framework.PreFilterPlugin = &TopologySchedulerPlugin{}
+var _ framework.FilterPlugin = &TopologySchedulerPlugin{}
+var _ framework.PostFilterPlugin = &TopologySchedulerPlugin{}
+var _ framework.ScorePlugin = &TopologySchedulerPlugin{}
+var _ framework.ReservePlugin = &TopologySchedulerPlugin{}
+var _ framework.PreBindPlugin = &TopologySchedulerPlugin{}
+var _ framework.PostBindPlugin = &TopologySchedulerPlugin{}
+
+// NewFactory returns a plugin factory that schedules with ts. The scheduler
+// binary registers it so the plugin shares its cache, config, link state,
+// device health, spares and recovery hints with the components that keep
+// them current.
+func NewFactory(ts *TopologyScheduler) frameworkruntime.PluginFactory {
+    return func(obj runtime.Object, h framework.Handle) (framework.Plugin, error) {
+        return &TopologySchedulerPlugin{
+            handle:    h,
+            scheduler: ts,
+        }, nil
+    }
+}
+
+// New builds the plugin with a cache and scheduler of its own, for a
+// kube-scheduler that registers it out of tree. Such a plugin only sees the
+// default config and the state it derives from the informers.
+func New(obj runtime.Object, h framework.Handle) (framework.Plugin, error) {
+    cache := NewTopologyCache(NewNodeCache())
+    cache.AddNodeEventHandler(h.SharedInformerFactory().Core().V1().Nodes())
+    cache.AddPodEventHandler(h.SharedInformerFactory().Core().V1().Pods())
+    go cache.RunAssumedPodCleanup(wait.NeverStop)
+    go cache.RunFlapExpiry(wait.NeverStop)
+    scheduler := NewTopologyScheduler(cache)
+    scheduler.SetEventRecorder(frameworkRecorder{h.EventRecorder()})
+    go scheduler.GetSparePool().Run(wait.NeverStop)
+    
+    return &TopologySchedulerPlugin{
+        handle:    h,
+        scheduler: scheduler,
+    }, nil
+}
+
+func (tp *TopologySchedulerPlugin) Name() string {
+    return Name
+}
+
+// PreFilter takes the topology snapshot used by the rest of the cycle
+func (tp *TopologySchedulerPlugin) PreFilter(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+) (*framework.PreFilterResult, *framework.Status) {
+    started := time.Now()
+    cycleCtx, cycleSpan := tracer.Start(ctx, "SchedulingCycle", trace.WithAttributes(podAttributes(pod)...))
+    ctx, span := tracer.Start(cycleCtx, "PreFilter")
+    defer span.End()
+
+    gpuReq, err := tp.scheduler.getGPURequirements(pod)
+    if err != nil {
+        endSpan(cycleSpan, err)
+        return nil, framework.NewStatus(framework.UnschedulableAndUnresolvable,
+            fmt.Sprintf("failed to get GPU requirements: %v", err))
+    }
+    cycleSpan.SetAttributes(
+        attribute.Int("nodes_needed", gpuReq.NodesNeeded),
+        attribute.String("strategy", string(tp.scheduler.getPlacementStrategy(gpuReq))),
+    )
+
+    preferredDomain, fromHint := tp.scheduler.preferredDomain(pod)
+    _, snapshotSpan := tracer.Start(ctx, "TopologyCache.Snapshot")
+    snapshot := tp.scheduler.cache.Snapshot()
+    snapshotSpan.End()
+
+    state.Write(cycleStateKey, &cycleState{
+        snapshot:        snapshot,
+        gpuReq:          gpuReq,
+        config:          tp.scheduler.GetConfig(),
+        preferredDomain: preferredDomain,
+        fromHint:        fromHint,
+        rejections:      newFilterRejections(),
+        candidates:      newDomainCandidates(),
+        started:         started,
+        span:            cycleSpan,
+    })
+    return nil, nil
+}
+
+func (tp *TopologySchedulerPlugin) PreFilterExtensions() framework.PreFilterExtensions {
+    return nil
+}
+
+func (tp *TopologySchedulerPlugin) Filter(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeInfo *framework.NodeInfo,
+) *framework.Status {
+    if nodeInfo.Node() == nil {
+        return framework.NewStatus(framework.Error, "node not found")
+    }
+
+    cs, err := getCycleState(state)
+    if err != nil {
+        return framework.AsStatus(err)
+    }
+
+    // Filter runs for every node, so it is summarized on the cycle's span
+    // rather than traced per node
+    status := tp.filter(cs, pod, nodeInfo)
+    rejected := status.Code() == framework.Unschedulable || status.Code() == framework.UnschedulableAndUnresolvable
+    cs.rejections.observe(status.Message(), rejected)
+    return status
+}
+
+func (tp *TopologySchedulerPlugin) filter(cs *cycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+    gpuReq := cs.gpuReq
+
+    domain, err := cs.snapshot.GetDomainForNode(nodeInfo.Node().Name)
+    if err != nil {
+        return framework.NewStatus(framework.Error, 
+            fmt.Sprintf("failed to get domain: %v", err))
+    }
+
+    if !domain.Lifecycle.AcceptsPlacements() {
+        return framework.NewStatus(framework.UnschedulableAndUnresolvable,
+            fmt.Sprintf("domain %s is %s", domain.Name, domain.Lifecycle))
+    }
+
+    if !tp.scheduler.isDomainEligible(domain, gpuReq) {
+        return framework.NewStatus(framework.Unschedulable,
+            "node's domain does not meet GPU requirements")
+    }
+
+    if !cs.snapshot.IsNodeHealthy(nodeInfo.Node().Name) {
+        return framework.NewStatus(framework.Unschedulable, "node is unhealthy")
+    }
+
+    if !cs.snapshot.AdmitsOnSpare(pod, nodeInfo.Node().Name) {
+        return framework.NewStatus(framework.Unschedulable, "node is reserved as a hot spare for recovery")
+    }
+
+    if gpuReq.NodesNeeded > 1 && !tp.scheduler.isDomainHealthy(domain) {
+        return framework.NewStatus(framework.Unschedulable,
+            fmt.Sprintf("domain %s health %.2f is below the multi-node threshold", domain.Name, domain.Health))
+    }
+
+    if gpuReq.NodesNeeded > len(domain.Nodes) && !isDomainReachable(domain) {
+        return framework.NewStatus(framework.Unschedulable,
+            fmt.Sprintf("domain %s uplink is down and the job does not fit in it", domain.Name))
+    }
+
+    if requiresGPU(pod) {
+        return checkLeafConstraints(cs, pod, nodeInfo.Node().Name, domain)
+    }
+    return framework.NewStatus(framework.Success, "")
+}
+
+// checkLeafConstraints rejects a node if placing the pod there would take its
+// job past the configured node or GPU limit within one leaf domain. Zero
+// limits are not enforced.
+func checkLeafConstraints(cs *cycleState, pod *v1.Pod, nodeName string, domain *Domain) *framework.Status {
+    constraints := cs.config.Constraints
+    usage := cs.snapshot.GetJobDomainUsage(getJobName(pod), domain.Name)
+
+    nodes := len(usage.Nodes)
+    if !usage.Nodes[nodeName] {
+        nodes++
+    }
+    if constraints.MaxNodesPerLeaf > 0 && nodes > int(constraints.MaxNodesPerLeaf) {
+        return framework.NewStatus(framework.Unschedulable,
+            fmt.Sprintf("job would use %d nodes of domain %s, above maxNodesPerLeaf %d",
+                nodes, domain.Name, constraints.MaxNodesPerLeaf))
+    }
+
+    gpus := usage.GPUs + getGPURequirements(pod)
+    if constraints.MaxGPUsPerLeaf > 0 && gpus > int(constraints.MaxGPUsPerLeaf) {
+        return framework.NewStatus(framework.Unschedulable,
+            fmt.Sprintf("job would use %d GPUs of domain %s, above maxGPUsPerLeaf %d",
+                gpus, domain.Name, constraints.MaxGPUsPerLeaf))
+    }
+    return framework.NewStatus(framework.Success, "")
+}
+
+// PostFilter runs once no node fits the pod. It preempts nothing and records
+// on the pod why the topology filter rejected the nodes.
+func (tp *TopologySchedulerPlugin) PostFilter(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    filteredNodeStatusMap framework.NodeToStatusMap,
+) (*framework.PostFilterResult, *framework.Status) {
+    if cs, err := getCycleState(state); err == nil {
+        defer cs.endCycle(fmt.Errorf("no node fits the pod"))
+        _, span := cs.startSpan(ctx, "PostFilter")
+        defer span.End()
+
+        if summary := cs.rejections.summary(); summary != "" {
+            tp.scheduler.events.Eventf(pod, v1.EventTypeWarning, EventReasonRejected,
+                "Topology filter rejected %s", summary)
+        }
+        decision := podDecision(DecisionRejection, pod, cs.gpuReq)
+        decision.Strategy = string(tp.scheduler.getPlacementStrategy(cs.gpuReq))
+        decision.PreferredDomain = cs.preferredDomain
+        decision.Rejections = cs.rejections.counts()
+        decision.LatencyMillis = millisSince(cs.started)
+        tp.scheduler.recordDecision(decision)
+    }
+    return nil, framework.NewStatus(framework.Unschedulable)
+}
+
+func (tp *TopologySchedulerPlugin) Score(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeName string,
+) (int64, *framework.Status) {
+    cs, err := getCycleState(state)
+    if err != nil {
+        return 0, framework.AsStatus(err)
+    }
+    gpuReq := cs.gpuReq
+
+    domain, err := cs.snapshot.GetDomainForNode(nodeName)
+    if err != nil {
+        return 0, framework.NewStatus(framework.Error,
+            fmt.Sprintf("failed to get domain: %v", err))
+    }
+
+    candidate := DomainCandidate{
+        Domain:       domain.Name,
+        Base:         scoreDomain(domain, gpuReq, cs.config.Weights),
+        HealthFactor: tp.scheduler.healthScoreFactor(domain),
+        LinkFactor:   linkScoreFactor(domain),
+    }
+    if cs.preferredDomain != "" && domain.Name == cs.preferredDomain {
+        candidate.PreferredBonus = PreferredDomainScoreBonus
+    }
+    // A replacement should take the spare claimed for it rather than regular capacity
+    if cs.snapshot.IsSpareClaimedFor(pod, nodeName) {
+        candidate.SpareBonus = PreferredDomainScoreBonus
+    }
+    score := candidate.Base*candidate.HealthFactor*candidate.LinkFactor +
+        candidate.PreferredBonus + candidate.SpareBonus
+    candidate.Score = score
+    cs.candidates.observe(candidate)
+    return nodeScore(score), framework.NewStatus(framework.Success,
+        "")
+}
+
+// maxDomainScore is the highest score a node can reach: a full base score
+// with both the preferred domain and the claimed spare bonus
+const maxDomainScore = 1 + 2*PreferredDomainScoreBonus
+
+// nodeScore scales a domain score to the framework's node score range
+func nodeScore(score float64) int64 {
+    scaled := int64(score / maxDomainScore * float64(framework.MaxNodeScore))
+    if scaled > framework.MaxNodeScore {
+        return framework.MaxNodeScore
+    }
+    if scaled < framework.MinNodeScore {
+        return framework.MinNodeScore
+    }
+    return scaled
+}
+
+func (tp *TopologySchedulerPlugin) ScoreExtensions() framework.ScoreExtensions {
+    return nil
+}
+
+// Reserve charges the pod's GPUs to the node until it is observed bound
+func (tp *TopologySchedulerPlugin) Reserve(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeName string,
+) *framework.Status {
+    _, span := startCycleSpan(ctx, state, "Reserve", attribute.String("node", nodeName))
+    err := tp.scheduler.cache.AssumePod(pod, nodeName)
+    endSpan(span, err)
+    if err != nil {
+        return framework.NewStatus(framework.Error,
+            fmt.Sprintf("failed to assume pod: %v", err))
+    }
+    return nil
+}
+
+// Unreserve releases the assumed GPUs; the framework also calls it when binding fails
+func (tp *TopologySchedulerPlugin) Unreserve(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeName string,
+) {
+    _, span := startCycleSpan(ctx, state, "Unreserve", attribute.String("node", nodeName))
+    tp.scheduler.cache.ForgetPod(pod)
+    span.End()
+    if cs, err := getCycleState(state); err == nil {
+        cs.endCycle(fmt.Errorf("pod unreserved from node %s", nodeName))
+    }
+}
+
+// PreBind records on a GPU pod where it was placed relative to the job's pods
+// placed so far, and the recovery hint it followed, so the replacement of an
+// evicted pod shows which domain it was steered to. Only the bound pod is
+// annotated; its peers keep the annotations they were bound with.
+func (tp *TopologySchedulerPlugin) PreBind(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeName string,
+) *framework.Status {
+    cs, err := getCycleState(state)
+    if err != nil {
+        return nil
+    }
+    ctx, span := cs.startSpan(ctx, "PreBind", attribute.String("node", nodeName))
+    defer span.End()
+
+    annotations := make(map[string]string)
+    if cs.fromHint {
+        annotations[v1alpha1.AnnotationPreferredDomain] = cs.preferredDomain
+    }
+    if requiresGPU(pod) {
+        placement, err := tp.scheduler.placementAnnotations(pod, nodeName, cs.gpuReq)
+        if err != nil {
+            klog.V(2).Infof("Not annotating placement of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+        }
+        for key, value := range placement {
+            annotations[key] = value
+        }
+    }
+    if len(annotations) == 0 {
+        return nil
+    }
+
+    // The annotations are informational, so failing to write them does not
+    // fail the binding
+    if err := tp.annotatePod(ctx, pod, annotations); err != nil {
+        recordSpanError(span, err)
+        klog.Warningf("Failed to annotate placement of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+    }
+    return nil
+}
+
+// annotatePod merges the annotations into the pod's
+func (tp *TopologySchedulerPlugin) annotatePod(ctx context.Context, pod *v1.Pod, annotations map[string]string) error {
+    patch, err := json.Marshal(map[string]interface{}{
+        "metadata": map[string]interface{}{
+            "annotations": annotations,
+        },
+    })
+    if err != nil {
+        return err
+    }
+    _, err = tp.handle.ClientSet().CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name,
+        types.MergePatchType, patch, metav1.PatchOptions{})
+    return err
+}
+
+func (tp *TopologySchedulerPlugin) PostBind(
+    ctx context.Context,
+    state *framework.CycleState,
+    pod *v1.Pod,
+    nodeName string,
+) {
+    if cs, err := getCycleState(state); err == nil {
+        defer cs.endCycle(nil)
+    }
+    ctx, span := startCycleSpan(ctx, state, "PostBind", attribute.String("node", nodeName))
+    defer span.End()
+
+    tp.scheduler.cache.FinishBinding(pod)
+    tp.scheduler.spares.ObserveBinding(pod)
+    if domain, err := tp.scheduler.cache.GetDomainForNode(nodeName); err == nil {
+        tp.scheduler.recoveries.ObserveBinding(pod, nodeName, domain.Name)
+        if cs, err := getCycleState(state); err == nil {
+            // The replacement a recovery hint waited for is bound
+            if cs.fromHint {
+                tp.scheduler.consumePlacementHint(pod, domain.Name, cs.preferredDomain)
+            }
+            tp.recordPlacement(pod, nodeName, domain, cs)
+        }
+    }
+    tp.scheduler.auditCycle(ctx)
+}
+
+// recordPlacement explains the placement on the pod, with a warning when it
+// missed the preferred domain or had to spread the job wider than its strategy,
+// and appends it to the decision log
+func (tp *TopologySchedulerPlugin) recordPlacement(pod *v1.Pod, nodeName string, domain *Domain, cs *cycleState) {
+    strategy := tp.scheduler.getPlacementStrategy(cs.gpuReq)
+    jobDomains := tp.scheduler.cache.GetJobDomains(getJobName(pod))
+
+    decision := podDecision(DecisionPlacement, pod, cs.gpuReq)
+    decision.Strategy = string(strategy)
+    decision.PreferredDomain = cs.preferredDomain
+    decision.Candidates = cs.candidates.list()
+    decision.Nodes = []string{nodeName}
+    decision.JobNodes = jobNodeNames(tp.scheduler.cache.GetJobNodes(getJobName(pod)))
+    decision.LatencyMillis = millisSince(cs.started)
+    tp.scheduler.recordDecision(decision)
+
+    tp.scheduler.events.Eventf(pod, v1.EventTypeNormal, EventReasonPlaced,
+        "Placed on node %s in domain %s with strategy %s; job spans domains %s",
+        nodeName, domain.Name, strategy, strings.Join(jobDomains, ", "))
+
+    if cs.preferredDomain != "" && cs.preferredDomain != domain.Name {
+        tp.scheduler.events.Eventf(pod, v1.EventTypeWarning, EventReasonFallback,
+            "Preferred domain %s had no room, placed in domain %s", cs.preferredDomain, domain.Name)
+    }
+    if (strategy == SingleDomain || strategy == CompleteDomain) && len(jobDomains) > 1 {
+        tp.scheduler.events.Eventf(pod, v1.EventTypeWarning, EventReasonFallback,
+            "Job did not fit one domain with strategy %s, spread over domains %s",
+            strategy, strings.Join(jobDomains, ", "))
+    }
+}
+
+// startCycleSpan starts the span of an extension point within the pod's
+// scheduling cycle, or on its own if PreFilter did not record the cycle
+func startCycleSpan(ctx context.Context, state *framework.CycleState, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+    if cs, err := getCycleState(state); err == nil {
+        return cs.startSpan(ctx, name, attrs...)
+    }
+    return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// jobNodeNames lists the distinct nodes of a job's pods, sorted
+func jobNodeNames(jobNodes map[types.UID]string) []string {
+    seen := make(map[string]bool, len(jobNodes))
+    names := make([]string, 0, len(jobNodes))
+    for _, nodeName := range jobNodes {
+        if !seen[nodeName] {
+            seen[nodeName] = true
+            names = append(names, nodeName)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+// frameworkRecorder publishes through the scheduler framework's events API recorder
+type frameworkRecorder struct {
+    recorder events.EventRecorder
+}
+
+func (r frameworkRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+    r.recorder.Eventf(object, nil, eventtype, reason, "Scheduling", messageFmt, args...)
+}
@@ -0,0 +1,237 @@
+package algorithm
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "testing"
+    "time"
+
+    v1 "k8s.io/api/core/v1"
+    "k8s.io/apimachinery/pkg/api/resource"
+    metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
+    "k8s.io/kubernetes/pkg/scheduler/framework"
+
+    "github.com/nod-ai/topology-aware-scheduler/pkg/apis/topology/v1alpha1"
+)
+
+// benchGPUCountAnnotation is the job's total GPU count
+const benchGPUCountAnnotation = "topology.scheduler/gpu-count"
+
+// Targets from the design doc: 1000 nodes and 10,000 GPUs at under 500ms and
+// at least 100 decisions per second
+const (
+    benchNodes          = 1000
+    benchGPUsPerNode    = 10
+    benchNodesPerLeaf   = 8
+    benchLeavesPerSpine = 16
+
+    targetLatency            = 500 * time.Millisecond
+    targetDecisionsPerSecond = 100
+)
+
+// benchJob is a job of full-node pods sized for one placement strategy
+type benchJob struct {
+    name     string
+    pods     int
+    strategy PlacementStrategy
+}
+
+// benchJobs cover every strategy; NodesNeeded follows the job's GPU count
+var benchJobs = []benchJob{
+    {name: "SingleDomain", pods: 1, strategy: SingleDomain},
+    {name: "CompleteDomain", pods: 4, strategy: CompleteDomain},
+    {name: "AdjacentDomains", pods: 6, strategy: AdjacentDomains},
+    {name: "MultipleDomains", pods: 16, strategy: MultipleDomains},
+}
+
+// BenchmarkSchedulingCycle runs PreFilter, then Filter and Score on every
+// node, as the framework does for one pod of each job size
+func BenchmarkSchedulingCycle(b *testing.B) {
+    for _, job := range benchJobs {
+        b.Run(job.name, func(b *testing.B) {
+            plugin, nodeInfos := benchPlugin()
+            pod := benchJobPods(b, plugin, job)[0]
+
+            b.ResetTimer()
+            for i := 0; i < b.N; i++ {
+                runCycle(b, plugin, pod, nodeInfos)
+            }
+            checkTargets(b, 1)
+        })
+    }
+}
+
+// BenchmarkSchedulingCycleCacheChange rebuilds the snapshot every cycle, as
+// when a pod is bound or a node changes between cycles
+func BenchmarkSchedulingCycleCacheChange(b *testing.B) {
+    plugin, nodeInfos := benchPlugin()
+    pod := benchJobPods(b, plugin, benchJobs[0])[0]
+    cache := plugin.scheduler.cache
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        cache.Lock()
+        cache.touchLocked()
+        cache.Unlock()
+        runCycle(b, plugin, pod, nodeInfos)
+    }
+    checkTargets(b, 1)
+}
+
+// BenchmarkGangScheduling places every pod of a job one cycle after another,
+// reserving the best scored node each time, so each cycle sees the job's
+// earlier pods in a rebuilt snapshot. The reservations are released between
+// iterations.
+func BenchmarkGangScheduling(b *testing.B) {
+    for _, job := range benchJobs {
+        b.Run(job.name, func(b *testing.B) {
+            plugin, nodeInfos := benchPlugin()
+            pods := benchJobPods(b, plugin, job)
+
+            b.ResetTimer()
+            for i := 0; i < b.N; i++ {
+                for _, pod := range pods {
+                    nodeName := runCycle(b, plugin, pod, nodeInfos)
+                    if nodeName == "" {
+                        b.Fatalf("no node for pod %s of a %d pod job", pod.Name, job.pods)
+                    }
+                    if status := plugin.Reserve(context.Background(), framework.NewCycleState(), pod, nodeName); !status.IsSuccess() {
+                        b.Fatalf("Reserve failed: %v", status.AsError())
+                    }
+                }
+                b.StopTimer()
+                for _, pod := range pods {
+                    plugin.scheduler.cache.ForgetPod(pod)
+                }
+                b.StartTimer()
+            }
+            checkTargets(b, len(pods))
+        })
+    }
+}
+
+// runCycle returns the best scored node that fits the pod, or "" if none
+// does. The GPU fit check stands in for the framework's NodeResourcesFit.
+func runCycle(b *testing.B, plugin *TopologySchedulerPlugin, pod *v1.Pod, nodeInfos []*framework.NodeInfo) string {
+    ctx := context.Background()
+    state := framework.NewCycleState()
+    if _, status := plugin.PreFilter(ctx, state, pod); !status.IsSuccess() {
+        b.Fatalf("PreFilter failed: %v", status.AsError())
+    }
+    cs, err := getCycleState(state)
+    if err != nil {
+        b.Fatal(err)
+    }
+
+    best, bestScore := "", int64(-1)
+    for _, nodeInfo := range nodeInfos {
+        nodeName := nodeInfo.Node().Name
+        if cs.snapshot.GetNodeGPUs(nodeName)+getGPURequirements(pod) > cs.snapshot.GetNodeCapacity(nodeName) {
+            continue
+        }
+        if status := plugin.Filter(ctx, state, pod, nodeInfo); !status.IsSuccess() {
+            continue
+        }
+        score, status := plugin.Score(ctx, state, pod, nodeName)
+        if !status.IsSuccess() {
+            b.Fatalf("Score failed: %v", status.AsError())
+        }
+        if score > bestScore {
+            best, bestScore = nodeName, score
+        }
+    }
+    return best
+}
+
+// checkTargets fails the benchmark if one decision misses the design doc
+// targets; each operation makes the given number of decisions
+func checkTargets(b *testing.B, decisionsPerOp int) {
+    if b.N == 0 {
+        return
+    }
+    perOp := b.Elapsed() / time.Duration(b.N*decisionsPerOp)
+    if perOp >= targetLatency {
+        b.Errorf("%s per decision, target is under %s", perOp, targetLatency)
+    }
+    if perSecond := float64(time.Second) / float64(perOp); perSecond < targetDecisionsPerSecond {
+        b.Errorf("%.0f decisions/s, target is at least %d", perSecond, targetDecisionsPerSecond)
+    }
+}
+
+func benchPlugin() (*TopologySchedulerPlugin, []*framework.NodeInfo) {
+    cache := NewTopologyCache(NewNodeCache())
+    nodeInfos := make([]*framework.NodeInfo, 0, benchNodes)
+    for _, node := range benchNodeList() {
+        cache.UpsertNode(node)
+        nodeInfo := framework.NewNodeInfo()
+        nodeInfo.SetNode(node)
+        nodeInfos = append(nodeInfos, nodeInfo)
+    }
+    return &TopologySchedulerPlugin{scheduler: NewTopologyScheduler(cache)}, nodeInfos
+}
+
+func benchNodeList() []*v1.Node {
+    nodes := make([]*v1.Node, 0, benchNodes)
+    for i := 0; i < benchNodes; i++ {
+        leaf := i / benchNodesPerLeaf
+        spine := leaf / benchLeavesPerSpine
+        nodes = append(nodes, &v1.Node{
+            ObjectMeta: metav1.ObjectMeta{
+                Name: fmt.Sprintf("node-%04d", i),
+                Labels: map[string]string{
+                    v1alpha1.LabelLeafDomain:  fmt.Sprintf("leaf-%03d", leaf),
+                    v1alpha1.LabelSpineDomain: fmt.Sprintf("spine-%02d", spine),
+                },
+            },
+            Status: v1.NodeStatus{
+                Allocatable: v1.ResourceList{
+                    gpuResourceName: *resource.NewQuantity(benchGPUsPerNode, resource.DecimalSI),
+                },
+                Conditions: []v1.NodeCondition{
+                    {Type: v1.NodeReady, Status: v1.ConditionTrue},
+                },
+            },
+        })
+    }
+    return nodes
+}
+
+// benchJobPods returns the full-node pods of a job, checking that the job's
+// size selects the strategy it is meant to exercise
+func benchJobPods(b *testing.B, plugin *TopologySchedulerPlugin, job benchJob) []*v1.Pod {
+    pods := make([]*v1.Pod, 0, job.pods)
+    for i := 0; i < job.pods; i++ {
+        pods = append(pods, &v1.Pod{
+            ObjectMeta: metav1.ObjectMeta{
+                Name:      fmt.Sprintf("bench-%d", i),
+                Namespace: "default",
+                UID:       types.UID(fmt.Sprintf("bench-uid-%d", i)),
+                Labels:    map[string]string{"job-name": "bench"},
+                Annotations: map[string]string{
+                    benchGPUCountAnnotation: strconv.Itoa(job.pods * benchGPUsPerNode),
+                },
+            },
+            Spec: v1.PodSpec{
+                Containers: []v1.Container{{
+                    Name: "train",
+                    Resources: v1.ResourceRequirements{
+                        Limits: v1.ResourceList{
+                            gpuResourceName: *resource.NewQuantity(benchGPUsPerNode, resource.DecimalSI),
+                        },
+                    },
+                }},
+            },
+        })
+    }
+
+    gpuReq, err := plugin.scheduler.getGPURequirements(pods[0])
+    if err != nil {
+        b.Fatal(err)
+    }
+    if strategy := plugin.scheduler.getPlacementStrategy(gpuReq); strategy != job.strategy {
+        b.Fatalf("a %d node job is placed with %s, want %s", gpuReq.NodesNeeded, strategy, job.strategy)
+    }
+    return pods
+}
@@ -0,0 +1,149 @@
+package topology
+
+import (
+    "container/heap"
+    "math"
+)
+
+const (
+    // DefaultLinkLatencyMicros and DefaultLinkCapacityGbps are assumed for links that do not declare them
+    DefaultLinkLatencyMicros = 1.0
+    DefaultLinkCapacityGbps  = 100.0
+
+    // referenceTransferMbit sizes the bandwidth term of a link cost, so that
+    // a 100G link adds 10us and a 400G link 2.5us on top of the latency
+    referenceTransferMbit = 1.0
+)
+
+// Edge is a bidirectional link and the cost of crossing it
+type Edge struct {
+    Source string
+    Target string
+    Cost   float64
+}
+
+// LinkCost is the time in microseconds to move a reference transfer across a
+// link: its latency plus the inverse of its bandwidth
+func LinkCost(latencyMicros, capacityGbps float64) float64 {
+    if latencyMicros <= 0 {
+        latencyMicros = DefaultLinkLatencyMicros
+    }
+    if capacityGbps <= 0 {
+        capacityGbps = DefaultLinkCapacityGbps
+    }
+    return latencyMicros + referenceTransferMbit*1000/capacityGbps
+}
+
+// DistanceTable holds the weighted shortest-path distance between every pair
+// of domains. It is immutable once built; rebuild it when the links change.
+type DistanceTable struct {
+    index map[string]int
+    dist  [][]float64
+}
+
+// NewDistanceTable runs Dijkstra from every vertex of the graph
+func NewDistanceTable(edges []Edge) *DistanceTable {
+    index, adjacency := buildGraph(edges)
+
+    dt := &DistanceTable{
+        index: index,
+        dist:  make([][]float64, len(index)),
+    }
+    for s := range dt.dist {
+        dt.dist[s] = shortestPaths(adjacency, s)
+    }
+    return dt
+}
+
+// Distance returns the cost of the cheapest path between two domains and
+// whether target is reachable from source. A domain is always at distance 0
+// from itself.
+func (dt *DistanceTable) Distance(source, target string) (float64, bool) {
+    if source == target {
+        return 0, true
+    }
+
+    s, ok := dt.index[source]
+    if !ok {
+        return 0, false
+    }
+    t, ok := dt.index[target]
+    if !ok {
+        return 0, false
+    }
+
+    d := dt.dist[s][t]
+    if math.IsInf(d, 1) {
+        return 0, false
+    }
+    return d, true
+}
+
+type neighbor struct {
+    vertex int
+    cost   float64
+}
+
+func buildGraph(edges []Edge) (map[string]int, [][]neighbor) {
+    index := make(map[string]int)
+    vertex := func(name string) int {
+        i, exists := index[name]
+        if !exists {
+            i = len(index)
+            index[name] = i
+        }
+        return i
+    }
+
+    type pair struct{ a, b int }
+    pairs := make([]pair, len(edges))
+    for i, edge := range edges {
+        pairs[i] = pair{vertex(edge.Source), vertex(edge.Target)}
+    }
+
+    adjacency := make([][]neighbor, len(index))
+    for i, edge := range edges {
+        p := pairs[i]
+        adjacency[p.a] = append(adjacency[p.a], neighbor{p.b, edge.Cost})
+        adjacency[p.b] = append(adjacency[p.b], neighbor{p.a, edge.Cost})
+    }
+    return index, adjacency
+}
+
+// shortestPaths returns the distance from source to every vertex, +Inf when unreachable
+func shortestPaths(adjacency [][]neighbor, source int) []float64 {
+    dist := make([]float64, len(adjacency))
+    for i := range dist {
+        dist[i] = math.Inf(1)
+    }
+    dist[source] = 0
+
+    queue := &distanceQueue{{source, 0}}
+    for queue.Len() > 0 {
+        current := heap.Pop(queue).(neighbor)
+        if current.cost > dist[current.vertex] {
+            continue
+        }
+        for _, next := range adjacency[current.vertex] {
+            if d := current.cost + next.cost; d < dist[next.vertex] {
+                dist[next.vertex] = d
+                heap.Push(queue, neighbor{next.vertex, d})
+            }
+        }
+    }
+    return dist
+}
+
+// distanceQueue is a min-heap of vertices ordered by tentative distance
+type distanceQueue []neighbor
+
+func (q distanceQueue) Len() int            { return len(q) }
+func (q distanceQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q distanceQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *distanceQueue) Push(x interface{}) { *q = append(*q, x.(neighbor)) }
+func (q *distanceQueue) Pop() interface{} {
+    old := *q
+    item := old[len(old)-1]
+    *q = old[:len(old)-1]
+    return item
+}
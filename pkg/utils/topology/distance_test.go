@@ -0,0 +1,62 @@
+package topology
+
+import (
+    "testing"
+)
+
+func TestLinkCost(t *testing.T) {
+    tests := []struct {
+        name          string
+        latencyMicros float64
+        capacityGbps  float64
+        want          float64
+    }{
+        {name: "defaults", want: 11},
+        {name: "400G", latencyMicros: 1, capacityGbps: 400, want: 3.5},
+        {name: "slow link", latencyMicros: 5, capacityGbps: 100, want: 15},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := LinkCost(tt.latencyMicros, tt.capacityGbps); got != tt.want {
+                t.Errorf("LinkCost(%v, %v) = %v, want %v", tt.latencyMicros, tt.capacityGbps, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDistanceTable(t *testing.T) {
+    // Two leaves under spine-a, one under spine-b, a cheap direct link from
+    // leaf-2 to leaf-3 and an expensive one from leaf-1 to leaf-3. leaf-5 is
+    // only linked to leaf-4.
+    table := NewDistanceTable([]Edge{
+        {Source: "leaf-1", Target: "spine-a", Cost: 1},
+        {Source: "leaf-2", Target: "spine-a", Cost: 1},
+        {Source: "leaf-3", Target: "spine-b", Cost: 1},
+        {Source: "leaf-2", Target: "leaf-3", Cost: 2},
+        {Source: "leaf-1", Target: "leaf-3", Cost: 10},
+        {Source: "leaf-4", Target: "leaf-5", Cost: 1},
+    })
+
+    tests := []struct {
+        source, target string
+        want           float64
+        reachable      bool
+    }{
+        {source: "leaf-1", target: "leaf-1", want: 0, reachable: true},
+        {source: "leaf-1", target: "leaf-2", want: 2, reachable: true},
+        // Through spine-a and the cheap link rather than the direct one
+        {source: "leaf-1", target: "leaf-3", want: 4, reachable: true},
+        {source: "leaf-3", target: "leaf-1", want: 4, reachable: true},
+        {source: "spine-a", target: "spine-b", want: 4, reachable: true},
+        {source: "leaf-1", target: "leaf-5", reachable: false},
+        {source: "leaf-1", target: "unknown", reachable: false},
+        {source: "unknown", target: "unknown", want: 0, reachable: true},
+    }
+    for _, tt := range tests {
+        got, reachable := table.Distance(tt.source, tt.target)
+        if reachable != tt.reachable || got != tt.want {
+            t.Errorf("Distance(%s, %s) = %v, %v, want %v, %v",
+                tt.source, tt.target, got, reachable, tt.want, tt.reachable)
+        }
+    }
+}
@@ -1,115 +1,166 @@
-go
-package topology
-
-import (
-    "encoding/json"
-    "fmt"
-    "math"
-    "strconv"
-    v1 "k8s.io/api/core/v1"
-)
-
-type NodeGPUInfo struct {
-    TotalGPUs     int
-    AllocatedGPUs int
-    GPUTypes      []string
-    GPUMemory     []int64
-}
-
-func ExtractNodeGPUInfo(node *v1.Node) (*NodeGPUInfo, error) {
-    info := &NodeGPUInfo{}
-    
-    if val, ok := node.Labels["nvidia.com/gpu.count"]; ok {
-        count, err := strconv.Atoi(val)
-        if err != nil {
-            return nil, fmt.Errorf("invalid GPU count: %v", err)
-        }
-        info.TotalGPUs = count
-    }
-
-    if val, ok := node.Labels["nvidia.com/gpu.memory"]; ok {
-        if err := json.Unmarshal([]byte(val), &info.GPUMemory); err != nil {
-            return nil, fmt.Errorf("invalid GPU memory info: %v", err)
-        }
-    }
-    
-    if val, ok := node.Labels["nvidia.com/gpu.type"]; ok {
-        info.GPUTypes = append(info.GPUTypes, val)
-    }
-
-    return info, nil
-}
-
-func CalculateDomainDistance(source, target *Domain, connections map[string][]string) int {
-    if source.Name == target.Name {
-        return 0
-    }
-
-    visited := make(map[string]bool)
-    queue := []struct {
-        domain string
-        dist   int
-    }{{source.Name, 0}}
-    visited[source.Name] = true
-
-    for len(queue) > 0 {
-        current := queue[0]
-        queue = queue[1:]
-
-        for _, neighbor := range connections[current.domain] {
-            if neighbor == target.Name {
-                return current.dist + 1
-            }
-            if !visited[neighbor] {
-                visited[neighbor] = true
-                queue = append(queue, struct {
-                    domain string
-                    dist   int
-                }{neighbor, current.dist + 1})
-            }
-        }
-    }
-    return math.MaxInt32
-}
-
-func CalculateDomainHealth(domain *Domain) float64 {
-    if len(domain.Nodes) == 0 {
-        return 0.0
-    }
-
-    healthyNodes := 0
-    for _, node := range domain.Nodes {
-        if IsNodeHealthy(node) {
-            healthyNodes++
-        }
-    }
-    return float64(healthyNodes) / float64(len(domain.Nodes))
-}
-
-func IsNodeHealthy(node *v1.Node) bool {
-    for _, condition := range node.Status.Conditions {
-        if condition.Type == v1.NodeReady {
-            return condition.Status == v1.ConditionTrue
-        }
-    }
-    return false
-}
-
-func CalculateGPUFragmentation(domain *Domain) float64 {
-    if len(domain.Nodes) == 0 {
-        return 0.0
-    }
-
-    totalPartialNodes := 0
-    for _, node := range domain.Nodes {
-        info, err := ExtractNodeGPUInfo(node)
-        if err != nil {
-            continue
-        }
-        if info.AllocatedGPUs > 0 && info.AllocatedGPUs < info.TotalGPUs {
-            totalPartialNodes++
-        }
-    }
-
-    return float64(totalPartialNodes) / float64(len(domain.Nodes))
-}
+go
+package topology
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "strconv"
+    "time"
+    v1 "k8s.io/api/core/v1"
+)
+
+type NodeGPUInfo struct {
+    TotalGPUs     int
+    AllocatedGPUs int
+    GPUTypes      []string
+    GPUMemory     []int64
+    // Devices is the health of each GPU, when a device health source reports it
+    Devices []GPUDevice
+}
+
+// GPUDevice is the health of a single GPU. Since is when it last changed.
+type GPUDevice struct {
+    ID      string    `json:"id"`
+    Healthy bool      `json:"healthy"`
+    Reason  string    `json:"reason,omitempty"`
+    Since   time.Time `json:"since"`
+}
+
+// UnhealthyGPUs counts the devices reported unhealthy
+func (info *NodeGPUInfo) UnhealthyGPUs() int {
+    unhealthy := 0
+    for _, device := range info.Devices {
+        if !device.Healthy {
+            unhealthy++
+        }
+    }
+    return unhealthy
+}
+
+// UsableGPUs is the GPU count less the unhealthy devices
+func (info *NodeGPUInfo) UsableGPUs() int {
+    if usable := info.TotalGPUs - info.UnhealthyGPUs(); usable > 0 {
+        return usable
+    }
+    return 0
+}
+
+func ExtractNodeGPUInfo(node *v1.Node) (*NodeGPUInfo, error) {
+    info := &NodeGPUInfo{}
+    
+    if val, ok := node.Labels["nvidia.com/gpu.count"]; ok {
+        count, err := strconv.Atoi(val)
+        if err != nil {
+            return nil, fmt.Errorf("invalid GPU count: %v", err)
+        }
+        info.TotalGPUs = count
+    }
+
+    if val, ok := node.Labels["nvidia.com/gpu.memory"]; ok {
+        if err := json.Unmarshal([]byte(val), &info.GPUMemory); err != nil {
+            return nil, fmt.Errorf("invalid GPU memory info: %v", err)
+        }
+    }
+    
+    if val, ok := node.Labels["nvidia.com/gpu.type"]; ok {
+        info.GPUTypes = append(info.GPUTypes, val)
+    }
+
+    return info, nil
+}
+
+// CalculateDomainDistance returns the weighted shortest-path distance between
+// two domains and whether target is reachable at all. Build a DistanceTable
+// instead when many distances over the same edges are needed.
+func CalculateDomainDistance(source, target *Domain, edges []Edge) (float64, bool) {
+    if source.Name == target.Name {
+        return 0, true
+    }
+
+    index, adjacency := buildGraph(edges)
+    s, ok := index[source.Name]
+    if !ok {
+        return 0, false
+    }
+    t, ok := index[target.Name]
+    if !ok {
+        return 0, false
+    }
+
+    distance := shortestPaths(adjacency, s)[t]
+    if math.IsInf(distance, 1) {
+        return 0, false
+    }
+    return distance, true
+}
+
+func CalculateDomainHealth(domain *Domain) float64 {
+    if len(domain.Nodes) == 0 {
+        return 0.0
+    }
+
+    healthyNodes := 0
+    for _, node := range domain.Nodes {
+        if IsNodeHealthy(node) {
+            healthyNodes++
+        }
+    }
+    return float64(healthyNodes) / float64(len(domain.Nodes))
+}
+
+// unhealthyTaints are set by the node lifecycle controller when a node is
+// failing. Cordoning is not a health problem and is left out.
+var unhealthyTaints = map[string]bool{
+    v1.TaintNodeNotReady:           true,
+    v1.TaintNodeUnreachable:        true,
+    v1.TaintNodeMemoryPressure:     true,
+    v1.TaintNodeDiskPressure:       true,
+    v1.TaintNodePIDPressure:        true,
+    v1.TaintNodeNetworkUnavailable: true,
+}
+
+// IsNodeHealthy reports whether a node is Ready, has no pressure or network
+// conditions and carries none of the unhealthy node taints
+func IsNodeHealthy(node *v1.Node) bool {
+    ready := false
+    for _, condition := range node.Status.Conditions {
+        switch condition.Type {
+        case v1.NodeReady:
+            ready = condition.Status == v1.ConditionTrue
+        case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure, v1.NodeNetworkUnavailable:
+            if condition.Status == v1.ConditionTrue {
+                return false
+            }
+        }
+    }
+    if !ready {
+        return false
+    }
+
+    for _, taint := range node.Spec.Taints {
+        if unhealthyTaints[taint.Key] {
+            return false
+        }
+    }
+    return true
+}
+
+func CalculateGPUFragmentation(domain *Domain) float64 {
+    if len(domain.Nodes) == 0 {
+        return 0.0
+    }
+
+    totalPartialNodes := 0
+    for _, node := range domain.Nodes {
+        info, err := ExtractNodeGPUInfo(node)
+        if err != nil {
+            continue
+        }
+        if info.AllocatedGPUs > 0 && info.AllocatedGPUs < info.TotalGPUs {
+            totalPartialNodes++
+        }
+    }
+
+    return float64(totalPartialNodes) / float64(len(domain.Nodes))
+}